@@ -0,0 +1,139 @@
+package cache
+
+import (
+	"time"
+)
+
+// AtomicCache is a Cache with conditional operations that read and write a key under a single lock,
+// so no other operation can change the key in between. The in-memory caches implement it;
+// callers that need these operations can type-assert a Cache to AtomicCache.
+type AtomicCache interface {
+	Cache
+
+	// GetOrSet returns the live value stored under key and true if there is one.
+	// Otherwise it stores value with the given TTL and returns value and false.
+	// If ttl <= 0, the stored value does not expire. If the cache rejects value, it returns the error
+	// SetChecked would, such as one wrapping ErrValueTooLarge or ErrCacheFull, with value and false.
+	GetOrSet(key string, value any, ttl time.Duration) (actual any, loaded bool, err error)
+	// CompareAndSwap stores new under key if the key holds a live value equal to old, keeping the item's TTL,
+	// and reports whether it did. Values are compared with the cache's EqualFunc, reflect.DeepEqual by default.
+	// It returns false, leaving old in place, if the cache rejects new, for example for exceeding MaxValueSize.
+	CompareAndSwap(key string, old, new any) bool
+	// CompareAndDelete removes the item stored under key if it holds a live value equal to old,
+	// and reports whether it did. Values are compared like in CompareAndSwap.
+	CompareAndDelete(key string, old any) bool
+	// GetAndDelete removes the live item stored under key and returns its value.
+	// Returns (nil, false) if the key does not exist or is expired.
+	GetAndDelete(key string) (any, bool)
+	// Increment atomically adds delta to the int64 value stored under the specified key and returns the new value.
+	// A missing or expired key starts from zero without expiration; an existing item keeps its TTL.
+	// Returns an error wrapping ErrWrongType if the stored value is not an int64,
+	// or the error SetChecked would return if the cache rejects the new value.
+	Increment(key string, delta int64) (int64, error)
+	// IncrementFloat is like Increment for float64 values, such as latency sums.
+	// Returns an error wrapping ErrWrongType if the stored value is not a float64.
+	IncrementFloat(key string, delta float64) (float64, error)
+}
+
+// GetOrSet returns the live value stored under key, or stores value with the given TTL under the write lock.
+// If the cache rejects value, for example because it is full and cannot evict, value is returned with the error.
+func (c *inMemoryCache) GetOrSet(key string, value any, ttl time.Duration) (any, bool, error) {
+	key = c.normalizeKey(key)
+
+	c.mu.Lock()
+	defer c.unlock()
+
+	if item, ok := c.items[key]; ok && !c.isExpired(item) {
+		c.hits.Add(1)
+		item.accesses.Add(1)
+		if c.policy != nil {
+			c.policy.OnAccess(key)
+		}
+		return c.copyValue(item.value), true, nil
+	}
+
+	c.misses.Add(1)
+	c.removeLocked(key, EventExpire)
+	if err := c.storeLocked(key, c.newItem(value, ttl)); err != nil {
+		return value, false, err
+	}
+
+	return value, false, nil
+}
+
+// CompareAndSwap replaces the live value stored under key with new under the write lock if it equals old.
+// The item keeps its expiration, priority, tier, and pin. A rejected new value leaves the item unchanged.
+func (c *inMemoryCache) CompareAndSwap(key string, old, new any) bool {
+	key = c.normalizeKey(key)
+
+	c.mu.Lock()
+	defer c.unlock()
+
+	item, ok := c.items[key]
+	if !ok {
+		return false
+	}
+	if c.isExpired(item) {
+		c.removeLocked(key, EventExpire)
+		return false
+	}
+	if !c.equalFn(settledValue(item.value), old) {
+		return false
+	}
+
+	item.value = c.copyValue(new)
+	if err := c.storeLocked(key, item); err != nil {
+		return false
+	}
+
+	return true
+}
+
+// CompareAndDelete removes the live item stored under key under the write lock if its value equals old.
+func (c *inMemoryCache) CompareAndDelete(key string, old any) bool {
+	key = c.normalizeKey(key)
+
+	c.mu.Lock()
+	defer c.unlock()
+
+	item, ok := c.items[key]
+	if !ok {
+		return false
+	}
+	if c.isExpired(item) {
+		c.removeLocked(key, EventExpire)
+		return false
+	}
+	if !c.equalFn(settledValue(item.value), old) {
+		return false
+	}
+
+	c.removeLocked(key, EventDelete)
+
+	return true
+}
+
+// GetAndDelete removes the live item stored under key under the write lock and returns its value.
+// An expired item is removed as an expiration and (nil, false) is returned.
+func (c *inMemoryCache) GetAndDelete(key string) (any, bool) {
+	key = c.normalizeKey(key)
+
+	c.mu.Lock()
+	defer c.unlock()
+
+	item, ok := c.items[key]
+	if !ok {
+		c.misses.Add(1)
+		return nil, false
+	}
+	if c.isExpired(item) {
+		c.misses.Add(1)
+		c.removeLocked(key, EventExpire)
+		return nil, false
+	}
+
+	c.hits.Add(1)
+	c.removeLocked(key, EventDelete)
+
+	return c.copyValue(item.value), true
+}
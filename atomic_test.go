@@ -0,0 +1,360 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestInMemoryCachesImplementAtomicCache(t *testing.T) {
+	for name, c := range map[string]Cache{
+		"NewCache":            NewCache(),
+		"NewBoundedCache":     NewBoundedCache(10, nil),
+		"NewCacheWithKeyFunc": NewCacheWithKeyFunc(strings.ToLower),
+	} {
+		if _, ok := c.(AtomicCache); !ok {
+			t.Fatalf("%s() does not implement AtomicCache", name)
+		}
+	}
+}
+
+// runConcurrently runs fn from n goroutines at once and waits for them.
+func runConcurrently(n int, fn func(i int)) {
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			fn(i)
+		}()
+	}
+	close(start)
+	wg.Wait()
+}
+
+func TestGetOrSetConcurrent(t *testing.T) {
+	c := NewCache().(AtomicCache)
+
+	var stored atomic.Int64
+	winners := make([]any, 50)
+	runConcurrently(len(winners), func(i int) {
+		actual, loaded, _ := c.GetOrSet("a", i, 0)
+		if !loaded {
+			stored.Add(1)
+		}
+		winners[i] = actual
+	})
+
+	if stored.Load() != 1 {
+		t.Fatalf("GetOrSet stored %d times, want once", stored.Load())
+	}
+	for i, actual := range winners {
+		if actual != winners[0] {
+			t.Fatalf("caller %d got %v, want every caller to get the stored %v", i, actual, winners[0])
+		}
+	}
+}
+
+func TestCompareAndSwapConcurrent(t *testing.T) {
+	c := NewCache().(AtomicCache)
+	c.Set("counter", 0)
+
+	const goroutines, increments = 20, 100
+	runConcurrently(goroutines, func(int) {
+		for range increments {
+			for {
+				old, _ := c.Get("counter")
+				if c.CompareAndSwap("counter", old, old.(int)+1) {
+					break
+				}
+			}
+		}
+	})
+
+	if value, _ := c.Get("counter"); value != goroutines*increments {
+		t.Fatalf("counter = %v, want %d", value, goroutines*increments)
+	}
+	if c.CompareAndSwap("counter", -1, 0) || c.CompareAndSwap("missing", nil, 0) {
+		t.Fatal("CompareAndSwap() = true with a mismatched old value")
+	}
+}
+
+func TestCompareAndDelete(t *testing.T) {
+	c := NewCache().(AtomicCache)
+	c.Set("a", []int{1, 2})
+
+	if c.CompareAndDelete("a", []int{1, 3}) {
+		t.Fatal("CompareAndDelete() = true with a mismatched value")
+	}
+	if !c.CompareAndDelete("a", []int{1, 2}) {
+		t.Fatal("CompareAndDelete() = false with an equal value")
+	}
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("Get(a) ok = true after CompareAndDelete")
+	}
+}
+
+func TestAtomicWritesRejectedByCache(t *testing.T) {
+	// The sizer measures counters by their value, so an increment can push one over MaxValueSize.
+	c := NewCacheWithConfig(CacheConfig{
+		MaxValueSize: 10,
+		Sizer: func(value any) int {
+			if n, ok := value.(int64); ok {
+				return int(n)
+			}
+			return valueSize(value)
+		},
+	}).(AtomicCache)
+	c.Set("s", "short")
+	c.Set("n", int64(5))
+
+	if c.CompareAndSwap("s", "short", strings.Repeat("x", 11)) {
+		t.Fatal("CompareAndSwap() = true for a value over MaxValueSize")
+	}
+	if value, _ := c.Get("s"); value != "short" {
+		t.Fatalf("Get(s) = %v after a rejected CompareAndSwap, want short", value)
+	}
+	if n, err := c.Increment("n", 10); !errors.Is(err, ErrValueTooLarge) {
+		t.Fatalf("Increment(n, 10) = %d, %v, want ErrValueTooLarge", n, err)
+	}
+	if value, _ := c.Get("n"); value != int64(5) {
+		t.Fatalf("Get(n) = %v after a rejected Increment, want 5", value)
+	}
+
+	full := newPinnedFullCache(FullReject).(AtomicCache)
+	actual, loaded, err := full.GetOrSet("new", 1, 0)
+	if !errors.Is(err, ErrCacheFull) || loaded || actual != 1 {
+		t.Fatalf("GetOrSet(new) = %v, %v, %v on a full cache, want 1, false, ErrCacheFull", actual, loaded, err)
+	}
+	if _, ok := full.Get("new"); ok {
+		t.Fatal("Get(new) ok = true after a rejected GetOrSet")
+	}
+	if actual, loaded, err := full.GetOrSet("p1", 2, 0); err != nil || !loaded || actual != 1 {
+		t.Fatalf("GetOrSet(p1) = %v, %v, %v, want 1, true, nil", actual, loaded, err)
+	}
+}
+
+func TestGetAndDeleteConcurrent(t *testing.T) {
+	c := NewCache().(AtomicCache)
+	const keys = 100
+	for i := range keys {
+		c.Set(fmt.Sprint(i), i)
+	}
+
+	// Every key is taken by exactly one of the goroutines racing for it.
+	var taken atomic.Int64
+	runConcurrently(10, func(int) {
+		for i := range keys {
+			if _, ok := c.GetAndDelete(fmt.Sprint(i)); ok {
+				taken.Add(1)
+			}
+		}
+	})
+
+	if taken.Load() != keys {
+		t.Fatalf("GetAndDelete took %d values, want %d", taken.Load(), keys)
+	}
+	if size := c.(StatsReporter).Stats().Size; size != 0 {
+		t.Fatalf("Size = %d, want 0", size)
+	}
+}
+
+func TestIncrementConcurrent(t *testing.T) {
+	c := NewCache().(AtomicCache)
+
+	const goroutines, increments = 20, 100
+	runConcurrently(goroutines, func(int) {
+		for range increments {
+			if _, err := c.Increment("hits", 1); err != nil {
+				t.Errorf("Increment() error = %v", err)
+				return
+			}
+		}
+	})
+
+	if value, _ := c.Get("hits"); value != int64(goroutines*increments) {
+		t.Fatalf("hits = %v, want %d", value, goroutines*increments)
+	}
+}
+
+func TestIncrementKeepsTTLAndRejectsWrongType(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCacheWithConfig(CacheConfig{Clock: clock}).(AtomicCache)
+	c.SetWithTTL("n", int64(5), time.Minute)
+	c.Set("s", "five")
+
+	if n, err := c.Increment("n", 2); err != nil || n != 7 {
+		t.Fatalf("Increment(n) = %d, %v, want 7, nil", n, err)
+	}
+	if ttl, _ := c.(TTLCache).GetTTL("n"); ttl != time.Minute {
+		t.Fatalf("GetTTL(n) = %v, want the TTL kept", ttl)
+	}
+	if _, err := c.Increment("s", 1); !errors.Is(err, ErrWrongType) {
+		t.Fatalf("Increment(s) error = %v, want ErrWrongType", err)
+	}
+
+	clock.Advance(time.Hour)
+	if n, err := c.Increment("n", 1); err != nil || n != 1 {
+		t.Fatalf("Increment of an expired key = %d, %v, want 1, nil", n, err)
+	}
+}
+
+func TestIncrementFloat(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCacheWithConfig(CacheConfig{Clock: clock}).(AtomicCache)
+
+	if sum, err := c.IncrementFloat("latency", 1.5); err != nil || sum != 1.5 {
+		t.Fatalf("IncrementFloat() of an absent key = %v, %v, want 1.5, nil", sum, err)
+	}
+	if sum, err := c.IncrementFloat("latency", -0.25); err != nil || sum != 1.25 {
+		t.Fatalf("IncrementFloat(latency) = %v, %v, want 1.25, nil", sum, err)
+	}
+
+	c.SetWithTTL("timed", 2.0, time.Minute)
+	if sum, err := c.IncrementFloat("timed", 1); err != nil || sum != 3 {
+		t.Fatalf("IncrementFloat(timed) = %v, %v, want 3, nil", sum, err)
+	}
+	if ttl, _ := c.(TTLCache).GetTTL("timed"); ttl != time.Minute {
+		t.Fatalf("GetTTL(timed) = %v, want the TTL kept", ttl)
+	}
+
+	for key, value := range map[string]any{"int": int64(1), "float32": float32(1), "string": "1"} {
+		c.Set(key, value)
+		if _, err := c.IncrementFloat(key, 1); !errors.Is(err, ErrWrongType) {
+			t.Fatalf("IncrementFloat(%s) error = %v, want ErrWrongType", key, err)
+		}
+		if got, _ := c.Get(key); got != value {
+			t.Fatalf("Get(%s) = %v after a rejected increment, want %v unchanged", key, got, value)
+		}
+	}
+
+	clock.Advance(time.Hour)
+	if sum, err := c.IncrementFloat("timed", 0.5); err != nil || sum != 0.5 {
+		t.Fatalf("IncrementFloat() of an expired key = %v, %v, want 0.5, nil", sum, err)
+	}
+}
+
+func TestIncrementFloatConcurrent(t *testing.T) {
+	c := NewCache().(AtomicCache)
+
+	const goroutines, increments, delta = 20, 100, 0.1
+	runConcurrently(goroutines, func(int) {
+		for range increments {
+			if _, err := c.IncrementFloat("sum", delta); err != nil {
+				t.Errorf("IncrementFloat() error = %v", err)
+				return
+			}
+		}
+	})
+
+	value, _ := c.Get("sum")
+	if sum, want := value.(float64), goroutines*increments*delta; math.Abs(sum-want) > 1e-9 {
+		t.Fatalf("sum = %v, want %v", sum, want)
+	}
+}
+
+// versioned is a value whose identity is its ID, regardless of its payload.
+type versioned struct {
+	ID      int
+	Payload []byte
+}
+
+func TestEqualFunc(t *testing.T) {
+	var calls atomic.Int64
+	c := NewCacheWithConfig(CacheConfig{EqualFunc: func(a, b any) bool {
+		calls.Add(1)
+		va, okA := a.(versioned)
+		vb, okB := b.(versioned)
+		return okA && okB && va.ID == vb.ID
+	}}).(AtomicCache)
+	c.Set("a", versioned{ID: 1, Payload: []byte("current")})
+
+	// DeepEqual would reject the stale payload; the custom function only compares IDs.
+	if !c.CompareAndSwap("a", versioned{ID: 1, Payload: []byte("stale")}, versioned{ID: 2}) {
+		t.Fatal("CompareAndSwap() = false for a value equal by EqualFunc")
+	}
+	if c.CompareAndSwap("a", versioned{ID: 1}, versioned{ID: 3}) {
+		t.Fatal("CompareAndSwap() = true for a value unequal by EqualFunc")
+	}
+	if value, _ := c.Get("a"); value.(versioned).ID != 2 {
+		t.Fatalf("Get(a) = %+v, want ID 2", value)
+	}
+
+	if c.CompareAndDelete("a", versioned{ID: 1}) {
+		t.Fatal("CompareAndDelete() = true for a value unequal by EqualFunc")
+	}
+	if !c.CompareAndDelete("a", versioned{ID: 2, Payload: []byte("other")}) {
+		t.Fatal("CompareAndDelete() = false for a value equal by EqualFunc")
+	}
+	if calls.Load() != 4 {
+		t.Fatalf("EqualFunc called %d times, want once per comparison", calls.Load())
+	}
+}
+
+func TestEqualFuncDefaultsToDeepEqual(t *testing.T) {
+	c := NewCache().(AtomicCache)
+	c.Set("a", versioned{ID: 1, Payload: []byte("x")})
+
+	if c.CompareAndSwap("a", versioned{ID: 1, Payload: []byte("y")}, versioned{ID: 2}) {
+		t.Fatal("CompareAndSwap() = true for values that are not deeply equal")
+	}
+	if !c.CompareAndSwap("a", versioned{ID: 1, Payload: []byte("x")}, versioned{ID: 2}) {
+		t.Fatal("CompareAndSwap() = false for deeply equal values")
+	}
+}
+
+func TestDrainLosesNoIncrements(t *testing.T) {
+	c := NewCache().(AtomicCache)
+	const goroutines, increments = 8, 1000
+
+	done := make(chan struct{})
+	var drained int64
+	go func() {
+		defer close(done)
+		for range 200 {
+			for _, value := range c.(BulkCache).Drain() {
+				drained += value.(int64)
+			}
+		}
+	}()
+	runConcurrently(goroutines, func(i int) {
+		key := fmt.Sprintf("counter:%d", i%3)
+		for range increments {
+			if _, err := c.Increment(key, 1); err != nil {
+				t.Errorf("Increment() error = %v", err)
+				return
+			}
+		}
+	})
+	<-done
+
+	// Every increment is either in a drained map or still stored.
+	for _, value := range c.(BulkCache).Drain() {
+		drained += value.(int64)
+	}
+	if drained != goroutines*increments {
+		t.Fatalf("drained %d increments, want %d", drained, goroutines*increments)
+	}
+}
+
+func TestDrainSkipsExpiredItems(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCacheWithConfig(CacheConfig{Clock: clock})
+	c.Set("a", 1)
+	c.SetWithTTL("expired", 2, time.Second)
+	clock.Advance(time.Minute)
+
+	if values := c.(BulkCache).Drain(); len(values) != 1 || values["a"] != 1 {
+		t.Fatalf("Drain() = %v, want map[a:1]", values)
+	}
+	if size := c.(StatsReporter).Stats().Size; size != 0 {
+		t.Fatalf("Size = %d after Drain, want 0", size)
+	}
+}
@@ -0,0 +1,59 @@
+package cache
+
+import (
+	"time"
+)
+
+// backlogWatch is the callback registered with OnBacklog.
+type backlogWatch struct {
+	threshold int
+	fn        func(backlog int)
+}
+
+// OnBacklog registers fn to be called with the backlog, the number of expired items still stored, whenever
+// a cleanup pass such as a cache worker's cycle starts with a backlog above threshold. A growing backlog means
+// items expire faster than the worker removes them, so memory grows despite the TTLs. fn runs after the write
+// lock is released and may call back into the cache. Registering replaces the previous callback; a nil fn
+// removes it. Passes that visit every item count the backlog as they go. Batched and tier passes visit only
+// some items, so they count it separately, and only while a callback is registered.
+func (c *inMemoryCache) OnBacklog(threshold int, fn func(backlog int)) {
+	c.mu.Lock()
+	defer c.unlock()
+
+	c.backlog = backlogWatch{threshold: threshold, fn: fn}
+}
+
+// checkBacklogLocked counts the expired items at now and reports them with reportBacklogLocked
+// if a backlog callback is registered. The caller must hold the write lock.
+func (c *inMemoryCache) checkBacklogLocked(now time.Time) {
+	if c.backlog.fn == nil {
+		return
+	}
+
+	c.reportBacklogLocked(c.countExpiredLocked(now))
+}
+
+// reportBacklogLocked records the backlog counted by a cleanup pass for Stats and, if it exceeds the registered
+// threshold, queues the backlog callback to run after the write lock is released. The caller must hold the write lock.
+func (c *inMemoryCache) reportBacklogLocked(backlog int) {
+	c.lastBacklog = backlog
+	if c.backlog.fn == nil || backlog <= c.backlog.threshold {
+		return
+	}
+
+	fn := c.backlog.fn
+	c.expireCallbacks = append(c.expireCallbacks, func() { fn(backlog) })
+}
+
+// countExpiredLocked returns the number of stored items that are expired at now, including the items
+// invalidated by InvalidateAll. The caller must hold the read or write lock.
+func (c *inMemoryCache) countExpiredLocked(now time.Time) int {
+	count := 0
+	for _, item := range c.items {
+		if c.isExpiredAt(item, now) {
+			count++
+		}
+	}
+
+	return count
+}
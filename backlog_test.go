@@ -0,0 +1,87 @@
+package cache
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestOnBacklog(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCacheWithConfig(CacheConfig{Clock: clock})
+	var reported []int
+	c.(BacklogNotifier).OnBacklog(3, func(backlog int) { reported = append(reported, backlog) })
+
+	for i := range 5 {
+		c.SetWithTTL(fmt.Sprintf("expired:%d", i), i, time.Second)
+	}
+	c.Set("live", 1)
+	clock.Advance(time.Minute)
+
+	if removed := c.(Cleanable).RemoveExpired(); removed != 5 {
+		t.Fatalf("RemoveExpired() = %d, want 5", removed)
+	}
+	if !slices.Equal(reported, []int{5}) {
+		t.Fatalf("backlog callback calls = %v, want [5]", reported)
+	}
+	if backlog := c.(StatsReporter).Stats().Backlog; backlog != 5 {
+		t.Fatalf("Stats().Backlog = %d, want the backlog the last pass started with", backlog)
+	}
+
+	// A backlog at the threshold does not fire the callback.
+	for i := range 3 {
+		c.SetWithTTL(fmt.Sprintf("expired:%d", i), i, time.Second)
+	}
+	clock.Advance(time.Minute)
+	c.(Cleanable).RemoveExpired()
+	if len(reported) != 1 {
+		t.Fatalf("backlog callback calls = %v, want none for a backlog of 3 at threshold 3", reported)
+	}
+	if backlog := c.(StatsReporter).Stats().Backlog; backlog != 3 {
+		t.Fatalf("Stats().Backlog = %d, want 3", backlog)
+	}
+
+	c.(BacklogNotifier).OnBacklog(0, nil)
+	for i := range 5 {
+		c.SetWithTTL(fmt.Sprintf("expired:%d", i), i, time.Second)
+	}
+	clock.Advance(time.Minute)
+	c.(Cleanable).RemoveExpired()
+	if len(reported) != 1 {
+		t.Fatalf("backlog callback calls = %v after removing the callback, want no more", reported)
+	}
+}
+
+func TestBacklogCallbackFiresForSlowWorker(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCacheWithConfig(CacheConfig{Clock: clock})
+	fired := make(chan int, 1)
+	c.(BacklogNotifier).OnBacklog(50, func(backlog int) {
+		select {
+		case fired <- backlog:
+		default:
+		}
+	})
+
+	// The worker deletes a single item per cycle, far fewer than expire between two cycles.
+	startWorker(t, CacheWorkerConfig{Cache: c, Interval: time.Millisecond, MaxDeletionsPerCycle: 1, Logger: discardLogger()})
+	for i := range 1000 {
+		c.SetWithTTL(fmt.Sprintf("key:%d", i), i, time.Millisecond)
+		if i%10 == 9 {
+			clock.Advance(time.Second)
+		}
+	}
+
+	select {
+	case backlog := <-fired:
+		if backlog <= 50 {
+			t.Fatalf("backlog callback called with %d, want more than the threshold", backlog)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("backlog callback not called while expired items piled up")
+	}
+	if backlog := c.(StatsReporter).Stats().Backlog; backlog <= 50 {
+		t.Fatalf("Stats().Backlog = %d, want the growing backlog reported", backlog)
+	}
+}
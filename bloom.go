@@ -0,0 +1,72 @@
+package cache
+
+import (
+	"hash/maphash"
+	"sync/atomic"
+)
+
+const (
+	// bloomBitsPerKey and bloomHashes give a false positive rate of about 1% at the expected number of keys.
+	bloomBitsPerKey = 10
+	bloomHashes     = 7
+)
+
+// bloomFilter records keys in a bit array so that keys that were never added can be ruled out
+// without a map lookup. It may report keys that were not added (false positives) but never misses one.
+// Bits are read and set atomically, so lookups do not need the cache's lock.
+type bloomFilter struct {
+	bits []atomic.Uint64
+	seed maphash.Seed
+}
+
+// newBloomFilter creates a filter sized for expectedKeys keys.
+func newBloomFilter(expectedKeys int) *bloomFilter {
+	words := (max(expectedKeys, 1)*bloomBitsPerKey + 63) / 64
+
+	return &bloomFilter{
+		bits: make([]atomic.Uint64, words),
+		seed: maphash.MakeSeed(),
+	}
+}
+
+// add records key in the filter.
+func (f *bloomFilter) add(key string) {
+	h1, h2 := f.hash(key)
+	for i := range uint64(bloomHashes) {
+		bit := (h1 + i*h2) % f.size()
+		f.bits[bit/64].Or(1 << (bit % 64))
+	}
+}
+
+// mayContain reports whether key may have been added. False means key was definitely never added.
+func (f *bloomFilter) mayContain(key string) bool {
+	h1, h2 := f.hash(key)
+	for i := range uint64(bloomHashes) {
+		bit := (h1 + i*h2) % f.size()
+		if f.bits[bit/64].Load()&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// reset removes all keys from the filter.
+func (f *bloomFilter) reset() {
+	for i := range f.bits {
+		f.bits[i].Store(0)
+	}
+}
+
+// size returns the number of bits in the filter.
+func (f *bloomFilter) size() uint64 {
+	return uint64(len(f.bits)) * 64
+}
+
+// hash returns the two halves of the key's hash, which are combined to derive the bit positions.
+// The second half is made odd so that the positions do not repeat.
+func (f *bloomFilter) hash(key string) (uint64, uint64) {
+	h := maphash.String(f.seed, key)
+
+	return h >> 32, h&0xffffffff | 1
+}
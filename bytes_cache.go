@@ -0,0 +1,180 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// BytesCache is a cache specialized for []byte values.
+// It stores and returns slices without converting them to any, so reads do not allocate.
+type BytesCache interface {
+	// Get retrieves the value for the specified key.
+	// Returns (nil, false) if the key does not exist or if the item is expired.
+	// The returned slice is shared with the cache and must not be modified.
+	Get(key string) ([]byte, bool)
+	// Set assigns a value to the specified key with a TTL, evicting the least recently used item if the cache is full.
+	// If ttl <= 0, the item does not expire. The cache keeps val, so it must not be modified afterwards.
+	Set(key string, val []byte, ttl time.Duration)
+	// Delete removes the item associated with the specified key.
+	Delete(key string)
+	// Len returns the number of items in the cache, including expired items that have not been removed yet.
+	Len() int
+}
+
+// bytesNode is an item of a bytesCache, linked into its recency list.
+type bytesNode struct {
+	key        string
+	value      []byte
+	expiration time.Time
+	prev, next *bytesNode
+}
+
+// isExpired reports whether the node has expired at now.
+func (n *bytesNode) isExpired(now time.Time) bool {
+	return !n.expiration.IsZero() && now.After(n.expiration)
+}
+
+// bytesCache implements BytesCache with a map and an intrusive doubly linked list
+// ordered from most to least recently used.
+type bytesCache struct {
+	mu       sync.Mutex
+	items    map[string]*bytesNode
+	capacity int
+	// head and tail are the most and least recently used nodes.
+	head, tail *bytesNode
+}
+
+// NewBytesCache creates a new []byte cache that holds at most capacity items, evicting the least recently used.
+// If capacity <= 0, the cache is unbounded.
+func NewBytesCache(capacity int) BytesCache {
+	return &bytesCache{
+		items:    make(map[string]*bytesNode, max(capacity, 0)),
+		capacity: capacity,
+	}
+}
+
+// Get retrieves the value for the specified key and marks it as recently used.
+// If the item is expired, it is removed and (nil, false) is returned.
+func (c *bytesCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	node, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	if node.isExpired(time.Now()) {
+		c.removeLocked(node)
+		return nil, false
+	}
+
+	c.moveToFrontLocked(node)
+
+	return node.value, true
+}
+
+// Set assigns a value to the specified key with a TTL.
+// If ttl <= 0, the item does not expire.
+func (c *bytesCache) Set(key string, val []byte, ttl time.Duration) {
+	var expiration time.Time
+	if ttl > 0 {
+		expiration = time.Now().Add(ttl)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if node, ok := c.items[key]; ok {
+		node.value = val
+		node.expiration = expiration
+		c.moveToFrontLocked(node)
+		return
+	}
+
+	node := &bytesNode{key: key, value: val, expiration: expiration}
+	c.items[key] = node
+	c.pushFrontLocked(node)
+
+	if c.capacity > 0 && len(c.items) > c.capacity {
+		c.removeLocked(c.tail)
+	}
+}
+
+// Delete removes the item associated with the specified key.
+func (c *bytesCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if node, ok := c.items[key]; ok {
+		c.removeLocked(node)
+	}
+}
+
+// Len returns the number of items in the cache.
+func (c *bytesCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.items)
+}
+
+// RemoveExpired deletes all expired items and returns the number of items removed.
+func (c *bytesCache) RemoveExpired() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	removed := 0
+	for _, node := range c.items {
+		if node.isExpired(now) {
+			c.removeLocked(node)
+			removed++
+		}
+	}
+
+	return removed
+}
+
+// pushFrontLocked links node at the front of the list. The caller must hold the lock.
+func (c *bytesCache) pushFrontLocked(node *bytesNode) {
+	node.prev = nil
+	node.next = c.head
+	if c.head != nil {
+		c.head.prev = node
+	}
+	c.head = node
+	if c.tail == nil {
+		c.tail = node
+	}
+}
+
+// unlinkLocked removes node from the list. The caller must hold the lock.
+func (c *bytesCache) unlinkLocked(node *bytesNode) {
+	if node.prev != nil {
+		node.prev.next = node.next
+	} else {
+		c.head = node.next
+	}
+	if node.next != nil {
+		node.next.prev = node.prev
+	} else {
+		c.tail = node.prev
+	}
+	node.prev, node.next = nil, nil
+}
+
+// moveToFrontLocked marks node as the most recently used. The caller must hold the lock.
+func (c *bytesCache) moveToFrontLocked(node *bytesNode) {
+	if c.head == node {
+		return
+	}
+	c.unlinkLocked(node)
+	c.pushFrontLocked(node)
+}
+
+// removeLocked unlinks node and deletes it from the map. The caller must hold the lock.
+func (c *bytesCache) removeLocked(node *bytesNode) {
+	c.unlinkLocked(node)
+	delete(c.items, node.key)
+}
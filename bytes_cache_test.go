@@ -0,0 +1,125 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBytesCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := NewBytesCache(2)
+	c.Set("a", []byte("1"), 0)
+	c.Set("b", []byte("2"), 0)
+	c.Get("a")
+	c.Set("c", []byte("3"), 0)
+
+	if _, ok := c.Get("b"); ok {
+		t.Fatal("least recently used key b was not evicted")
+	}
+	for key, want := range map[string]string{"a": "1", "c": "3"} {
+		if value, ok := c.Get(key); !ok || string(value) != want {
+			t.Fatalf("Get(%s) = %q, %v, want %q, true", key, value, ok, want)
+		}
+	}
+	if n := c.Len(); n != 2 {
+		t.Fatalf("Len() = %d, want 2", n)
+	}
+
+	c.Delete("a")
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("Get(a) ok = true after Delete")
+	}
+}
+
+func TestBytesCacheTTL(t *testing.T) {
+	c := NewBytesCache(0)
+	c.Set("short", []byte("1"), time.Millisecond)
+	c.Set("long", []byte("2"), time.Hour)
+	c.Set("forever", []byte("3"), 0)
+	time.Sleep(5 * time.Millisecond)
+
+	if _, ok := c.Get("short"); ok {
+		t.Fatal("Get(short) ok = true after its TTL")
+	}
+	for _, key := range []string{"long", "forever"} {
+		if _, ok := c.Get(key); !ok {
+			t.Fatalf("Get(%s) ok = false before its TTL", key)
+		}
+	}
+	if n := c.Len(); n != 2 {
+		t.Fatalf("Len() = %d, want the expired item removed", n)
+	}
+}
+
+func TestBytesCacheGetDoesNotAllocate(t *testing.T) {
+	c := NewBytesCache(10)
+	c.Set("a", []byte("value"), time.Hour)
+
+	if allocs := testing.AllocsPerRun(100, func() { c.Get("a") }); allocs != 0 {
+		t.Fatalf("Get() made %.0f allocations, want 0", allocs)
+	}
+}
+
+// BenchmarkBytesGet compares reading a []byte from a BytesCache and from the generic cache.
+func BenchmarkBytesGet(b *testing.B) {
+	keys := benchKeys(1000)
+	value := []byte("value")
+
+	b.Run("BytesCache", func(b *testing.B) {
+		c := NewBytesCache(len(keys))
+		for _, key := range keys {
+			c.Set(key, value, time.Hour)
+		}
+		b.ReportAllocs()
+		i := 0
+		for b.Loop() {
+			v, _ := c.Get(keys[i%len(keys)])
+			_ = v
+			i++
+		}
+	})
+	b.Run("AnyCache", func(b *testing.B) {
+		c := NewBoundedCache(len(keys), nil)
+		for _, key := range keys {
+			c.SetWithTTL(key, value, time.Hour)
+		}
+		b.ReportAllocs()
+		i := 0
+		for b.Loop() {
+			v, _ := c.Get(keys[i%len(keys)])
+			_ = v.([]byte)
+			i++
+		}
+	})
+}
+
+// BenchmarkBytesSet compares the allocations of overwriting a []byte in a BytesCache and in the generic cache,
+// which boxes the slice into an any on every Set.
+func BenchmarkBytesSet(b *testing.B) {
+	keys := benchKeys(1000)
+	value := []byte("value")
+
+	b.Run("BytesCache", func(b *testing.B) {
+		c := NewBytesCache(len(keys))
+		for _, key := range keys {
+			c.Set(key, value, time.Hour)
+		}
+		b.ReportAllocs()
+		i := 0
+		for b.Loop() {
+			c.Set(keys[i%len(keys)], value, time.Hour)
+			i++
+		}
+	})
+	b.Run("AnyCache", func(b *testing.B) {
+		c := NewBoundedCache(len(keys), nil)
+		for _, key := range keys {
+			c.SetWithTTL(key, value, time.Hour)
+		}
+		b.ReportAllocs()
+		i := 0
+		for b.Loop() {
+			c.SetWithTTL(keys[i%len(keys)], value, time.Hour)
+			i++
+		}
+	})
+}
@@ -1,21 +1,414 @@
 package cache
 
 import (
+	"context"
+	"io"
 	"time"
 )
 
 // Cache defines the interface for the cache.
+//
+// Operations beyond these are grouped into optional interfaces that embed Cache, such as AtomicCache and TTLCache.
+// The in-memory caches implement all of them; type-assert a Cache to the interface whose operations you need.
 type Cache interface {
-	// Set assigns a value to the specified key without expiration.
+	// Set assigns a value to the specified key with the default TTL configured for its prefix, if any,
+	// and otherwise without expiration.
 	Set(key string, value any)
 	// SetWithTTL assigns a value to the specified key with a given time-to-live (TTL).
 	// If ttl <= 0, the item does not expire.
 	SetWithTTL(key string, value any, ttl time.Duration)
 	// Get retrieves the value for the specified key.
 	// Returns (nil, false) if the key does not exist or if the item is expired.
+	// A stored nil value is returned as (nil, true); use LookupCache.Has to check for presence.
 	Get(key string) (any, bool)
 	// Delete removes the item associated with the specified key.
 	Delete(key string)
 	// Clear removes all items from the cache.
 	Clear()
 }
+
+// CheckedCache is a Cache that reports why a value could not be stored.
+type CheckedCache interface {
+	Cache
+
+	// SetChecked is like SetWithTTL but returns ErrEmptyKey for an empty key
+	// and ErrNilValue for a nil value if the cache is configured with RejectNilValues.
+	// A value larger than the configured MaxValueSize returns ErrValueTooLarge.
+	// A full bounded cache that cannot evict anything returns ErrCacheFull for a new key.
+	// A full cache with TinyLFU admission returns ErrNotAdmitted for a new key it rejects.
+	SetChecked(key string, value any, ttl time.Duration) error
+}
+
+// LookupCache is a Cache with reads that go beyond Get: presence checks, non-blocking and copying reads,
+// and numeric conversions.
+type LookupCache interface {
+	Cache
+
+	// Has reports whether a live item is stored under the specified key, regardless of its value.
+	Has(key string) bool
+	// TryGet is like Get but does not wait for the cache lock: if the lock is contended, it returns
+	// immediately with acquired=false so the caller can fall through to the source.
+	TryGet(key string) (value any, ok bool, acquired bool)
+	// GetCopy is like Get but returns a deep copy of the value that can be mutated safely,
+	// while other reads keep sharing the stored value.
+	GetCopy(key string) (any, bool)
+	// GetInt64 retrieves the integer stored under key, of any integer kind, converted to an int64.
+	// Returns (0, false) if the key is missing or expired, the value is not an integer, or it does not fit in an int64.
+	GetInt64(key string) (int64, bool)
+	// GetFloat64 retrieves the number stored under key, of any integer or floating-point kind, converted to a float64.
+	// Returns (0, false) if the key is missing or expired or the value is not a number.
+	GetFloat64(key string) (float64, bool)
+}
+
+// ComputeCache is a Cache that can compute missing values itself, on a miss or on the first read.
+type ComputeCache interface {
+	Cache
+
+	// GetOrComputeContext returns the value for the specified key, computing and storing it with fn on a miss.
+	// Concurrent misses for the same key share a single fn call; a waiting caller returns ctx.Err()
+	// when its own context is done, while the running call continues and stores its result.
+	GetOrComputeContext(ctx context.Context, key string, fn func(ctx context.Context) (any, time.Duration, error)) (any, error)
+	// SetLazy stores an item under key with a TTL whose value is computed by fn on the first Get.
+	// Concurrent first reads share a single call of fn. Errors are not cached, so the next Get calls fn again.
+	SetLazy(key string, fn func() (any, error), ttl time.Duration)
+}
+
+// SourceCache is a Cache that reports where the value returned by a read came from.
+// Read-through caches and chains implement it too.
+type SourceCache interface {
+	Cache
+
+	// GetWithSource is like Get but also reports where the value came from.
+	GetWithSource(key string) (value any, source Source, ok bool)
+}
+
+// TTLCache is a Cache with operations that inspect and change the expiration of its items.
+type TTLCache interface {
+	Cache
+
+	// GetTTL returns the remaining time-to-live for the specified key.
+	// If the item does not expire, the returned TTL is 0.
+	// Returns (0, false) if the key does not exist or if the item is expired.
+	GetTTL(key string) (time.Duration, bool)
+	// GetIfFresh is like Get but returns the value only if its remaining TTL exceeds minRemaining.
+	// Items that do not expire are always fresh. Returns (nil, false) otherwise.
+	GetIfFresh(key string, minRemaining time.Duration) (any, bool)
+	// GetAndRefresh retrieves the value for the specified key and atomically resets its TTL.
+	// The new expiration is now+ttl for the ttl given by each caller, not the TTL the item was stored with.
+	// If ttl <= 0, the item no longer expires.
+	// Returns (nil, false) if the key does not exist or if the item is expired.
+	GetAndRefresh(key string, ttl time.Duration) (any, bool)
+	// GetWithRefreshTTL is GetAndRefresh, named for call sites that extend a hit by their own TTL.
+	GetWithRefreshTTL(key string, ttl time.Duration) (any, bool)
+	// SetTTLByPrefix updates the TTL of all live items whose key starts with prefix.
+	// If ttl <= 0, the matching items no longer expire. Returns the number of items updated.
+	SetTTLByPrefix(prefix string, ttl time.Duration) int
+	// SetWithExpireCallback assigns a value to the specified key with a TTL and calls onExpire with the value
+	// when the item expires and is removed. The callback does not run on Delete, Clear, or eviction.
+	SetWithExpireCallback(key string, value any, ttl time.Duration, onExpire func(value any))
+	// SetWithTier assigns a value to the specified key with a TTL and tags it with a cleanup tier.
+	// A cache worker configured with that tier cleans the item on its own schedule.
+	SetWithTier(key string, value any, ttl time.Duration, tier string)
+	// SetMultiTTL atomically stores each value in values with the TTL of its key in ttls.
+	// Keys missing from ttls do not expire.
+	SetMultiTTL(values map[string]any, ttls map[string]time.Duration)
+	// ExpiringWithin returns the keys of the live items whose remaining TTL is less than d, for refreshing them ahead of time.
+	// Items without expiration are not included.
+	ExpiringWithin(d time.Duration) []string
+	// RemoveExpiredBefore deletes all items that expired before t and returns the number of items removed.
+	// Items that expired at or after t are kept.
+	RemoveExpiredBefore(t time.Time) int
+	// SuspendExpiration makes reads and cache workers treat all items as live, ignoring their TTLs,
+	// until ResumeExpiration is called. Items whose TTL elapsed in the meantime then expire right away.
+	SuspendExpiration()
+	// ResumeExpiration ends a suspension started by SuspendExpiration.
+	ResumeExpiration()
+}
+
+// EvictionCache is a Cache whose items can be given an eviction priority or cost, or be pinned,
+// to control what a bounded cache evicts first.
+type EvictionCache interface {
+	Cache
+
+	// SetWithPriority assigns a value to the specified key with a TTL and an eviction priority.
+	// Bounded caches evict lower priorities first. Set and SetWithTTL use priority 0.
+	SetWithPriority(key string, value any, ttl time.Duration, priority int)
+	// SetWithCost assigns a value to the specified key with a TTL and the cost of reloading it.
+	// Cost-aware eviction policies evict items that are cheap to reload first.
+	SetWithCost(key string, value any, ttl time.Duration, cost float64)
+	// Pin protects the live item stored under key from eviction; it still expires. Returns false if the key is absent.
+	Pin(key string) bool
+	// Unpin makes a pinned item evictable again. Returns false if the key is absent.
+	Unpin(key string) bool
+}
+
+// LabelCache is a Cache whose items can carry string labels for querying.
+type LabelCache interface {
+	Cache
+
+	// SetWithLabels assigns a value to the specified key with a TTL and attaches string labels to it,
+	// such as source=db, for querying with LabelsOf and CountByLabel. Overwriting the key with another setter drops the labels.
+	SetWithLabels(key string, value any, ttl time.Duration, labels map[string]string)
+	// LabelsOf returns a copy of the labels attached to the live item stored under key.
+	// Returns (nil, false) if the key does not exist or if the item is expired.
+	LabelsOf(key string) (map[string]string, bool)
+	// CountByLabel returns the number of live items whose label name is set to value.
+	CountByLabel(name, value string) int
+}
+
+// GroupCache is a Cache that stores related fields together under one key and one TTL.
+type GroupCache interface {
+	Cache
+
+	// SetGroup stores fields together under groupKey with a single TTL, so they always expire together.
+	// If ttl <= 0, the group does not expire.
+	SetGroup(groupKey string, fields map[string]any, ttl time.Duration)
+	// GetGroup returns all the fields stored with SetGroup under groupKey.
+	// Returns (nil, false) if the group does not exist, is expired, or the key holds a value not stored by SetGroup.
+	GetGroup(groupKey string) (map[string]any, bool)
+}
+
+// InspectableCache is a Cache whose live items can be listed, ranked, and counted.
+type InspectableCache interface {
+	Cache
+
+	// Entries returns a snapshot of all live items in the cache, in no particular order.
+	Entries() []Entry
+	// KeysChan streams the keys of all live items until they are exhausted or ctx is done, then closes the channel.
+	// Keys are copied in chunks under the read lock and sent without it, so the stream does not block writers.
+	KeysChan(ctx context.Context) <-chan string
+	// TopN returns the n live items with the most hits, sorted from the most hit.
+	TopN(n int) []ItemStats
+	// Oldest returns the key and value of the live item that was set the longest time ago.
+	// Returns ok=false if there are no live items.
+	Oldest() (key string, value any, ok bool)
+	// Newest returns the key and value of the live item that was set most recently.
+	// Returns ok=false if there are no live items.
+	Newest() (key string, value any, ok bool)
+	// CountFunc returns the number of live items for which predicate returns true.
+	// The predicate runs under the cache's read lock and must not call back into the cache.
+	CountFunc(predicate func(key string, value any) bool) int
+	// NamespaceBytes returns the total size of the live items whose key starts with prefix, as measured by the
+	// cache's Sizer, for per-namespace memory accounting such as tenant quotas.
+	NamespaceBytes(prefix string) int64
+	// Snapshot returns the values of all live items in the cache keyed by their keys. Compare two snapshots with Diff.
+	Snapshot() map[string]any
+	// ExportCSV writes a "key,value,expires_at" header and one row per live item to w, sorted by key.
+	// Values are rendered with fmt.Sprint and expirations in RFC 3339, or as "never".
+	ExportCSV(w io.Writer) error
+}
+
+// StoredKeyCache is a Cache whose items can be addressed by the keys it stores, such as those reported by Entries
+// and Snapshot, which differ from the keys callers pass in when the cache is configured with KeyFunc or HashKeys.
+// Stored keys are used as given, without applying KeyFunc or hashing them again.
+type StoredKeyCache interface {
+	Cache
+
+	// GetStored is like Get for a key as stored.
+	GetStored(storedKey string) (any, bool)
+	// SetStored is like SetWithTTL for a key as stored.
+	SetStored(storedKey string, value any, ttl time.Duration)
+	// DeleteStored is like Delete for a key as stored.
+	DeleteStored(storedKey string)
+}
+
+// BulkCache is a Cache with operations that read or remove many items under a single lock.
+type BulkCache interface {
+	Cache
+
+	// Migrate atomically stores transform(old value) under newKey with the returned TTL and deletes oldKey.
+	// Returns false if oldKey does not exist or is expired, or if the cache rejects the new item; oldKey is then kept.
+	Migrate(oldKey, newKey string, transform func(old any) (new any, ttl time.Duration)) bool
+	// DeleteMulti atomically removes the items associated with keys and returns the keys, as passed in,
+	// that were present and not expired.
+	DeleteMulti(keys []string) (deleted []string)
+	// Transaction runs fn while holding the cache's write lock, so the operations made through tx are atomic.
+	// fn must only use tx; calling the cache's own methods inside fn deadlocks.
+	Transaction(fn func(tx Tx))
+	// InvalidateAll makes all stored items absent in constant time, without blocking writers.
+	// The invalidated items are reclaimed later by reads, writes, and the cache worker.
+	InvalidateAll()
+	// ClearAndReturnKeys atomically removes all items from the cache and returns the keys that were live.
+	ClearAndReturnKeys() []string
+	// Drain atomically removes all items from the cache and returns the live ones as a map of keys to values.
+	Drain() map[string]any
+	// Compact rebuilds the internal storage from the live items to release memory held after large deletions.
+	// Expired items are removed in the process.
+	Compact()
+}
+
+// SnapshotCache is a Cache that can stream its live items to a writer and restore them from a reader.
+type SnapshotCache interface {
+	Cache
+
+	// WriteSnapshot streams all live items to w, leaving out SetLazy values not computed yet.
+	// Values of custom types must be registered with gob.Register.
+	WriteSnapshot(w io.Writer) error
+	// ReadSnapshot stores the items streamed by WriteSnapshot from r, skipping items that have expired.
+	// It returns ErrSnapshotVersion if r was written in a different snapshot format version.
+	ReadSnapshot(r io.Reader) error
+	// ReadSnapshotWithConfig is like ReadSnapshot but can recompute expirations from the TTLs the entries had left
+	// when they were written, so that clock skew between the writing and reading machines does not matter.
+	ReadSnapshotWithConfig(r io.Reader, cfg SnapshotReadConfig) error
+}
+
+// WatchableCache is a Cache that publishes the changes made to a key.
+type WatchableCache interface {
+	Cache
+
+	// Watch subscribes to set, delete, expire, and evict events for a single key.
+	// Events are dropped if the channel's buffer is full. The returned function unsubscribes and closes the channel.
+	Watch(key string) (<-chan CacheEvent, func())
+}
+
+// StatsReporter is implemented by caches that keep usage statistics.
+// A cache worker configured with StatsEveryN reports them for any Cache that also implements StatsReporter.
+type StatsReporter interface {
+	// Stats returns the current size of the cache, its hit and miss counters, and how long expired items lingered.
+	Stats() Stats
+}
+
+// HealthReporter is implemented by caches that can summarize their state for health checks.
+type HealthReporter interface {
+	// Health returns a summary of the cache state for health checks, including the activity of its cache workers.
+	Health() HealthStatus
+}
+
+// BacklogNotifier is implemented by caches that can report a growing backlog of expired items.
+type BacklogNotifier interface {
+	// OnBacklog registers fn to be called when a cleanup pass starts with more than threshold expired items
+	// still stored, a sign that the cache worker cannot keep up. A nil fn removes the callback.
+	OnBacklog(threshold int, fn func(backlog int))
+}
+
+// Source describes where a value returned by GetWithSource came from.
+type Source int
+
+const (
+	// SourceNone means no value was found.
+	SourceNone Source = iota
+	// SourceHit means the value was already stored in the cache.
+	SourceHit
+	// SourceLoaded means the value was missing and was loaded by a read-through loader.
+	SourceLoaded
+)
+
+// String returns the name of the source.
+func (s Source) String() string {
+	switch s {
+	case SourceNone:
+		return "none"
+	case SourceHit:
+		return "hit"
+	case SourceLoaded:
+		return "loaded"
+	default:
+		return "unknown"
+	}
+}
+
+// Entry is a snapshot of a single cached item.
+type Entry struct {
+	Key       string
+	Value     any
+	ExpiresAt time.Time // Zero if the item does not expire.
+}
+
+// ItemStats is a live item together with the number of reads that found it.
+type ItemStats struct {
+	Key       string
+	Value     any
+	Hits      uint64    // Reads that found the item since its key was stored, including overwrites.
+	ExpiresAt time.Time // Zero if the item does not expire.
+}
+
+// Stats holds cache usage statistics.
+type Stats struct {
+	Size   int    // Number of items currently stored, including expired items not yet removed.
+	Hits   uint64 // Number of reads that found a live item.
+	Misses uint64 // Number of reads that found no item or an expired item.
+	// Bytes is the total size of the stored items as measured by the cache's sizer.
+	Bytes int64
+	// Expired is the number of expired items removed, by a cache worker or lazily by the operation that found them.
+	Expired uint64
+	// ExpiryLagAvg and ExpiryLagMax are the average and longest time expired items stayed stored past their
+	// expiration before being removed, which shows whether the worker interval is tight enough.
+	ExpiryLagAvg time.Duration
+	ExpiryLagMax time.Duration
+	// ExpiredBytes is the total size of the expired items removed.
+	ExpiredBytes int64
+	// Backlog is the number of expired items that were still stored, waiting for a cache worker or a read to remove
+	// them, when the last cleanup pass that counted them started. Passes that visit every item always count them;
+	// batched and tier passes only while an OnBacklog callback is registered. If it keeps growing, the worker
+	// cannot keep up.
+	Backlog int
+}
+
+// Cleanable is implemented by caches that can remove their own expired items.
+// The cache worker cleans any Cache that also implements Cleanable.
+type Cleanable interface {
+	// RemoveExpired deletes all expired items and returns the number of items removed.
+	RemoveExpired() int
+}
+
+// KeyCleanable is implemented by caches that can report the keys of the expired items they remove.
+// A cache worker configured with LogKeys uses it instead of Cleanable.
+type KeyCleanable interface {
+	// RemoveExpiredKeys deletes all expired items and returns their keys.
+	RemoveExpiredKeys() []string
+}
+
+// SizedCleanable is implemented by caches that can report the size of the expired items they remove.
+// The cache worker prefers it over Cleanable, so that it can report the bytes freed by each cleanup cycle.
+type SizedCleanable interface {
+	// RemoveExpiredSized deletes all expired items and returns the number of items removed and their total size.
+	RemoveExpiredSized() (removed int, size int64)
+}
+
+// BatchCleanable is implemented by caches that can remove a bounded number of expired items at a time,
+// so that a cache worker configured with MaxDeletionsPerCycle holds the cache's lock for a bounded time.
+type BatchCleanable interface {
+	// RemoveExpiredN deletes at most n expired items and returns the number of items removed and their total size.
+	RemoveExpiredN(n int) (removed int, size int64)
+}
+
+// Evictable is implemented by caches that can evict items on demand, in the order of their eviction policy.
+// A cache worker configured with MaxHeapFraction uses it to relieve memory pressure.
+type Evictable interface {
+	// EvictN evicts at most n items and returns the number of items evicted.
+	EvictN(n int) (evicted int)
+	// Len returns the number of items stored, which the worker evicts a share of.
+	Len() int
+}
+
+// HookCleanable is implemented by caches that let a cache worker configured with OnExpire inspect
+// expired items before removing them.
+type HookCleanable interface {
+	// RemoveExpiredFunc calls fn with the key and value of every expired item, without holding the cache's lock,
+	// then removes those items and returns the number of items removed and their total size.
+	RemoveExpiredFunc(fn func(key string, value any)) (removed int, size int64)
+}
+
+// TierCleanable is implemented by caches that can remove the expired items of a single tier.
+// A cache worker configured with a tier uses it instead of Cleanable.
+type TierCleanable interface {
+	// RemoveExpiredInTier deletes the expired items tagged with tier and returns the number of items removed.
+	RemoveExpiredInTier(tier string) int
+}
+
+// MembershipFilter is implemented by caches that can rule out keys that were never stored without a lookup.
+// A read-through cache configured with UseMembershipFilter does not call its loader for keys its wrapped
+// cache rules out.
+type MembershipFilter interface {
+	// MayContain reports whether key may be stored. False means key is definitely not stored.
+	MayContain(key string) bool
+	// AddToFilter records keys as possibly stored without storing them, so that they are no longer ruled out.
+	AddToFilter(keys ...string)
+}
+
+// Verifier is implemented by caches that can check their internal indexes for consistency.
+// It is intended for tests and debugging.
+type Verifier interface {
+	// Verify returns a descriptive error if the cache's indexes disagree with its stored items.
+	Verify() error
+}
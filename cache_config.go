@@ -0,0 +1,194 @@
+package cache
+
+import (
+	"cmp"
+	"log"
+	"reflect"
+	"runtime"
+	"slices"
+	"time"
+)
+
+// CacheConfig holds the configuration for creating an in-memory cache.
+// The zero value is a valid configuration for an unbounded cache that stores values by reference.
+type CacheConfig struct {
+	// InitialSize pre-allocates room for approximately this many items. It is also used when the cache is cleared.
+	InitialSize int
+	// Capacity limits the number of items. If <= 0, the cache is unbounded.
+	Capacity int
+	// Policy chooses eviction victims. If nil and Capacity > 0, least recently used items are evicted.
+	Policy EvictionPolicy
+	// FullBehavior decides what happens when the cache is full and Policy offers no item to evict.
+	// The zero value is FullReject.
+	FullBehavior FullBehavior
+	// EvictBatch is the number of items evicted at once when Capacity is exceeded, so that a cache under
+	// steady overflow does not evict on every insert. Values <= 1 evict one item at a time.
+	// It is capped at Capacity.
+	EvictBatch int
+	// TinyLFUAdmission makes a full cache admit a new key only if it was accessed more often recently than
+	// the item Policy would evict for it, so that keys read once, such as those of a scan, do not push out
+	// frequently used items. Accesses by Get and the setters are counted in a count-min sketch whose counts
+	// are halved periodically. Rejected keys are not stored and SetChecked returns ErrNotAdmitted.
+	// It requires Capacity > 0.
+	TinyLFUAdmission bool
+	// KeyFunc, if set, normalizes every key passed to the cache's methods, for example by lowercasing it.
+	// It must be idempotent.
+	KeyFunc func(string) string
+	// HashKeys makes the cache store a fixed-size 128-bit FNV-1a hash of every key, applied after KeyFunc,
+	// instead of the key itself, which saves memory when keys are long, such as full URLs. Original keys are
+	// not kept, so collisions are not detected: two keys with the same hash address the same item. FNV-1a is
+	// not a cryptographic hash, so callers who control keys can craft colliding ones; do not enable HashKeys
+	// for keys chosen by untrusted clients if their entries must stay apart. Methods that report keys, such as
+	// Entries and Snapshot, report the hashes, and SetTTLByPrefix and NamespaceBytes match no keys. PrefixTTLs
+	// still apply. Every key passed in is hashed, so reported keys must be passed back through StoredKeyCache.
+	HashKeys bool
+	// BloomFilterKeys, if positive, sizes a bloom filter for about this many distinct keys. The filter records
+	// every stored key, and any key passed to AddToFilter, so that Get on a key that was never stored returns
+	// without a map lookup. A read-through cache configured with UseMembershipFilter also skips its loader for
+	// such keys. The filter is reset by Clear but not by Delete, and its false positive rate grows once more
+	// distinct keys than configured have been recorded.
+	BloomFilterKeys int
+	// Sizer, if set, measures stored values for memory accounting and cost-aware eviction policies.
+	// If nil, strings and byte slices are measured by their length and other values count as 1.
+	// A SetLazy value is measured as nil until the read that computes it measures the computed value.
+	Sizer func(value any) int
+	// MaxValueSize, if positive, is the largest value size, as measured by Sizer, the cache stores. SetChecked
+	// returns an error wrapping ErrValueTooLarge for a larger value, and other setters drop it, leaving any item
+	// already stored under the key in place.
+	MaxValueSize int
+	// CopyFunc, if set, is applied to values when they are stored and when they are read.
+	CopyFunc func(any) any
+	// GetCopyFunc, if set, replaces DeepCopy as the copy GetCopy returns, without copying the values of other reads.
+	GetCopyFunc func(any) any
+	// EqualFunc, if set, decides whether a stored value equals the one given to CompareAndSwap or
+	// CompareAndDelete. If nil, reflect.DeepEqual is used.
+	EqualFunc func(a, b any) bool
+	// CopyByteValues copies []byte values when they are stored and when they are read,
+	// leaving other types shared. It is ignored if CopyFunc is set.
+	CopyByteValues bool
+	// EmptyValueDeletes makes setting an empty string delete the key instead of storing it.
+	// By default an empty string is stored like any other value and Get returns ("", true).
+	EmptyValueDeletes bool
+	// RejectNilValues makes SetChecked refuse nil values with ErrNilValue, since Get returns (nil, true)
+	// for a stored nil, which careless callers can mistake for a miss. Other setters still store nil.
+	RejectNilValues bool
+	// PrefixTTLs maps key prefixes to the TTL that Set gives keys starting with them, so callers do not have to
+	// pass a TTL at every call site. The longest matching prefix wins; keys matching no prefix do not expire.
+	// Setters taking an explicit TTL ignore the rules.
+	PrefixTTLs map[string]time.Duration
+	// WriteDebounce, if positive, is the minimum interval between stored writes to the same key.
+	// A Set arriving sooner is not stored right away; the latest such value is stored once the interval
+	// since the last stored write has passed. Deleting, evicting, or expiring the key, or clearing the cache,
+	// discards a pending write. Writes made in a Transaction are not debounced.
+	WriteDebounce time.Duration
+	// WatchCoalesce, if positive, merges the events for a watched key that occur within this window
+	// of the first one into a single event carrying the latest change, delivered when the window ends.
+	WatchCoalesce time.Duration
+	// TimeResolution, if positive, makes the cache compute and check expirations with a clock that a background
+	// goroutine refreshes every TimeResolution, instead of calling time.Now on every operation. Items may then
+	// expire up to TimeResolution early or late. The goroutine stops when the cache is garbage collected.
+	// It is ignored if Clock is set.
+	TimeResolution time.Duration
+	// TaskPool, if set, runs the cache's background tasks on the pool's goroutines: write debounce flushes,
+	// watch coalescing flushes, KeysChan streams, and TimeResolution clock refreshes. Sharing one pool between
+	// caches bounds their background goroutines together. By default every task gets its own goroutine or timer.
+	TaskPool *TaskPool
+	// Clock, if set, supplies the time the cache uses to compute and check expirations, so that tests can
+	// control expiry with a fake clock. Write debouncing and watch coalescing still use real timers.
+	Clock Clock
+	// Seed, if non-zero, seeds the random choices of the eviction policy, so that a cache created with the same
+	// seed, clock, and sequence of operations evicts the same items. It only affects policies with random choices,
+	// such as the one returned by NewRandomSamplePolicy, and reseeds them when the cache is created.
+	Seed uint64
+	// Logger receives the panics recovered from expiration callbacks, GetOrComputeContext functions,
+	// and SetLazy functions.
+	// If nil, the standard logger is used.
+	Logger *log.Logger
+	// PropagatePanics lets panics in those callbacks propagate to the caller instead of being recovered and logged.
+	PropagatePanics bool
+}
+
+// NewCacheWithConfig creates a new in-memory cache with the given configuration.
+func NewCacheWithConfig(cfg CacheConfig) Cache {
+	c := &inMemoryCache{
+		items:      make(map[string]cachedItem, max(cfg.InitialSize, 0)),
+		sizeHint:   max(cfg.InitialSize, 0),
+		capacity:   cfg.Capacity,
+		evictBatch: min(max(cfg.EvictBatch, 1), max(cfg.Capacity, 1)),
+		policy:     cfg.Policy,
+		keyFn:      cfg.KeyFunc,
+		hashKeys:   cfg.HashKeys,
+		copyFn:     cfg.CopyFunc,
+		getCopyFn:  cfg.GetCopyFunc,
+		equalFn:    cfg.EqualFunc,
+		sizer:      cfg.Sizer,
+		maxSize:    max(cfg.MaxValueSize, 0),
+
+		emptyValueDeletes: cfg.EmptyValueDeletes,
+		rejectNilValues:   cfg.RejectNilValues,
+		fullBehavior:      cfg.FullBehavior,
+		debounce:          cfg.WriteDebounce,
+		logger:            cfg.Logger,
+		propagatePanics:   cfg.PropagatePanics,
+		pool:              cfg.TaskPool,
+	}
+
+	c.epoch.Store(new(epoch))
+	c.watch.coalesce = cfg.WatchCoalesce
+	c.watch.pool = cfg.TaskPool
+	if c.logger == nil {
+		c.logger = log.Default()
+	}
+	if c.equalFn == nil {
+		c.equalFn = reflect.DeepEqual
+	}
+	if c.getCopyFn == nil {
+		c.getCopyFn = DeepCopy
+	}
+	if c.sizer == nil {
+		c.sizer = valueSize
+	}
+	if cfg.TinyLFUAdmission && cfg.Capacity > 0 {
+		c.sketch = newCountMinSketch(cfg.Capacity)
+	}
+	if cfg.BloomFilterKeys > 0 {
+		c.bloom = newBloomFilter(cfg.BloomFilterKeys)
+	}
+	for prefix, ttl := range cfg.PrefixTTLs {
+		c.prefixTTLs = append(c.prefixTTLs, prefixTTL{prefix: c.applyKeyFunc(prefix), ttl: ttl})
+	}
+	slices.SortFunc(c.prefixTTLs, func(a, b prefixTTL) int {
+		return cmp.Or(cmp.Compare(len(b.prefix), len(a.prefix)), cmp.Compare(a.prefix, b.prefix))
+	})
+	if cfg.Clock != nil {
+		c.clock = cfg.Clock
+	} else if cfg.TimeResolution > 0 {
+		clock := newCoarseClock(cfg.TimeResolution, cfg.TaskPool)
+		runtime.AddCleanup(c, (*coarseClock).close, clock)
+		c.clock = clock
+	}
+
+	if c.policy == nil && c.capacity > 0 {
+		c.policy = NewLRUPolicy()
+	}
+	if c.policy != nil {
+		c.priorities = make(map[int]int)
+	}
+	if p, ok := c.policy.(seedablePolicy); ok && cfg.Seed != 0 {
+		p.seed(cfg.Seed)
+	}
+	if c.copyFn == nil && cfg.CopyByteValues {
+		c.copyFn = copyBytes
+	}
+
+	return c
+}
+
+// copyBytes returns a copy of value if it is a []byte, and value itself otherwise.
+func copyBytes(value any) any {
+	if b, ok := value.([]byte); ok && b != nil {
+		return append([]byte(nil), b...)
+	}
+
+	return value
+}
@@ -0,0 +1,164 @@
+package cache
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCopyByteValues(t *testing.T) {
+	c := NewCacheWithConfig(CacheConfig{CopyByteValues: true})
+
+	stored := []byte("hello")
+	c.Set("a", stored)
+	stored[0] = 'j'
+
+	value, _ := c.Get("a")
+	if string(value.([]byte)) != "hello" {
+		t.Fatalf("Get(a) = %q after mutating the stored slice, want hello", value)
+	}
+	value.([]byte)[0] = 'y'
+	if again, _ := c.Get("a"); string(again.([]byte)) != "hello" {
+		t.Fatalf("Get(a) = %q after mutating a read slice, want hello", again)
+	}
+
+	// Other types are still shared.
+	shared := map[string]int{"n": 1}
+	c.Set("m", shared)
+	shared["n"] = 2
+	if value, _ := c.Get("m"); value.(map[string]int)["n"] != 2 {
+		t.Fatal("a map value was copied, want only byte slices copied")
+	}
+}
+
+func TestByteValuesSharedByDefault(t *testing.T) {
+	c := NewCache()
+
+	stored := []byte("hello")
+	c.Set("a", stored)
+	stored[0] = 'j'
+	if value, _ := c.Get("a"); string(value.([]byte)) != "jello" {
+		t.Fatalf("Get(a) = %q, want the stored slice shared", value)
+	}
+}
+
+func TestEmptyValueDeletes(t *testing.T) {
+	for _, deletes := range []bool{false, true} {
+		c := NewCacheWithConfig(CacheConfig{EmptyValueDeletes: deletes})
+		c.Set("a", "value")
+		c.Set("a", "")
+
+		value, ok := c.Get("a")
+		if deletes && ok {
+			t.Fatalf("EmptyValueDeletes: Get(a) = %q, true, want the key deleted", value)
+		}
+		if !deletes && (!ok || value != "") {
+			t.Fatalf("Get(a) = %q, %v, want the empty string stored", value, ok)
+		}
+	}
+}
+
+func TestKeyFuncNormalizesKeys(t *testing.T) {
+	c := NewCacheWithKeyFunc(func(key string) string { return strings.ToLower(strings.TrimSpace(key)) })
+
+	c.Set("key", 1)
+	if value, ok := c.Get("KEY"); !ok || value != 1 {
+		t.Fatalf("Get(KEY) = %v, %v, want 1, true", value, ok)
+	}
+	c.Set("  Key ", 2)
+	if value, ok := c.Get("key"); !ok || value != 2 {
+		t.Fatalf("Get(key) = %v, %v, want the value set under an equivalent key", value, ok)
+	}
+
+	c.Set("Other", 3)
+	var keys []string
+	for _, entry := range c.(InspectableCache).Entries() {
+		keys = append(keys, entry.Key)
+	}
+	slices.Sort(keys)
+	if !slices.Equal(keys, []string{"key", "other"}) {
+		t.Fatalf("stored keys = %q, want the normalized keys [key other]", keys)
+	}
+
+	c.Delete(" OTHER")
+	if _, ok := c.Get("other"); ok {
+		t.Fatal("Get(other) ok = true after deleting an equivalent key")
+	}
+}
+
+func TestNilValues(t *testing.T) {
+	c := NewCache()
+	c.Set("nil", nil)
+
+	if value, ok := c.Get("nil"); value != nil || !ok {
+		t.Fatalf("Get(nil) = %v, %v, want nil, true for a stored nil", value, ok)
+	}
+	if value, ok := c.Get("missing"); value != nil || ok {
+		t.Fatalf("Get(missing) = %v, %v, want nil, false", value, ok)
+	}
+	lookup := c.(LookupCache)
+	if !lookup.Has("nil") || lookup.Has("missing") {
+		t.Fatal("Has() does not tell a stored nil from a miss")
+	}
+	if err := c.(CheckedCache).SetChecked("checked", nil, 0); err != nil {
+		t.Fatalf("SetChecked(nil) error = %v without RejectNilValues", err)
+	}
+}
+
+func TestRejectNilValues(t *testing.T) {
+	c := NewCacheWithConfig(CacheConfig{RejectNilValues: true})
+	checked := c.(CheckedCache)
+
+	if err := checked.SetChecked("a", nil, 0); !errors.Is(err, ErrNilValue) {
+		t.Fatalf("SetChecked(nil) error = %v, want ErrNilValue", err)
+	}
+	if c.(LookupCache).Has("a") {
+		t.Fatal("a rejected nil value was stored")
+	}
+	if err := checked.SetChecked("a", 0, 0); err != nil {
+		t.Fatalf("SetChecked(0) error = %v, want a zero non-nil value accepted", err)
+	}
+
+	// Setters without an error keep storing nil.
+	c.Set("b", nil)
+	if value, ok := c.Get("b"); value != nil || !ok {
+		t.Fatalf("Get(b) = %v, %v, want nil, true", value, ok)
+	}
+}
+
+func TestPrefixTTLs(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCacheWithConfig(CacheConfig{Clock: clock, PrefixTTLs: map[string]time.Duration{
+		"session:":       30 * time.Minute,
+		"session:admin:": 5 * time.Minute,
+		"cache:":         time.Minute,
+	}})
+	tc := c.(TTLCache)
+
+	for key, want := range map[string]time.Duration{
+		"session:1":       30 * time.Minute,
+		"session:admin:1": 5 * time.Minute,
+		"cache:page":      time.Minute,
+		"other":           0,
+	} {
+		c.Set(key, 1)
+		if ttl, ok := tc.GetTTL(key); !ok || ttl != want {
+			t.Fatalf("GetTTL(%s) = %v, %v, want %v from the longest matching prefix", key, ttl, ok, want)
+		}
+	}
+
+	// An explicit TTL overrides the prefix rule.
+	c.SetWithTTL("session:2", 1, time.Hour)
+	if ttl, _ := tc.GetTTL("session:2"); ttl != time.Hour {
+		t.Fatalf("GetTTL(session:2) = %v, want the explicit TTL", ttl)
+	}
+
+	clock.Advance(10 * time.Minute)
+	for key, live := range map[string]bool{"session:1": true, "session:admin:1": false, "cache:page": false, "other": true} {
+		if _, ok := c.Get(key); ok != live {
+			t.Fatalf("Get(%s) ok = %v after 10m, want %v", key, ok, live)
+		}
+	}
+}
@@ -1,7 +1,15 @@
 package cache
 
 import (
+	"context"
+	"fmt"
+	"iter"
+	"log"
+	"maps"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -9,82 +17,1820 @@ import (
 type cachedItem struct {
 	value      any
 	expiration time.Time
+	created    time.Time
+	priority   int
+	cost       float64
+	tier       string
+	pinned     bool
+	// labels are the descriptive labels attached by SetWithLabels. They are never modified in place.
+	labels map[string]string
+	// size is the value's size as measured by the cache's sizer. setLocked computes it.
+	size     int
+	onExpire func(value any)
+	// accesses counts the reads that found the item. It is shared by the copies of the item
+	// and carried over when a live item is overwritten. setLocked allocates it.
+	accesses *atomic.Uint64
+	// epoch is the epoch the item was stored in. An item from an ended epoch counts as expired.
+	epoch *epoch
+	// written is when a cache configured with WriteDebounce stored the write that created the item.
+	written time.Time
 }
 
-// isExpired checks whether the cached item has expired.
-func (ci cachedItem) isExpired() bool {
+// expiredAt checks whether the cached item has expired at now or was invalidated by InvalidateAll.
+func (ci cachedItem) expiredAt(now time.Time) bool {
+	if ci.invalidated() {
+		return true
+	}
 	if ci.expiration.IsZero() {
 		return false
 	}
 
-	return time.Now().After(ci.expiration)
+	return now.After(ci.expiration)
+}
+
+// prefixTTL is a rule giving the keys that start with prefix a default TTL.
+type prefixTTL struct {
+	prefix string
+	ttl    time.Duration
 }
 
 // inMemoryCache is an in-memory cache implementation.
 type inMemoryCache struct {
-	mu    sync.RWMutex
-	items map[string]cachedItem
+	mu       sync.RWMutex
+	items    map[string]cachedItem
+	sizeHint int
+
+	// Eviction state. priorities counts the items stored with each priority
+	// and is only maintained when a policy is set, and pinned counts the pinned items.
+	// evictBatch is at least 1.
+	capacity   int
+	evictBatch int
+	policy     EvictionPolicy
+	priorities map[int]int
+	pinned     int
+
+	// sizer measures stored values, and bytes is the total size of the stored items.
+	// maxSize, if positive, is the size above which values are not stored.
+	sizer   func(any) int
+	bytes   int64
+	maxSize int
+
+	// sketch, if set, estimates key access frequencies for TinyLFU admission.
+	sketch *countMinSketch
+
+	// tiers holds the keys of the items stored in each non-empty tier.
+	tiers map[string]map[string]struct{}
+	// bloom, if set, records every key stored since the cache was created or last cleared.
+	bloom *bloomFilter
+	// cleared holds the keys of the Clear calls still telling a custom eviction policy of the keys they removed.
+	cleared []*clearedKeys
+
+	// Configured key and value handling. keyFn normalizes every key passed in by callers, hashKeys replaces
+	// the normalized keys with their hashes,
+	// getCopyFn copies the values returned by GetCopy, equalFn compares values for CompareAndSwap and CompareAndDelete,
+	// emptyValueDeletes makes storing an empty string delete the key,
+	// and rejectNilValues makes SetChecked refuse nil.
+	keyFn             func(string) string
+	hashKeys          bool
+	copyFn            func(any) any
+	getCopyFn         func(any) any
+	equalFn           func(a, b any) bool
+	emptyValueDeletes bool
+	rejectNilValues   bool
+	fullBehavior      FullBehavior
+
+	// prefixTTLs holds the default TTL rules applied by Set, ordered from the longest prefix to the shortest.
+	prefixTTLs []prefixTTL
+
+	// debounce is the minimum interval between stored writes to a key.
+	// debounced holds the keys with a write waiting for the interval to pass.
+	debounce  time.Duration
+	debounced map[string]*debounceState
+
+	// clock, if set, supplies the time used to compute and check expirations instead of time.Now.
+	clock Clock
+	// epoch is the epoch new items are stored in. InvalidateAll replaces it.
+	epoch atomic.Pointer[epoch]
+	// expirySuspended makes items count as live whatever their expiration, while SuspendExpiration is in effect.
+	expirySuspended atomic.Bool
+
+	flight Group
+	watch  watchers
+	// expireCallbacks holds the callbacks of items that expired while the write lock was held.
+	// They run in unlock, after the lock is released.
+	expireCallbacks []func()
+	// backlog is the callback registered with OnBacklog, run by cleanup passes.
+	backlog backlogWatch
+	// lastBacklog is the backlog counted by the last cleanup pass, reported by Stats.
+	lastBacklog int
+	// logger receives the panics recovered from user callbacks unless propagatePanics is set.
+	logger          *log.Logger
+	propagatePanics bool
+	// pool, if set, runs the background tasks: debounce flushes and KeysChan streams.
+	pool *TaskPool
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+
+	// Expiration accounting, guarded by mu: the number and total size of the expired items removed,
+	// and the time they stayed stored past their expiration.
+	expired      uint64
+	expiredBytes int64
+	expiryLagSum time.Duration
+	expiryLagMax time.Duration
+
+	// Worker activity reported for Health. lastCleanup is in Unix nanoseconds, or 0 before the first cleanup.
+	workers     atomic.Int32
+	lastCleanup atomic.Int64
 }
 
 // NewCache creates and returns a new instance of inMemoryCache that implements the Cache interface.
 func NewCache() Cache {
-	return &inMemoryCache{
-		items: make(map[string]cachedItem),
+	return NewCacheWithConfig(CacheConfig{})
+}
+
+// NewCacheWithSize creates a new in-memory cache with room for approximately hint items.
+// Pre-allocating avoids repeated map growth while a large cache warms up.
+// The hint is also used when the cache is cleared. If hint <= 0, no space is pre-allocated.
+func NewCacheWithSize(hint int) Cache {
+	return NewCacheWithConfig(CacheConfig{InitialSize: hint})
+}
+
+// NewBoundedCache creates a new in-memory cache that holds at most capacity items.
+// When a new item would exceed the capacity, the cache evicts the victims chosen by policy.
+// If policy is nil, least recently used items are evicted. If capacity <= 0, the cache is unbounded.
+func NewBoundedCache(capacity int, policy EvictionPolicy) Cache {
+	if policy == nil {
+		policy = NewLRUPolicy()
+	}
+
+	return NewCacheWithConfig(CacheConfig{Capacity: capacity, Policy: policy})
+}
+
+// NewCacheWithKeyFunc creates a new in-memory cache that passes every key given to its methods through fn,
+// so keys that fn maps to the same string address the same item. The stored keys, as returned by Entries,
+// are the normalized ones. fn must be idempotent. If fn is nil, keys are used as given.
+func NewCacheWithKeyFunc(fn func(string) string) Cache {
+	return NewCacheWithConfig(CacheConfig{KeyFunc: fn})
+}
+
+// NewCacheWithCopy creates a new in-memory cache that stores copyFn(value) on every set
+// and returns copyFn(stored) on every read, so callers never share mutable values with the cache.
+// If copyFn is nil, DeepCopy is used.
+func NewCacheWithCopy(copyFn func(any) any) Cache {
+	if copyFn == nil {
+		copyFn = DeepCopy
+	}
+
+	return NewCacheWithConfig(CacheConfig{CopyFunc: copyFn})
+}
+
+// now returns the time used to compute and check expirations: the clock's time if the cache has one,
+// and time.Now otherwise.
+func (c *inMemoryCache) now() time.Time {
+	if c.clock != nil {
+		return c.clock.Now()
+	}
+
+	return time.Now()
+}
+
+// isExpired checks whether item has expired by the cache's clock or was invalidated by InvalidateAll.
+func (c *inMemoryCache) isExpired(item cachedItem) bool {
+	return c.isExpiredAt(item, c.now())
+}
+
+// isExpiredAt checks whether item has expired at now or was invalidated by InvalidateAll.
+// While expiration is suspended, only invalidated items count as expired.
+func (c *inMemoryCache) isExpiredAt(item cachedItem, now time.Time) bool {
+	if c.expirySuspended.Load() {
+		return item.invalidated()
+	}
+
+	return item.expiredAt(now)
+}
+
+// normalizeKey returns key as transformed by the configured key function, and hashed if the cache hashes keys.
+// Every key is hashed, including one that has the form of a hash; the StoredKeyCache methods take stored keys as is.
+// Public methods normalize the keys they are given once and pass the result to unexported helpers such as get
+// and setNormalized, which store and look up keys as given.
+func (c *inMemoryCache) normalizeKey(key string) string {
+	key = c.applyKeyFunc(key)
+	if c.hashKeys {
+		return hashKey(key)
+	}
+
+	return key
+}
+
+// applyKeyFunc returns key as transformed by the configured key function, before any hashing.
+func (c *inMemoryCache) applyKeyFunc(key string) string {
+	if c.keyFn == nil {
+		return key
+	}
+
+	return c.keyFn(key)
+}
+
+// copyValue returns a copy of value if the cache was created with a copy function.
+func (c *inMemoryCache) copyValue(value any) any {
+	value = settledValue(value)
+	if c.copyFn == nil {
+		return value
 	}
+
+	return c.copyFn(value)
 }
 
 // Get retrieves the value for the specified key if it exists and is not expired.
 // If the item is expired, it is removed and (nil, false) is returned.
+// A stored nil value is returned as (nil, true).
 func (c *inMemoryCache) Get(key string) (any, bool) {
+	return c.get(c.normalizeKey(key))
+}
+
+// get is Get for a normalized key.
+func (c *inMemoryCache) get(key string) (any, bool) {
+	if c.sketch != nil {
+		c.sketch.increment(key)
+	}
+	if c.bloom != nil && !c.bloom.mayContain(key) {
+		c.misses.Add(1)
+		return nil, false
+	}
+
+	c.mu.RLock()
+	item, ok := c.items[key]
+	if ok && c.policy != nil && !c.isExpired(item) {
+		c.policy.OnAccess(key)
+	}
+	c.mu.RUnlock()
+
+	if !ok {
+		c.misses.Add(1)
+		return nil, false
+	}
+
+	if c.isExpired(item) {
+		c.misses.Add(1)
+		c.deleteExpired(key)
+		return nil, false
+	}
+
+	value, err := c.resolveItem(key, item)
+	if err != nil {
+		c.misses.Add(1)
+		return nil, false
+	}
+
+	c.hits.Add(1)
+	item.accesses.Add(1)
+	return c.copyValue(value), true
+}
+
+// GetCopy retrieves the value for the specified key like Get and returns a copy made with the cache's GetCopyFunc,
+// DeepCopy by default, that the caller may mutate without affecting the cached value. Other reads keep sharing
+// the stored value; a cache configured with CopyFunc copies the value again.
+func (c *inMemoryCache) GetCopy(key string) (any, bool) {
+	value, ok := c.Get(key)
+	if !ok {
+		return nil, false
+	}
+
+	return c.getCopyFn(value), true
+}
+
+// TryGet is like Get but returns acquired=false, without waiting and without counting a hit or miss,
+// if the read lock is held by a writer or wanted by a waiting one. An expired item is reported as a miss
+// but left for the cache worker to remove, since removing it would need the write lock.
+func (c *inMemoryCache) TryGet(key string) (value any, ok bool, acquired bool) {
+	key = c.normalizeKey(key)
+	if c.bloom != nil && !c.bloom.mayContain(key) {
+		c.misses.Add(1)
+		return nil, false, true
+	}
+
+	if !c.mu.TryRLock() {
+		return nil, false, false
+	}
+	item, ok := c.items[key]
+	if ok && c.policy != nil && !c.isExpired(item) {
+		c.policy.OnAccess(key)
+	}
+	c.mu.RUnlock()
+
+	if !ok || c.isExpired(item) {
+		c.misses.Add(1)
+		return nil, false, true
+	}
+	if lazy, ok := item.value.(*lazyValue); ok {
+		if _, computed := lazy.peek(); !computed {
+			return nil, false, false
+		}
+	}
+
+	c.hits.Add(1)
+	item.accesses.Add(1)
+	return c.copyValue(item.value), true, true
+}
+
+// MayContain reports whether key may be stored. If the cache has no bloom filter, it always returns true;
+// otherwise false means that key has not been stored since the cache was created or last cleared.
+func (c *inMemoryCache) MayContain(key string) bool {
+	return c.bloom == nil || c.bloom.mayContain(c.normalizeKey(key))
+}
+
+// AddToFilter records keys in the bloom filter without storing them, for example to seed the filter with
+// the keys a backing store is known to hold. It does nothing if the cache has no bloom filter.
+// Like stored keys, added keys are forgotten by Clear.
+func (c *inMemoryCache) AddToFilter(keys ...string) {
+	if c.bloom == nil {
+		return
+	}
+	for _, key := range keys {
+		c.bloom.add(c.normalizeKey(key))
+	}
+}
+
+// Has reports whether a live item is stored under the specified key, even if its value is nil.
+// It does not count as a hit or miss and does not affect eviction order.
+func (c *inMemoryCache) Has(key string) bool {
+	key = c.normalizeKey(key)
+
+	c.mu.RLock()
+	item, ok := c.items[key]
+	c.mu.RUnlock()
+
+	return ok && !c.isExpired(item)
+}
+
+// GetIfFresh retrieves the value for the specified key like Get, but only if the item will live longer
+// than minRemaining. A live item that expires sooner is left in the cache and counted as a miss.
+func (c *inMemoryCache) GetIfFresh(key string, minRemaining time.Duration) (any, bool) {
+	key = c.normalizeKey(key)
+
 	c.mu.RLock()
 	item, ok := c.items[key]
+	fresh := ok && !c.isExpired(item) && (item.expiration.IsZero() || item.expiration.Sub(c.now()) > minRemaining)
+	if fresh && c.policy != nil {
+		c.policy.OnAccess(key)
+	}
 	c.mu.RUnlock()
 
+	if ok && c.isExpired(item) {
+		c.deleteExpired(key)
+	}
+	if !fresh {
+		c.misses.Add(1)
+		return nil, false
+	}
+
+	value, err := c.resolveItem(key, item)
+	if err != nil {
+		c.misses.Add(1)
+		return nil, false
+	}
+
+	c.hits.Add(1)
+	item.accesses.Add(1)
+	return c.copyValue(value), true
+}
+
+// GetGroup retrieves the fields stored under groupKey by SetGroup.
+// The returned map is a copy, so adding or removing fields does not affect the cache.
+func (c *inMemoryCache) GetGroup(groupKey string) (map[string]any, bool) {
+	value, ok := c.Get(groupKey)
+	if !ok {
+		return nil, false
+	}
+
+	fields, ok := value.(map[string]any)
+	if !ok {
+		return nil, false
+	}
+
+	return maps.Clone(fields), true
+}
+
+// GetWithSource retrieves the value for the specified key like Get.
+// The source is SourceHit when a value is found and SourceNone otherwise.
+func (c *inMemoryCache) GetWithSource(key string) (any, Source, bool) {
+	value, ok := c.Get(key)
+	if !ok {
+		return nil, SourceNone, false
+	}
+
+	return value, SourceHit, true
+}
+
+// GetOrComputeContext returns the value for the specified key, or computes it with fn and stores it with the returned TTL.
+// Only the caller that starts the computation passes its context to fn; errors from fn are returned but not cached.
+func (c *inMemoryCache) GetOrComputeContext(ctx context.Context, key string, fn func(ctx context.Context) (any, time.Duration, error)) (any, error) {
+	key = c.normalizeKey(key)
+
+	if value, ok := c.get(key); ok {
+		return value, nil
+	}
+
+	value, err, _ := c.flight.doContext(ctx, key, func() (any, error) {
+		// The key may have been stored by a flight that finished just before this one started.
+		if value, ok := c.get(key); ok {
+			return value, nil
+		}
+
+		value, ttl, err := c.compute(ctx, fn)
+		if err != nil {
+			return nil, err
+		}
+		c.setNormalized(key, c.newItem(value, ttl))
+
+		return value, nil
+	})
+
+	return value, err
+}
+
+// compute runs the fn of GetOrComputeContext. Unless the cache propagates panics, a panic in fn
+// is recovered and returned as an error wrapping ErrPanic.
+func (c *inMemoryCache) compute(ctx context.Context, fn func(ctx context.Context) (any, time.Duration, error)) (value any, ttl time.Duration, err error) {
+	defer recoverCallback(c.logger, c.propagatePanics, "compute function", &err)
+
+	return fn(ctx)
+}
+
+// GetAndRefresh retrieves the value for the specified key and resets its expiration to now+ttl
+// under the write lock, so the item cannot be removed between the read and the refresh.
+// An expired item is removed and (nil, false) is returned.
+func (c *inMemoryCache) GetAndRefresh(key string, ttl time.Duration) (any, bool) {
+	key = c.normalizeKey(key)
+
+	c.mu.Lock()
+	defer c.unlock()
+
+	item, ok := c.items[key]
 	if !ok {
+		c.misses.Add(1)
 		return nil, false
 	}
 
-	if item.isExpired() {
-		c.Delete(key)
+	if c.isExpired(item) {
+		c.misses.Add(1)
+		c.removeLocked(key, EventExpire)
 		return nil, false
 	}
 
-	return item.value, true
+	c.hits.Add(1)
+	item.accesses.Add(1)
+
+	item.expiration = time.Time{}
+	if ttl > 0 {
+		item.expiration = c.now().Add(ttl)
+	}
+	c.items[key] = item
+	if c.policy != nil {
+		c.policy.OnAccess(key)
+	}
+
+	return c.copyValue(item.value), true
+}
+
+// GetWithRefreshTTL is GetAndRefresh.
+func (c *inMemoryCache) GetWithRefreshTTL(key string, ttl time.Duration) (any, bool) {
+	return c.GetAndRefresh(key, ttl)
+}
+
+// GetTTL returns the remaining time-to-live for the specified key.
+// If the item does not expire, the returned TTL is 0.
+func (c *inMemoryCache) GetTTL(key string) (time.Duration, bool) {
+	key = c.normalizeKey(key)
+
+	c.mu.RLock()
+	item, ok := c.items[key]
+	c.mu.RUnlock()
+
+	if !ok || c.isExpired(item) {
+		return 0, false
+	}
+
+	if item.expiration.IsZero() {
+		return 0, true
+	}
+
+	return item.expiration.Sub(c.now()), true
 }
 
-// Set assigns a value to the specified key without setting an expiration.
+// Set assigns a value to the specified key with the TTL of the longest configured prefix rule matching the key,
+// or without expiration if no rule matches.
 func (c *inMemoryCache) Set(key string, value any) {
-	c.SetWithTTL(key, value, 0)
+	c.SetWithTTL(key, value, c.prefixTTL(key))
+}
+
+// prefixTTL returns the TTL of the longest prefix rule matching key, or 0 if no rule matches.
+func (c *inMemoryCache) prefixTTL(key string) time.Duration {
+	if len(c.prefixTTLs) == 0 {
+		return 0
+	}
+
+	key = c.applyKeyFunc(key)
+	for _, rule := range c.prefixTTLs {
+		if strings.HasPrefix(key, rule.prefix) {
+			return rule.ttl
+		}
+	}
+
+	return 0
 }
 
 // SetWithTTL assigns a value to the specified key with a TTL.
 // If ttl <= 0, the item does not expire.
 func (c *inMemoryCache) SetWithTTL(key string, value any, ttl time.Duration) {
+	c.set(key, c.newItem(value, ttl))
+}
+
+// SetChecked assigns a value to the specified key with a TTL like SetWithTTL, but returns ErrEmptyKey
+// for an empty key, if the cache rejects nil values, ErrNilValue for a nil value, and an error wrapping
+// ErrValueTooLarge for a value larger than the cache's MaxValueSize. If a bounded cache is full, none of its items can be evicted, and it is configured with FullReject,
+// it returns an error wrapping ErrCacheFull for a new key.
+func (c *inMemoryCache) SetChecked(key string, value any, ttl time.Duration) error {
+	if key == "" {
+		return ErrEmptyKey
+	}
+	if value == nil && c.rejectNilValues {
+		return ErrNilValue
+	}
+
+	return c.set(key, c.newItem(value, ttl))
+}
+
+// SetWithPriority assigns a value to the specified key with a TTL and an eviction priority.
+// When a bounded cache is over capacity, it evicts items with the lowest priority first,
+// using the eviction policy's order among items of the same priority.
+func (c *inMemoryCache) SetWithPriority(key string, value any, ttl time.Duration, priority int) {
+	item := c.newItem(value, ttl)
+	item.priority = priority
+	c.set(key, item)
+}
+
+// SetWithCost assigns a value to the specified key with a TTL and the cost of reloading it.
+// Cost-aware eviction policies such as NewGDSFPolicy keep expensive items longer.
+func (c *inMemoryCache) SetWithCost(key string, value any, ttl time.Duration, cost float64) {
+	item := c.newItem(value, ttl)
+	item.cost = cost
+	c.set(key, item)
+}
+
+// SetGroup stores a copy of fields as a single item under groupKey, so the fields share one expiration
+// and are replaced, expired, and deleted together. Get on groupKey returns the map[string]any.
+func (c *inMemoryCache) SetGroup(groupKey string, fields map[string]any, ttl time.Duration) {
+	c.SetWithTTL(groupKey, maps.Clone(fields), ttl)
+}
+
+// SetMultiTTL stores every value in values under a single write lock, each with the TTL of its key in ttls.
+// Keys missing from ttls, or with a TTL <= 0, do not expire. Keys are looked up in ttls before normalization.
+func (c *inMemoryCache) SetMultiTTL(values map[string]any, ttls map[string]time.Duration) {
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	defer c.unlock()
 
-	var expiration time.Time
-	if ttl > 0 {
-		expiration = time.Now().Add(ttl)
+	for key, value := range values {
+		item := c.newItem(value, ttls[key])
+		key = c.normalizeKey(key)
+		if c.debounce > 0 && c.debounceLocked(key, &item) {
+			continue
+		}
+		c.storeLocked(key, item)
+	}
+}
+
+// SetWithTier assigns a value to the specified key with a TTL and tags it with a cleanup tier.
+func (c *inMemoryCache) SetWithTier(key string, value any, ttl time.Duration, tier string) {
+	item := c.newItem(value, ttl)
+	item.tier = tier
+	c.set(key, item)
+}
+
+// SetWithExpireCallback assigns a value to the specified key with a TTL and registers onExpire to run with the value
+// when the item expires and is removed, either by the worker or lazily by a read.
+// The callback runs after the cache lock is released; it does not run on Delete, Clear, eviction, or a normal overwrite.
+func (c *inMemoryCache) SetWithExpireCallback(key string, value any, ttl time.Duration, onExpire func(value any)) {
+	item := c.newItem(value, ttl)
+	item.onExpire = onExpire
+	c.set(key, item)
+}
+
+// newItem creates an item holding value, copied if the cache copies values, that expires after ttl.
+// If ttl <= 0, the item does not expire.
+func (c *inMemoryCache) newItem(value any, ttl time.Duration) cachedItem {
+	item := cachedItem{
+		value:   c.copyValue(value),
+		created: c.now(),
+		epoch:   c.epoch.Load(),
 	}
-	c.items[key] = cachedItem{
-		value:      value,
-		expiration: expiration,
+	if ttl > 0 {
+		item.expiration = item.created.Add(ttl)
 	}
+
+	return item
 }
 
-// Delete removes the item associated with the specified key from the cache.
-func (c *inMemoryCache) Delete(key string) {
+// set normalizes key and stores item under it under the write lock.
+// It returns the error from storeLocked; most setters ignore it, and SetChecked returns it.
+func (c *inMemoryCache) set(key string, item cachedItem) error {
+	return c.setNormalized(c.normalizeKey(key), item)
+}
+
+// setNormalized is set for a normalized key.
+func (c *inMemoryCache) setNormalized(key string, item cachedItem) error {
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	defer c.unlock()
 
-	delete(c.items, key)
+	if c.debounce > 0 && c.debounceLocked(key, &item) {
+		return nil
+	}
+
+	return c.storeLocked(key, item)
 }
 
-// Clear removes all items from the cache.
-func (c *inMemoryCache) Clear() {
+// storeLocked stores item under key, or deletes the key if the cache treats empty values as deletes
+// and the item holds an empty string. It returns ErrValueTooLarge, without storing the item, if the value
+// is larger than the cache's maximum value size, ErrCacheFull if key is new, the cache is full, nothing can
+// be evicted, and the cache is configured with FullReject, and ErrNotAdmitted if key is new, the cache is full,
+// and TinyLFU admission rejects it. The caller must hold the write lock.
+func (c *inMemoryCache) storeLocked(key string, item cachedItem) error {
+	if c.emptyValueDeletes && item.value == "" {
+		c.removeLocked(key, EventDelete)
+		return nil
+	}
+	if c.maxSize > 0 {
+		if size := c.sizer(settledValue(item.value)); size > c.maxSize {
+			return fmt.Errorf("%w: %q has size %d, limit %d", ErrValueTooLarge, key, size, c.maxSize)
+		}
+	}
+
+	if c.sketch != nil {
+		c.sketch.increment(key)
+		if c.capacity > 0 && len(c.items) >= c.capacity {
+			if _, exists := c.items[key]; !exists && !c.admitLocked(key) {
+				return fmt.Errorf("%w: %q", ErrNotAdmitted, key)
+			}
+		}
+	}
+	if c.fullBehavior == FullReject && c.capacity > 0 && len(c.items) >= c.capacity {
+		if _, exists := c.items[key]; !exists && !c.evictableLocked() {
+			return fmt.Errorf("%w: cannot store %q", ErrCacheFull, key)
+		}
+	}
+	c.setLocked(key, item)
+
+	return nil
+}
+
+// admitLocked reports whether a new key may be stored in the full cache: only if it was accessed more often
+// recently than the item the eviction policy would evict for it. If there is no such item, the key is admitted
+// and the cache's FullBehavior applies. The caller must hold the write lock.
+func (c *inMemoryCache) admitLocked(key string) bool {
+	victim, ok := c.victimLocked()
+	if !ok {
+		return true
+	}
+	if _, ok := c.items[victim]; !ok {
+		return true
+	}
+
+	return c.sketch.estimate(key) > c.sketch.estimate(victim)
+}
+
+// evictableLocked reports whether the eviction policy can pick a stored item to evict.
+// The caller must hold the write lock.
+func (c *inMemoryCache) evictableLocked() bool {
+	for {
+		victim, ok := c.victimLocked()
+		if !ok {
+			return false
+		}
+		if _, ok := c.items[victim]; ok {
+			return true
+		}
+		c.forgetVictimLocked(victim)
+	}
+}
+
+// forcedVictimLocked returns the stored item with the lowest priority other than key, for a cache that is full
+// and configured with FullEvictLowestPriority when the eviction policy has no victim.
+// Ties are broken by the smallest key, so the choice does not depend on map iteration order.
+// The caller must hold the write lock.
+func (c *inMemoryCache) forcedVictimLocked(key string) (string, bool) {
+	var (
+		victim string
+		lowest int
+		found  bool
+	)
+	for candidate, item := range c.items {
+		if candidate == key {
+			continue
+		}
+		if !found || item.priority < lowest || item.priority == lowest && candidate < victim {
+			victim, lowest, found = candidate, item.priority, true
+		}
+	}
+
+	return victim, found
+}
+
+// setLocked stores item under key, notifies the eviction policy, and evicts items over capacity.
+// The caller must hold the write lock.
+func (c *inMemoryCache) setLocked(key string, item cachedItem) {
+	old, exists := c.items[key]
+	if exists {
+		c.unindexLocked(key, old)
+		if c.isExpired(old) {
+			if !old.invalidated() {
+				c.recordExpiryLocked(old)
+				c.queueExpireCallbackLocked(old)
+			}
+		} else {
+			item.accesses = old.accesses
+			item.pinned = old.pinned
+		}
+	}
+	if item.accesses == nil {
+		item.accesses = new(atomic.Uint64)
+	}
+	item.size = c.sizer(settledValue(item.value))
+	c.items[key] = item
+	c.indexLocked(key, item)
+	if c.bloom != nil {
+		c.bloom.add(key)
+	}
+	c.notifyLocked(EventSet, key, item.value)
+	if c.policy == nil {
+		return
+	}
+
+	if exists {
+		c.policy.OnAccess(key)
+		c.costLocked(key, item)
+		return
+	}
+
+	if len(c.cleared) > 0 {
+		c.reportClearedLocked(key)
+	}
+	c.policy.OnAdd(key)
+	c.costLocked(key, item)
+	if c.capacity <= 0 || len(c.items) <= c.capacity {
+		return
+	}
+
+	// Once over capacity, evict a whole batch so the next evictBatch-1 inserts fit without evicting.
+	target := c.capacity - c.evictBatch + 1
+	for len(c.items) > target {
+		victim, ok := c.victimLocked()
+		if ok && victim == key && c.pinned == len(c.items)-1 {
+			// Every other item is pinned, so the cache is full rather than the new item being the one to drop.
+			ok = false
+		}
+		if !ok && c.fullBehavior == FullEvictLowestPriority {
+			victim, ok = c.forcedVictimLocked(key)
+		}
+		if !ok {
+			break
+		}
+		if _, ok := c.items[victim]; ok {
+			c.removeLocked(victim, EventEvict)
+		} else {
+			c.forgetVictimLocked(victim)
+		}
+	}
+}
+
+// EvictN evicts at most n items in the order of the cache's eviction policy and returns the number of items evicted.
+// Pinned items are not evicted. A cache without an eviction policy, which unbounded caches have unless
+// CacheConfig.Policy is set, evicts the items that were set the longest time ago.
+func (c *inMemoryCache) EvictN(n int) int {
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	defer c.unlock()
+
+	if c.policy == nil {
+		return c.evictOldestLocked(n)
+	}
+
+	evicted := 0
+	for evicted < n {
+		victim, ok := c.victimLocked()
+		if !ok {
+			break
+		}
+		if _, ok := c.items[victim]; !ok {
+			c.forgetVictimLocked(victim)
+			continue
+		}
+		c.removeLocked(victim, EventEvict)
+		evicted++
+	}
+
+	return evicted
+}
+
+// evictOldestLocked evicts at most n unpinned items, oldest first, and returns the number of items evicted.
+// It sorts all the unpinned items, so it is meant for occasional use by caches without an eviction policy.
+// The caller must hold the write lock.
+func (c *inMemoryCache) evictOldestLocked(n int) int {
+	if n <= 0 {
+		return 0
+	}
+
+	type aged struct {
+		key     string
+		created time.Time
+	}
+	candidates := make([]aged, 0, len(c.items)-c.pinned)
+	for key, item := range c.items {
+		if !item.pinned {
+			candidates = append(candidates, aged{key: key, created: item.created})
+		}
+	}
+	slices.SortFunc(candidates, func(a, b aged) int {
+		return a.created.Compare(b.created)
+	})
+
+	candidates = candidates[:min(n, len(candidates))]
+	for _, candidate := range candidates {
+		c.removeLocked(candidate.key, EventEvict)
+	}
+
+	return len(candidates)
+}
+
+// Len returns the number of items stored, including expired items not yet removed.
+func (c *inMemoryCache) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return len(c.items)
+}
+
+// costLocked reports the cost and size of the item stored under key to a cost-aware policy.
+// The caller must hold the write lock.
+func (c *inMemoryCache) costLocked(key string, item cachedItem) {
+	if p, ok := c.policy.(CostAwarePolicy); ok {
+		p.OnCost(key, item.cost, item.size)
+	}
+}
+
+// valueSize returns the length of string and []byte values, and 1 for other values.
+func valueSize(value any) int {
+	switch v := value.(type) {
+	case string:
+		return len(v)
+	case []byte:
+		return len(v)
+	default:
+		return 1
+	}
+}
+
+// indexLocked adds the item stored under key to the priority and tier indexes and to the total size.
+// The caller must hold the write lock.
+func (c *inMemoryCache) indexLocked(key string, item cachedItem) {
+	c.bytes += int64(item.size)
+	if c.policy != nil {
+		c.priorities[item.priority]++
+	}
+	if item.pinned {
+		c.pinned++
+	}
+
+	if item.tier != "" {
+		if c.tiers == nil {
+			c.tiers = make(map[string]map[string]struct{})
+		}
+		if c.tiers[item.tier] == nil {
+			c.tiers[item.tier] = make(map[string]struct{})
+		}
+		c.tiers[item.tier][key] = struct{}{}
+	}
+}
+
+// unindexLocked removes the item stored under key from the priority and tier indexes and from the total size.
+// The caller must hold the write lock.
+func (c *inMemoryCache) unindexLocked(key string, item cachedItem) {
+	c.bytes -= int64(item.size)
+	if c.policy != nil {
+		c.priorities[item.priority]--
+		if c.priorities[item.priority] <= 0 {
+			delete(c.priorities, item.priority)
+		}
+	}
+	if item.pinned {
+		c.pinned--
+	}
+
+	if item.tier != "" {
+		delete(c.tiers[item.tier], key)
+		if len(c.tiers[item.tier]) == 0 {
+			delete(c.tiers, item.tier)
+		}
+	}
+}
+
+// victimLocked returns the next key to evict: the first key in the policy's order among the unpinned items
+// with the lowest priority. If the policy is not an OrderedPolicy, it returns the policy's Victim unless it is pinned.
+// The caller must hold the write lock.
+func (c *inMemoryCache) victimLocked() (string, bool) {
+	ordered, ok := c.policy.(OrderedPolicy)
+	if !ok {
+		victim, ok := c.policy.Victim()
+		if item, stored := c.items[victim]; ok && stored && item.pinned {
+			return "", false
+		}
+		return victim, ok
+	}
+	if c.pinned > 0 {
+		return c.unpinnedVictimLocked(ordered)
+	}
+	if len(c.priorities) <= 1 {
+		return c.policy.Victim()
+	}
+
+	lowest := c.lowestPriorityLocked()
+	for key := range ordered.Candidates() {
+		if item, ok := c.items[key]; ok && item.priority == lowest {
+			return key, true
+		}
+	}
+
+	return c.policy.Victim()
+}
+
+// unpinnedVictimLocked is victimLocked for a cache that holds pinned items.
+// It returns ("", false) if all the items tracked by the policy are pinned. The caller must hold the write lock.
+func (c *inMemoryCache) unpinnedVictimLocked(ordered OrderedPolicy) (string, bool) {
+	lowest := c.lowestPriorityLocked()
+
+	var (
+		victim string
+		best   int
+		found  bool
+	)
+	for key := range ordered.Candidates() {
+		item, ok := c.items[key]
+		if !ok || item.pinned {
+			continue
+		}
+		if item.priority == lowest {
+			return key, true
+		}
+		if !found || item.priority < best {
+			victim, best, found = key, item.priority, true
+		}
+	}
+
+	return victim, found
+}
+
+// lowestPriorityLocked returns the lowest priority of the stored items. The caller must hold the write lock.
+func (c *inMemoryCache) lowestPriorityLocked() int {
+	lowest := 0
+	first := true
+	for priority := range c.priorities {
+		if first || priority < lowest {
+			lowest = priority
+			first = false
+		}
+	}
+
+	return lowest
+}
+
+// Pin protects the live item stored under key from eviction until Unpin is called.
+// A pinned item still expires, and overwriting it keeps it pinned.
+// If a bounded cache is full and all its items are pinned, the cache's FullBehavior applies.
+// Returns false if the key does not exist or is expired.
+func (c *inMemoryCache) Pin(key string) bool {
+	return c.setPinned(key, true)
+}
+
+// Unpin makes the live item stored under key evictable again.
+// Returns false if the key does not exist or is expired.
+func (c *inMemoryCache) Unpin(key string) bool {
+	return c.setPinned(key, false)
+}
+
+// setPinned sets whether the live item stored under key is pinned.
+func (c *inMemoryCache) setPinned(key string, pinned bool) bool {
+	key = c.normalizeKey(key)
+
+	c.mu.Lock()
+	defer c.unlock()
+
+	item, ok := c.items[key]
+	if !ok || c.isExpired(item) {
+		return false
+	}
+
+	if item.pinned != pinned {
+		item.pinned = pinned
+		c.items[key] = item
+		if pinned {
+			c.pinned++
+		} else {
+			c.pinned--
+		}
+	}
+
+	return true
+}
+
+// SetTTLByPrefix updates the TTL of all live items whose key starts with prefix.
+// If ttl <= 0, the matching items no longer expire.
+func (c *inMemoryCache) SetTTLByPrefix(prefix string, ttl time.Duration) int {
+	prefix = c.normalizeKey(prefix)
+
+	c.mu.Lock()
+	defer c.unlock()
+
+	var expiration time.Time
+	if ttl > 0 {
+		expiration = c.now().Add(ttl)
+	}
+
+	updated := 0
+	for key, item := range c.items {
+		if !strings.HasPrefix(key, prefix) || c.isExpired(item) {
+			continue
+		}
+		item.expiration = expiration
+		c.items[key] = item
+		updated++
+	}
+
+	return updated
+}
+
+// NamespaceBytes returns the total size, as measured by the cache's sizer, of the live items whose key starts with prefix.
+// It visits every item, so its cost grows with the size of the cache.
+func (c *inMemoryCache) NamespaceBytes(prefix string) int64 {
+	prefix = c.normalizeKey(prefix)
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var total int64
+	for key, item := range c.items {
+		if strings.HasPrefix(key, prefix) && !c.isExpired(item) {
+			total += int64(item.size)
+		}
+	}
+
+	return total
+}
+
+// Increment adds delta to the int64 value stored under the specified key under the write lock.
+// A missing or expired key is treated as zero and stored without expiration.
+func (c *inMemoryCache) Increment(key string, delta int64) (int64, error) {
+	key = c.normalizeKey(key)
+
+	c.mu.Lock()
+	defer c.unlock()
+
+	item, ok := c.items[key]
+	if !ok || c.isExpired(item) {
+		c.removeLocked(key, EventExpire)
+		if err := c.storeLocked(key, c.newItem(delta, 0)); err != nil {
+			return 0, err
+		}
+		return delta, nil
+	}
+
+	current, ok := item.value.(int64)
+	if !ok {
+		return 0, fmt.Errorf("%w: key %q holds %T, not int64", ErrWrongType, key, item.value)
+	}
+
+	item.value = current + delta
+	if err := c.storeLocked(key, item); err != nil {
+		return 0, err
+	}
+
+	return current + delta, nil
+}
+
+// IncrementFloat adds delta to the float64 value stored under the specified key under the write lock.
+// A missing or expired key is treated as zero and stored without expiration.
+func (c *inMemoryCache) IncrementFloat(key string, delta float64) (float64, error) {
+	key = c.normalizeKey(key)
+
+	c.mu.Lock()
+	defer c.unlock()
+
+	item, ok := c.items[key]
+	if !ok || c.isExpired(item) {
+		c.removeLocked(key, EventExpire)
+		if err := c.storeLocked(key, c.newItem(delta, 0)); err != nil {
+			return 0, err
+		}
+		return delta, nil
+	}
+
+	current, ok := item.value.(float64)
+	if !ok {
+		return 0, fmt.Errorf("%w: key %q holds %T, not float64", ErrWrongType, key, item.value)
+	}
+
+	item.value = current + delta
+	if err := c.storeLocked(key, item); err != nil {
+		return 0, err
+	}
+
+	return current + delta, nil
+}
+
+// keysChanChunk is the number of keys KeysChan copies under a single hold of the read lock.
+const keysChanChunk = 256
+
+// KeysChan streams the keys of all live items from a background task until every key has been sent or ctx is done,
+// so no slice of all keys is built. The task copies up to keysChanChunk keys at a time under the read lock and
+// sends them with the lock released, so writers are not blocked by a slow receiver, and the receiver may call
+// back into the cache. The stream is live rather than a snapshot: every key stored for its whole duration is sent
+// once, while keys stored or removed in the meantime may or may not be sent, and a sent key may have been
+// removed by the time it is received. The channel is closed when the stream ends.
+func (c *inMemoryCache) KeysChan(ctx context.Context) <-chan string {
+	keys := make(chan string)
+
+	c.pool.goTask(func() {
+		defer close(keys)
+
+		// The iteration is resumed for every chunk. Go allows a map to be modified between the steps
+		// of an iteration, and the read lock orders each resumption after the writes made meanwhile.
+		c.mu.RLock()
+		next, stop := iter.Pull2(maps.All(c.items))
+		c.mu.RUnlock()
+		defer func() {
+			c.mu.RLock()
+			stop()
+			c.mu.RUnlock()
+		}()
+
+		chunk := make([]string, 0, keysChanChunk)
+		for more := true; more; {
+			chunk = chunk[:0]
+
+			c.mu.RLock()
+			for len(chunk) < keysChanChunk {
+				key, _, ok := next()
+				if !ok {
+					more = false
+					break
+				}
+				// Look the key up again, since Clear and Compact replace the map being iterated.
+				if item, ok := c.items[key]; ok && !c.isExpired(item) {
+					chunk = append(chunk, key)
+				}
+			}
+			c.mu.RUnlock()
+
+			for _, key := range chunk {
+				select {
+				case keys <- key:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	})
+
+	return keys
+}
+
+// Entries returns a snapshot of all live items in the cache. Expired items are excluded.
+func (c *inMemoryCache) Entries() []Entry {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	entries := make([]Entry, 0, len(c.items))
+	for key, item := range c.items {
+		if c.isExpired(item) {
+			continue
+		}
+		entries = append(entries, Entry{
+			Key:       key,
+			Value:     c.copyValue(item.value),
+			ExpiresAt: item.expiration,
+		})
+	}
+
+	return entries
+}
+
+// ExpiringWithin returns the keys of the live items that expire less than d from now, in no particular order.
+// Items without expiration are never included.
+func (c *inMemoryCache) ExpiringWithin(d time.Duration) []string {
+	now := c.now()
+	deadline := now.Add(d)
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var keys []string
+	for key, item := range c.items {
+		if item.expiration.IsZero() || c.isExpired(item) || !item.expiration.Before(deadline) {
+			continue
+		}
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+// Oldest returns the live item that was set the longest time ago.
+func (c *inMemoryCache) Oldest() (string, any, bool) {
+	return c.findByCreated(func(candidate, best time.Time) bool { return candidate.Before(best) })
+}
+
+// Newest returns the live item that was set most recently.
+func (c *inMemoryCache) Newest() (string, any, bool) {
+	return c.findByCreated(func(candidate, best time.Time) bool { return candidate.After(best) })
+}
+
+// findByCreated returns the live item whose set time is preferred over all others by better.
+func (c *inMemoryCache) findByCreated(better func(candidate, best time.Time) bool) (string, any, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var (
+		bestKey  string
+		bestItem cachedItem
+		found    bool
+	)
+	for key, item := range c.items {
+		if c.isExpired(item) {
+			continue
+		}
+		if !found || better(item.created, bestItem.created) {
+			bestKey, bestItem, found = key, item, true
+		}
+	}
+	if !found {
+		return "", nil, false
+	}
+
+	return bestKey, c.copyValue(bestItem.value), true
+}
+
+// CountFunc returns the number of live items for which predicate returns true. Expired items are skipped.
+func (c *inMemoryCache) CountFunc(predicate func(key string, value any) bool) int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	count := 0
+	for key, item := range c.items {
+		if !c.isExpired(item) && predicate(key, settledValue(item.value)) {
+			count++
+		}
+	}
+
+	return count
+}
+
+// Delete removes the item associated with the specified key from the cache.
+func (c *inMemoryCache) Delete(key string) {
+	key = c.normalizeKey(key)
+
+	c.mu.Lock()
+	defer c.unlock()
+
+	c.removeLocked(key, EventDelete)
+}
+
+// Migrate moves the value stored under oldKey to newKey as transformed by transform, under a single write lock,
+// so concurrent readers see either the old key or the new one. The new item gets the TTL returned by transform
+// and the default priority and tier. transform runs under the write lock and must not call back into the cache.
+// Returns false, without calling transform, if oldKey does not exist or is expired. The new item is stored before
+// oldKey is removed, so a full cache may evict another item for it. If the cache rejects the new item, as SetChecked
+// would, or EmptyValueDeletes is set and the new value is "", oldKey is kept and Migrate returns false.
+func (c *inMemoryCache) Migrate(oldKey, newKey string, transform func(old any) (any, time.Duration)) bool {
+	oldKey, newKey = c.normalizeKey(oldKey), c.normalizeKey(newKey)
+
+	c.mu.Lock()
+	defer c.unlock()
+
+	item, ok := c.items[oldKey]
+	if !ok {
+		return false
+	}
+	if c.isExpired(item) {
+		c.removeLocked(oldKey, EventExpire)
+		return false
+	}
+
+	value, ttl := transform(c.copyValue(item.value))
+	if c.emptyValueDeletes && value == "" {
+		return false
+	}
+	if err := c.storeLocked(newKey, c.newItem(value, ttl)); err != nil {
+		return false
+	}
+	if newKey != oldKey {
+		c.removeLocked(oldKey, EventDelete)
+	}
+
+	return true
+}
+
+// DeleteMulti removes the items associated with keys under a single write lock and returns the keys,
+// as passed in, whose items were live. Expired items are removed as expirations and their keys are not returned.
+func (c *inMemoryCache) DeleteMulti(keys []string) []string {
+	c.mu.Lock()
+	defer c.unlock()
+
+	var deleted []string
+	for _, key := range keys {
+		normalized := c.normalizeKey(key)
+		item, ok := c.items[normalized]
+		if !ok {
+			continue
+		}
+
+		if c.isExpired(item) {
+			c.removeLocked(normalized, EventExpire)
+			continue
+		}
+		c.removeLocked(normalized, EventDelete)
+		deleted = append(deleted, key)
+	}
+
+	return deleted
+}
+
+// deleteExpired removes the item associated with the specified key if it is still expired.
+// The check is repeated under the write lock because the key may have been set again in the meantime.
+func (c *inMemoryCache) deleteExpired(key string) {
+	c.mu.Lock()
+	defer c.unlock()
+
+	if item, ok := c.items[key]; ok && c.isExpired(item) {
+		c.removeLocked(key, EventExpire)
+	}
+}
+
+// removeLocked removes the item associated with the specified key from the items, the indexes, and the eviction policy,
+// and notifies the key's watchers with an event of the given type. An item invalidated by InvalidateAll is never
+// removed as an expiration; it is notified as deleted. The caller must hold the write lock.
+func (c *inMemoryCache) removeLocked(key string, reason EventType) {
+	if c.debounce > 0 {
+		c.forgetDebounceLocked(key)
+	}
+
+	item, ok := c.items[key]
+	if !ok {
+		return
+	}
+
+	delete(c.items, key)
+	c.unindexLocked(key, item)
+	if c.policy != nil {
+		c.policy.OnRemove(key)
+	}
+	if reason == EventExpire && item.invalidated() {
+		reason = EventDelete
+	}
+	c.notifyLocked(reason, key, item.value)
+	if reason == EventExpire {
+		c.recordExpiryLocked(item)
+		c.queueExpireCallbackLocked(item)
+	}
+}
+
+// recordExpiryLocked records how long the expired item stayed stored past its expiration.
+// Items removed before their expiration, such as by RemoveExpiredBefore with a future time, count with no lag.
+// The caller must hold the write lock.
+func (c *inMemoryCache) recordExpiryLocked(item cachedItem) {
+	lag := max(c.now().Sub(item.expiration), 0)
+
+	c.expired++
+	c.expiredBytes += int64(item.size)
+	c.expiryLagSum += lag
+	c.expiryLagMax = max(c.expiryLagMax, lag)
+}
+
+// queueExpireCallbackLocked queues the expiration callback of item, if it has one, to run after the write lock is released.
+// The caller must hold the write lock.
+func (c *inMemoryCache) queueExpireCallbackLocked(item cachedItem) {
+	if item.onExpire == nil {
+		return
+	}
+
+	onExpire, value := item.onExpire, c.copyValue(item.value)
+	c.expireCallbacks = append(c.expireCallbacks, func() { onExpire(value) })
+}
+
+// unlock releases the write lock and then runs the expiration callbacks queued while it was held,
+// so callbacks can safely call back into the cache.
+func (c *inMemoryCache) unlock() {
+	callbacks := c.expireCallbacks
+	c.expireCallbacks = nil
+	c.mu.Unlock()
+
+	for _, callback := range callbacks {
+		c.runCallback(callback)
+	}
+}
+
+// runCallback runs an expiration callback, recovering a panic from it unless the cache propagates panics.
+func (c *inMemoryCache) runCallback(callback func()) {
+	defer recoverCallback(c.logger, c.propagatePanics, "expiration callback", nil)
+
+	callback()
+}
+
+// notifyLocked sends an event for key to the key's watchers, if there are any.
+// The caller must hold the write lock, so events for a key are delivered in the order the changes happened.
+func (c *inMemoryCache) notifyLocked(eventType EventType, key string, value any) {
+	if !c.watch.watched(key) {
+		return
+	}
+
+	c.watch.emit(CacheEvent{Type: eventType, Key: key, Value: c.copyValue(value)})
+}
+
+// Watch subscribes to the changes of a single key.
+// The returned function unsubscribes and closes the channel; it is safe to call more than once.
+func (c *inMemoryCache) Watch(key string) (<-chan CacheEvent, func()) {
+	return c.watch.watch(c.normalizeKey(key))
+}
+
+// Clear removes all items from the cache.
+// The write lock is only held to swap in an empty map and reset the eviction policy,
+// so concurrent readers are not blocked for time proportional to the size of the cache.
+// A custom eviction policy, which cannot be reset, is told of the removed keys after the swap, under the read lock,
+// so slow OnRemove hooks do not block readers either; Clear returns once the policy has been told of all of them.
+func (c *inMemoryCache) Clear() {
+	c.mu.Lock()
+	_, cleared := c.clearLocked()
+	c.unlock()
+
+	c.reportCleared(cleared)
+}
+
+// ClearAndReturnKeys removes all items from the cache and returns the keys that were live at that moment.
+// Expired items are removed but their keys are not returned. The keys are collected from the detached map
+// after the write lock is released.
+func (c *inMemoryCache) ClearAndReturnKeys() []string {
+	c.mu.Lock()
+	old, cleared := c.clearLocked()
+	c.unlock()
+
+	keys := make([]string, 0, len(old))
+	for key, item := range old {
+		if !c.isExpired(item) {
+			keys = append(keys, key)
+		}
+	}
+	c.reportCleared(cleared)
+
+	return keys
+}
+
+// Drain removes all items from the cache and returns the values of those that were live at that moment.
+// Like ClearAndReturnKeys, it clears the cache under a single write lock, so no write made concurrently
+// is lost: it is either in the returned map or stored in the drained cache.
+func (c *inMemoryCache) Drain() map[string]any {
+	c.mu.Lock()
+	old, cleared := c.clearLocked()
+	c.unlock()
+
+	values := make(map[string]any, len(old))
+	for key, item := range old {
+		if !c.isExpired(item) {
+			values[key] = c.copyValue(item.value)
+		}
+	}
+	c.reportCleared(cleared)
+
+	return values
+}
+
+// Compact rebuilds the items map from the live items.
+// Go maps never shrink, so after deleting most items the map keeps its peak-size backing store until it is replaced.
+func (c *inMemoryCache) Compact() {
+	c.mu.Lock()
+	defer c.unlock()
+
+	for key, item := range c.items {
+		if c.isExpired(item) {
+			c.removeLocked(key, EventExpire)
+		}
+	}
+
+	items := make(map[string]cachedItem, max(len(c.items), c.sizeHint))
+	maps.Copy(items, c.items)
+	c.items = items
+}
+
+// clearLocked replaces the items with an empty map, resets the eviction policy, and returns the old map,
+// which the cache no longer references. A custom eviction policy cannot be reset, so clearLocked registers
+// the removed keys and returns them; the caller must pass them to reportCleared after releasing the lock.
+// The caller must hold the write lock.
+func (c *inMemoryCache) clearLocked() (map[string]cachedItem, *clearedKeys) {
+	for _, key := range c.watch.keys() {
+		if item, ok := c.items[key]; ok {
+			c.notifyLocked(EventDelete, key, item.value)
+		}
+	}
+
+	var cleared *clearedKeys
+	if c.policy != nil {
+		if p, ok := c.policy.(resettablePolicy); ok {
+			p.reset()
+		} else if len(c.items) > 0 {
+			cleared = &clearedKeys{items: c.items, reported: make(map[string]struct{})}
+			c.cleared = append(c.cleared, cleared)
+		}
+		clear(c.priorities)
+	}
+
+	for key := range c.debounced {
+		c.forgetDebounceLocked(key)
+	}
+
+	if c.bloom != nil {
+		c.bloom.reset()
+	}
+
+	old := c.items
+	c.pinned = 0
+	c.bytes = 0
+	c.tiers = nil
+	c.items = make(map[string]cachedItem, c.sizeHint)
+
+	return old, cleared
+}
+
+// clearChunk is the number of removed keys reportCleared tells a custom eviction policy of
+// under a single hold of the read lock.
+const clearChunk = 64
+
+// clearedKeys holds the keys removed by a Clear that a custom eviction policy has not all been told of yet.
+type clearedKeys struct {
+	// items is the map the Clear removed. It is only read, so it can be ranged over without the lock.
+	items map[string]cachedItem
+	// mu guards reported, which reportCleared updates under the read lock.
+	mu sync.Mutex
+	// reported holds the keys the policy has been told of.
+	reported map[string]struct{}
+}
+
+// report marks key as reported and returns true, or returns false if it was reported already.
+func (k *clearedKeys) report(key string) bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if _, ok := k.reported[key]; ok {
+		return false
+	}
+	k.reported[key] = struct{}{}
+
+	return true
+}
+
+// pending reports whether key was removed by the Clear and not reported yet.
+func (k *clearedKeys) pending(key string) bool {
+	if _, ok := k.items[key]; !ok {
+		return false
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	_, reported := k.reported[key]
+	return !reported
+}
+
+// reportCleared tells the eviction policy of the keys removed by a Clear, clearChunk keys at a time.
+// It holds the read lock, which excludes writers but not readers, so readers run alongside the policy's
+// OnRemove calls, and writers wait for at most one chunk. Keys reported earlier, because they were stored
+// again or offered as victims meanwhile, are skipped. cleared may be nil.
+func (c *inMemoryCache) reportCleared(cleared *clearedKeys) {
+	if cleared == nil {
+		return
+	}
+
+	chunk := make([]string, 0, clearChunk)
+	report := func() {
+		c.mu.RLock()
+		defer c.mu.RUnlock()
+
+		for _, key := range chunk {
+			if cleared.report(key) {
+				c.policy.OnRemove(key)
+			}
+		}
+		chunk = chunk[:0]
+	}
+	for key := range cleared.items {
+		if chunk = append(chunk, key); len(chunk) == clearChunk {
+			report()
+		}
+	}
+	report()
+
+	c.mu.Lock()
+	defer c.unlock()
+
+	c.cleared = slices.DeleteFunc(c.cleared, func(k *clearedKeys) bool { return k == cleared })
+}
+
+// reportClearedLocked tells the eviction policy that key was removed, if a Clear removed it and has not
+// told the policy yet, so that the policy stops tracking it before it is stored again. Reports whether
+// key was removed by a Clear in progress. The caller must hold the write lock.
+func (c *inMemoryCache) reportClearedLocked(key string) bool {
+	removed := false
+	for _, cleared := range c.cleared {
+		if _, ok := cleared.items[key]; !ok {
+			continue
+		}
+		removed = true
+		// A key stored again and cleared again is in several Clears; only the last one has not reported it.
+		if cleared.report(key) {
+			c.policy.OnRemove(key)
+			break
+		}
+	}
+
+	return removed
+}
+
+// forgetVictimLocked stops the eviction policy tracking victim, a key it offered that is no longer stored.
+// The caller must hold the write lock.
+func (c *inMemoryCache) forgetVictimLocked(victim string) {
+	if !c.reportClearedLocked(victim) {
+		c.policy.OnRemove(victim)
+	}
+}
+
+// Stats returns the current size of the cache and its hit and miss counters.
+func (c *inMemoryCache) Stats() Stats {
+	c.mu.RLock()
+	stats := Stats{
+		Size:         len(c.items),
+		Hits:         c.hits.Load(),
+		Misses:       c.misses.Load(),
+		Bytes:        c.bytes,
+		Backlog:      c.lastBacklog,
+		Expired:      c.expired,
+		ExpiredBytes: c.expiredBytes,
+		ExpiryLagMax: c.expiryLagMax,
+	}
+	if c.expired > 0 {
+		stats.ExpiryLagAvg = c.expiryLagSum / time.Duration(c.expired)
+	}
+	c.mu.RUnlock()
+
+	return stats
+}
+
+// RemoveExpired deletes all expired items from the cache and returns the number of items removed.
+func (c *inMemoryCache) RemoveExpired() int {
+	return c.RemoveExpiredBefore(c.now())
+}
+
+// RemoveExpiredSized deletes all expired items from the cache and returns the number of items removed
+// and their total size.
+func (c *inMemoryCache) RemoveExpiredSized() (int, int64) {
+	return c.removeExpiredBefore(c.now())
+}
+
+// RemoveExpiredBefore deletes all items whose expiration is before t and returns the number of items removed.
+func (c *inMemoryCache) RemoveExpiredBefore(t time.Time) int {
+	removed, _ := c.removeExpiredBefore(t)
+	return removed
+}
+
+// RemoveExpiredN deletes at most n expired items from the cache and returns the number of items removed
+// and their total size. The remaining expired items are left for a later call. If n <= 0, nothing is removed.
+func (c *inMemoryCache) RemoveExpiredN(n int) (int, int64) {
+	c.mu.Lock()
+	defer c.unlock()
+
+	now := c.now()
+	c.checkBacklogLocked(now)
+	removed, size := 0, int64(0)
+	for key, item := range c.items {
+		if removed >= n {
+			break
+		}
+		if c.isExpiredAt(item, now) {
+			c.removeLocked(key, EventExpire)
+			removed++
+			size += int64(item.size)
+		}
+	}
+
+	return removed, size
+}
+
+// removeExpiredBefore deletes all items whose expiration is before t, and the items invalidated by InvalidateAll,
+// and returns the number of items removed and their total size. While expiration is suspended, only the
+// invalidated items are removed.
+func (c *inMemoryCache) removeExpiredBefore(t time.Time) (int, int64) {
+	c.mu.Lock()
+	defer c.unlock()
+
+	now := c.now()
+	suspended := c.expirySuspended.Load()
+	backlog, removed, size := 0, 0, int64(0)
+	for key, item := range c.items {
+		if c.isExpiredAt(item, now) {
+			backlog++
+		}
+		if item.invalidated() || !suspended && !item.expiration.IsZero() && item.expiration.Before(t) {
+			c.removeLocked(key, EventExpire)
+			removed++
+			size += int64(item.size)
+		}
+	}
+	c.reportBacklogLocked(backlog)
+
+	return removed, size
+}
+
+// RemoveExpiredFunc calls fn with the key and value of every expired item, then removes them and returns
+// the number of items removed and their total size. fn runs without the cache's lock, so it may call back
+// into the cache. An item that is overwritten while fn runs is kept, and an item that expires meanwhile
+// is left for the next call, so fn sees every removed item exactly once. Items invalidated by InvalidateAll
+// are removed without being passed to fn.
+func (c *inMemoryCache) RemoveExpiredFunc(fn func(key string, value any)) (int, int64) {
+	type expiredItem struct {
+		key  string
+		item cachedItem
+	}
+
+	c.mu.Lock()
+	now := c.now()
+	var expired []expiredItem
+	invalidated := 0
+	for key, item := range c.items {
+		if item.invalidated() {
+			c.removeLocked(key, EventExpire)
+			invalidated++
+		} else if c.isExpiredAt(item, now) {
+			expired = append(expired, expiredItem{key: key, item: item})
+		}
+	}
+	c.reportBacklogLocked(invalidated + len(expired))
+	c.unlock()
+
+	for _, e := range expired {
+		fn(e.key, c.copyValue(e.item.value))
+	}
+
+	c.mu.Lock()
+	defer c.unlock()
+
+	removed, size := 0, int64(0)
+	for _, e := range expired {
+		// Overwriting an expired item gives the new item its own access counter.
+		if item, ok := c.items[e.key]; ok && item.accesses == e.item.accesses {
+			c.removeLocked(e.key, EventExpire)
+			removed++
+			size += int64(item.size)
+		}
+	}
+
+	return removed, size
+}
+
+// RemoveExpiredKeys deletes all expired items from the cache and returns their keys.
+func (c *inMemoryCache) RemoveExpiredKeys() []string {
+	c.mu.Lock()
+	defer c.unlock()
+
+	var keys []string
+	for key, item := range c.items {
+		if c.isExpired(item) {
+			c.removeLocked(key, EventExpire)
+			keys = append(keys, key)
+		}
+	}
+	c.reportBacklogLocked(len(keys))
+
+	return keys
+}
+
+// RemoveExpiredInTier deletes the expired items tagged with the given tier and returns the number of items removed.
+// Only the items of that tier are visited.
+func (c *inMemoryCache) RemoveExpiredInTier(tier string) int {
+	c.mu.Lock()
+	defer c.unlock()
+
+	c.checkBacklogLocked(c.now())
+	removed := 0
+	for key := range c.tiers[tier] {
+		if c.isExpired(c.items[key]) {
+			c.removeLocked(key, EventExpire)
+			removed++
+		}
+	}
 
-	c.items = make(map[string]cachedItem)
+	return removed
 }
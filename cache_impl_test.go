@@ -0,0 +1,1053 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeClock is a Clock that only moves when the test advances it.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+// Advance moves the clock forward by d.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+// benchKeys returns n distinct keys, built up front so that benchmarks do not measure formatting them.
+func benchKeys(n int) []string {
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key:%d", i)
+	}
+
+	return keys
+}
+
+func TestNewCacheWithSizeAllocatesLess(t *testing.T) {
+	keys := benchKeys(10_000)
+	fill := func(c Cache) {
+		for _, key := range keys {
+			c.Set(key, 1)
+		}
+	}
+
+	unsized := testing.AllocsPerRun(5, func() { fill(NewCache()) })
+	sized := testing.AllocsPerRun(5, func() { fill(NewCacheWithSize(len(keys))) })
+	if sized >= unsized {
+		t.Fatalf("bulk insert with a size hint made %.0f allocations, want fewer than %.0f without", sized, unsized)
+	}
+}
+
+func BenchmarkBulkInsert(b *testing.B) {
+	keys := benchKeys(100_000)
+	for _, hint := range []int{0, len(keys)} {
+		b.Run(fmt.Sprintf("hint=%d", hint), func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				c := NewCacheWithSize(hint)
+				for _, key := range keys {
+					c.Set(key, 1)
+				}
+			}
+		})
+	}
+}
+
+func TestSetTTLByPrefix(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCacheWithConfig(CacheConfig{Clock: clock})
+	tc := c.(TTLCache)
+
+	for _, key := range []string{"user:1", "user:2", "user:3"} {
+		c.SetWithTTL(key, key, time.Hour)
+	}
+	c.SetWithTTL("session:1", 1, time.Hour)
+	c.Set("user:forever", 0)
+
+	if n := tc.SetTTLByPrefix("user:", time.Minute); n != 4 {
+		t.Fatalf("SetTTLByPrefix() = %d, want 4", n)
+	}
+	for _, key := range []string{"user:1", "user:2", "user:3", "user:forever"} {
+		if ttl, ok := tc.GetTTL(key); !ok || ttl != time.Minute {
+			t.Fatalf("GetTTL(%s) = %v, %v, want %v, true", key, ttl, ok, time.Minute)
+		}
+	}
+	if ttl, ok := tc.GetTTL("session:1"); !ok || ttl != time.Hour {
+		t.Fatalf("GetTTL(session:1) = %v, %v, want %v, true", ttl, ok, time.Hour)
+	}
+
+	clock.Advance(2 * time.Minute)
+	if _, ok := c.Get("user:1"); ok {
+		t.Fatal("Get(user:1) ok = true after the shortened TTL")
+	}
+	if _, ok := tc.GetTTL("missing"); ok {
+		t.Fatal("GetTTL(missing) ok = true")
+	}
+}
+
+func TestRemoveExpiredBefore(t *testing.T) {
+	clock := newFakeClock()
+	start := clock.Now()
+	c := NewCacheWithConfig(CacheConfig{Clock: clock})
+	c.SetWithTTL("1m", 1, time.Minute)
+	c.SetWithTTL("2m", 2, 2*time.Minute)
+	c.SetWithTTL("3m", 3, 3*time.Minute)
+	c.SetWithTTL("1h", 4, time.Hour)
+	c.Set("forever", 5)
+
+	clock.Advance(5 * time.Minute)
+	grace := start.Add(150 * time.Second)
+	if n := c.(TTLCache).RemoveExpiredBefore(grace); n != 2 {
+		t.Fatalf("RemoveExpiredBefore() = %d, want 2", n)
+	}
+
+	entries := c.(InspectableCache).Entries()
+	if size := c.(StatsReporter).Stats().Size; size != 3 {
+		t.Fatalf("Size = %d after staged cleanup, want 3", size)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Entries() has %d live items, want 2", len(entries))
+	}
+
+	if n := c.(TTLCache).RemoveExpiredBefore(clock.Now()); n != 1 {
+		t.Fatalf("second RemoveExpiredBefore() = %d, want 1", n)
+	}
+}
+
+func TestGetAndRefresh(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCacheWithConfig(CacheConfig{Clock: clock})
+	tc := c.(TTLCache)
+	c.SetWithTTL("a", 1, time.Minute)
+
+	clock.Advance(50 * time.Second)
+	if value, ok := tc.GetAndRefresh("a", time.Hour); !ok || value != 1 {
+		t.Fatalf("GetAndRefresh(a) = %v, %v, want 1, true", value, ok)
+	}
+	if ttl, _ := tc.GetTTL("a"); ttl != time.Hour {
+		t.Fatalf("GetTTL(a) = %v after refresh, want %v", ttl, time.Hour)
+	}
+
+	clock.Advance(30 * time.Minute)
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("Get(a) ok = false past the original TTL")
+	}
+}
+
+func TestGetAndRefreshPerCallTTL(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCacheWithConfig(CacheConfig{Clock: clock})
+	tc := c.(TTLCache)
+	c.SetWithTTL("a", 1, time.Minute)
+
+	// Each caller sets the deadline from its own TTL, shortening it as well as extending it.
+	for _, ttl := range []time.Duration{time.Hour, 10 * time.Second, 5 * time.Minute} {
+		if _, ok := tc.GetAndRefresh("a", ttl); !ok {
+			t.Fatalf("GetAndRefresh(a, %v) ok = false", ttl)
+		}
+		if got, _ := tc.GetTTL("a"); got != ttl {
+			t.Fatalf("GetTTL(a) = %v after GetAndRefresh(a, %v), want the caller's TTL", got, ttl)
+		}
+		clock.Advance(time.Second)
+	}
+
+	tc.GetAndRefresh("a", 10*time.Second)
+	clock.Advance(11 * time.Second)
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("Get(a) ok = true past the deadline set by the last caller")
+	}
+}
+
+func TestGetWithRefreshTTL(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCacheWithConfig(CacheConfig{Clock: clock})
+	tc := c.(TTLCache)
+	c.SetWithTTL("a", 1, time.Minute)
+
+	for _, ttl := range []time.Duration{time.Hour, 10 * time.Second} {
+		if value, ok := tc.GetWithRefreshTTL("a", ttl); !ok || value != 1 {
+			t.Fatalf("GetWithRefreshTTL(a, %v) = %v, %v, want 1, true", ttl, value, ok)
+		}
+		if got, _ := tc.GetTTL("a"); got != ttl {
+			t.Fatalf("GetTTL(a) = %v after GetWithRefreshTTL(a, %v), want the caller's TTL", got, ttl)
+		}
+	}
+
+	clock.Advance(11 * time.Second)
+	if value, ok := tc.GetWithRefreshTTL("a", time.Hour); ok {
+		t.Fatalf("GetWithRefreshTTL(a) = %v, true past the deadline set by the last caller", value)
+	}
+}
+
+func TestGetAndRefreshDoesNotResurrect(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCacheWithConfig(CacheConfig{Clock: clock})
+	c.SetWithTTL("a", 1, time.Minute)
+
+	clock.Advance(2 * time.Minute)
+	if value, ok := c.(TTLCache).GetAndRefresh("a", time.Hour); ok {
+		t.Fatalf("GetAndRefresh(a) = %v, true for an expired key", value)
+	}
+	if _, ok := c.(TTLCache).GetAndRefresh("missing", time.Hour); ok {
+		t.Fatal("GetAndRefresh(missing) ok = true")
+	}
+	if size := c.(StatsReporter).Stats().Size; size != 0 {
+		t.Fatalf("Size = %d, want the expired item removed", size)
+	}
+}
+
+func TestEntries(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCacheWithConfig(CacheConfig{Clock: clock})
+	c.Set("forever", 1)
+	c.SetWithTTL("hour", 2, time.Hour)
+	c.SetWithTTL("expired", 3, time.Second)
+	clock.Advance(time.Minute)
+
+	entries := c.(InspectableCache).Entries()
+	slices.SortFunc(entries, func(a, b Entry) int { return strings.Compare(a.Key, b.Key) })
+
+	start := clock.Now().Add(-time.Minute)
+	want := []Entry{
+		{Key: "forever", Value: 1},
+		{Key: "hour", Value: 2, ExpiresAt: start.Add(time.Hour)},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("Entries() = %v, want %v", entries, want)
+	}
+	for i := range want {
+		if entries[i].Key != want[i].Key || entries[i].Value != want[i].Value || !entries[i].ExpiresAt.Equal(want[i].ExpiresAt) {
+			t.Fatalf("Entries()[%d] = %+v, want %+v", i, entries[i], want[i])
+		}
+	}
+}
+
+func TestClearAndReturnKeys(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCacheWithConfig(CacheConfig{Clock: clock})
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.SetWithTTL("expired", 3, time.Second)
+	clock.Advance(time.Minute)
+
+	keys := c.(BulkCache).ClearAndReturnKeys()
+	slices.Sort(keys)
+	if !slices.Equal(keys, []string{"a", "b"}) {
+		t.Fatalf("ClearAndReturnKeys() = %q, want [a b]", keys)
+	}
+	if size := c.(StatsReporter).Stats().Size; size != 0 {
+		t.Fatalf("Size = %d after ClearAndReturnKeys, want 0", size)
+	}
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("Get(a) ok = true after ClearAndReturnKeys")
+	}
+}
+
+func TestDrainDoesNotBlockReaders(t *testing.T) {
+	// The copy function blocks on the drained value, so Drain stays busy with the detached map until released.
+	var draining atomic.Bool
+	copying := make(chan struct{})
+	release := make(chan struct{})
+	c := NewCacheWithConfig(CacheConfig{CopyFunc: func(value any) any {
+		if value == "slow" && draining.Load() {
+			copying <- struct{}{}
+			<-release
+		}
+		return value
+	}})
+	c.Set("a", "slow")
+	draining.Store(true)
+
+	drained := make(chan map[string]any)
+	go func() {
+		drained <- c.(BulkCache).Drain()
+	}()
+	<-copying
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.Get("a")
+		c.Set("b", 2)
+		c.Get("b")
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("reader blocked while Drain processed the old items")
+	}
+
+	close(release)
+	if values := <-drained; len(values) != 1 || values["a"] != "slow" {
+		t.Fatalf("Drain() = %v, want map[a:slow]", values)
+	}
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("Get(a) ok = true after Drain")
+	}
+}
+
+// blockingRemovePolicy is an LRU policy that cannot be reset, whose OnRemove blocks while blocking is set.
+// If removes is set, it counts the OnRemove calls per key.
+type blockingRemovePolicy struct {
+	OrderedPolicy
+	blocking atomic.Bool
+	removing chan struct{}
+	release  chan struct{}
+	mu       sync.Mutex
+	removes  map[string]int
+}
+
+func (p *blockingRemovePolicy) OnRemove(key string) {
+	if p.removes != nil {
+		p.mu.Lock()
+		p.removes[key]++
+		p.mu.Unlock()
+	}
+	if p.blocking.Load() {
+		select {
+		case p.removing <- struct{}{}:
+		default:
+		}
+		<-p.release
+	}
+	p.OrderedPolicy.OnRemove(key)
+}
+
+func TestClearWithCustomPolicyDoesNotBlockReaders(t *testing.T) {
+	policy := &blockingRemovePolicy{
+		OrderedPolicy: NewLRUPolicy().(OrderedPolicy),
+		removing:      make(chan struct{}),
+		release:       make(chan struct{}),
+	}
+	c := NewBoundedCache(100, policy)
+	for _, key := range benchKeys(10) {
+		c.Set(key, 1)
+	}
+	policy.blocking.Store(true)
+
+	cleared := make(chan struct{})
+	go func() {
+		defer close(cleared)
+		c.Clear()
+	}()
+	<-policy.removing
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.Get("key:1")
+		c.(LookupCache).Has("key:2")
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("reader blocked while Clear told the eviction policy of the removed keys")
+	}
+
+	close(policy.release)
+	<-cleared
+	if size := c.(StatsReporter).Stats().Size; size != 0 {
+		t.Fatalf("Size = %d after Clear, want 0", size)
+	}
+	if err := c.(Verifier).Verify(); err != nil {
+		t.Fatalf("Verify() after Clear: %v", err)
+	}
+}
+
+func TestWritesDuringClearKeepCustomPolicyConsistent(t *testing.T) {
+	policy := &blockingRemovePolicy{OrderedPolicy: NewLRUPolicy().(OrderedPolicy), removes: make(map[string]int)}
+	c := NewBoundedCache(10, policy)
+	for _, key := range benchKeys(10) {
+		c.Set(key, 1)
+	}
+
+	// Swap the map as Clear does, then write before the policy is told of the removed keys.
+	ic := c.(*inMemoryCache)
+	ic.mu.Lock()
+	_, cleared := ic.clearLocked()
+	ic.unlock()
+	for _, key := range []string{"new:0", "new:1", "new:2", "new:3", "new:4", "new:5", "new:6", "new:7", "new:8"} {
+		c.Set(key, 3)
+	}
+	c.Set("key:1", 2)
+	ic.reportCleared(cleared)
+
+	if err := c.(Verifier).Verify(); err != nil {
+		t.Fatalf("Verify() = %v", err)
+	}
+	for _, key := range benchKeys(10) {
+		if n := policy.removes[key]; n != 1 {
+			t.Fatalf("OnRemove(%s) called %d times, want once", key, n)
+		}
+	}
+	c.Set("new:9", 3)
+	if _, ok := c.Get("new:0"); ok {
+		t.Fatal("Get(new:0) found the least recently used key after an eviction")
+	}
+	if v, ok := c.Get("key:1"); !ok || v != 2 {
+		t.Fatalf("Get(key:1) = %v, %v, want 2, true", v, ok)
+	}
+}
+
+// heapInUse returns the heap in use after a garbage collection.
+func heapInUse() uint64 {
+	runtime.GC()
+
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return stats.HeapAlloc
+}
+
+func TestCompactShrinksMap(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCacheWithConfig(CacheConfig{Clock: clock})
+	keys := benchKeys(100_000)
+	for _, key := range keys {
+		c.Set(key, 1)
+	}
+	c.(BulkCache).DeleteMulti(keys[100:])
+	c.SetWithTTL("expired", 1, time.Second)
+	clock.Advance(time.Minute)
+
+	before := heapInUse()
+	c.(BulkCache).Compact()
+	after := heapInUse()
+	if after >= before/2 {
+		t.Fatalf("heap in use went from %d to %d bytes, want the map's backing store released", before, after)
+	}
+
+	for _, key := range keys[:100] {
+		if _, ok := c.Get(key); !ok {
+			t.Fatalf("live key %s lost by Compact", key)
+		}
+	}
+	if size := c.(StatsReporter).Stats().Size; size != 100 {
+		t.Fatalf("Size = %d after Compact, want 100", size)
+	}
+	if err := c.(Verifier).Verify(); err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	runtime.KeepAlive(c)
+}
+
+func TestCountFunc(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCacheWithConfig(CacheConfig{Clock: clock})
+	c.Set("user:1", "alice")
+	c.Set("user:2", "bob")
+	c.Set("count", 1)
+	c.Set("limit", 2)
+	c.SetWithTTL("user:expired", "carol", time.Second)
+	clock.Advance(time.Minute)
+
+	isString := func(_ string, value any) bool {
+		_, ok := value.(string)
+		return ok
+	}
+	if n := c.(InspectableCache).CountFunc(isString); n != 2 {
+		t.Fatalf("CountFunc(isString) = %d, want 2", n)
+	}
+}
+
+func TestGetOrComputeContextWaiterCancel(t *testing.T) {
+	c := NewCache().(ComputeCache)
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	owner := make(chan error, 1)
+	go func() {
+		_, err := c.GetOrComputeContext(context.Background(), "a", func(ctx context.Context) (any, time.Duration, error) {
+			close(started)
+			<-release
+			return "computed", 0, nil
+		})
+		owner <- err
+	}()
+	<-started
+
+	ctx, cancel := context.WithCancel(context.Background())
+	waiter := make(chan error, 1)
+	go func() {
+		_, err := c.GetOrComputeContext(ctx, "a", func(ctx context.Context) (any, time.Duration, error) {
+			t.Error("waiter started a second computation")
+			return nil, 0, nil
+		})
+		waiter <- err
+	}()
+	cancel()
+	if err := <-waiter; !errors.Is(err, context.Canceled) {
+		t.Fatalf("waiter error = %v, want context.Canceled", err)
+	}
+
+	close(release)
+	if err := <-owner; err != nil {
+		t.Fatalf("owner error = %v, want nil", err)
+	}
+	if value, ok := c.Get("a"); !ok || value != "computed" {
+		t.Fatalf("Get(a) = %v, %v, want the value stored by the owner", value, ok)
+	}
+}
+
+func TestGetOrComputeContextDoesNotCacheErrors(t *testing.T) {
+	c := NewCache().(ComputeCache)
+	calls := 0
+	fn := func(ctx context.Context) (any, time.Duration, error) {
+		calls++
+		return nil, 0, errors.New("failed")
+	}
+
+	for range 2 {
+		if _, err := c.GetOrComputeContext(context.Background(), "a", fn); err == nil {
+			t.Fatal("GetOrComputeContext() error = nil, want the error from fn")
+		}
+	}
+	if calls != 2 {
+		t.Fatalf("fn called %d times, want 2", calls)
+	}
+}
+
+func TestSetWithExpireCallback(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCacheWithConfig(CacheConfig{Clock: clock})
+	tc := c.(TTLCache)
+
+	var fired []string
+	record := func(key string) func(any) {
+		return func(value any) { fired = append(fired, fmt.Sprintf("%s=%v", key, value)) }
+	}
+	tc.SetWithExpireCallback("expiring", 1, time.Second, record("expiring"))
+	tc.SetWithExpireCallback("lazy", 2, time.Second, record("lazy"))
+	tc.SetWithExpireCallback("deleted", 3, time.Second, record("deleted"))
+	tc.SetWithExpireCallback("overwritten", 4, time.Second, record("overwritten"))
+	tc.SetWithExpireCallback("fresh", 5, time.Hour, record("fresh"))
+	c.Delete("deleted")
+	c.Set("overwritten", 6)
+
+	clock.Advance(time.Minute)
+	c.Get("lazy")
+	c.(Cleanable).RemoveExpired()
+
+	slices.Sort(fired)
+	if want := []string{"expiring=1", "lazy=2"}; !slices.Equal(fired, want) {
+		t.Fatalf("fired callbacks = %q, want %q", fired, want)
+	}
+}
+
+func TestOldestAndNewest(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCacheWithConfig(CacheConfig{Clock: clock})
+	ic := c.(InspectableCache)
+	if _, _, ok := ic.Oldest(); ok {
+		t.Fatal("Oldest() ok = true for an empty cache")
+	}
+	if _, _, ok := ic.Newest(); ok {
+		t.Fatal("Newest() ok = true for an empty cache")
+	}
+
+	c.SetWithTTL("expired-oldest", 0, time.Second)
+	clock.Advance(time.Minute)
+	for _, key := range []string{"b", "a", "c"} {
+		c.Set(key, key)
+		clock.Advance(time.Second)
+	}
+
+	if key, value, ok := ic.Oldest(); !ok || key != "b" || value != "b" {
+		t.Fatalf("Oldest() = %v, %v, %v, want b, b, true", key, value, ok)
+	}
+	if key, value, ok := ic.Newest(); !ok || key != "c" || value != "c" {
+		t.Fatalf("Newest() = %v, %v, %v, want c, c, true", key, value, ok)
+	}
+}
+
+func TestGetIfFresh(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCacheWithConfig(CacheConfig{Clock: clock})
+	tc := c.(TTLCache)
+	c.SetWithTTL("fresh", 1, time.Hour)
+	c.SetWithTTL("stale", 2, 10*time.Second)
+	c.Set("forever", 3)
+
+	tests := []struct {
+		key   string
+		value any
+		ok    bool
+	}{
+		{key: "fresh", value: 1, ok: true},
+		{key: "stale", value: nil, ok: false},
+		{key: "forever", value: 3, ok: true},
+		{key: "missing", value: nil, ok: false},
+	}
+	for _, tt := range tests {
+		if value, ok := tc.GetIfFresh(tt.key, time.Minute); value != tt.value || ok != tt.ok {
+			t.Fatalf("GetIfFresh(%s, 1m) = %v, %v, want %v, %v", tt.key, value, ok, tt.value, tt.ok)
+		}
+	}
+	if _, ok := c.Get("stale"); !ok {
+		t.Fatal("GetIfFresh removed a live item")
+	}
+}
+
+func TestKeysChanStreamsAllKeys(t *testing.T) {
+	c := NewCache()
+	const keys = 3*keysChanChunk + 7
+	for i := range keys {
+		c.Set(fmt.Sprintf("key:%d", i), i)
+	}
+	c.SetWithTTL("expired", 0, time.Nanosecond)
+	time.Sleep(time.Millisecond)
+
+	seen := make(map[string]bool)
+	for key := range c.(InspectableCache).KeysChan(context.Background()) {
+		if seen[key] {
+			t.Fatalf("key %s sent twice", key)
+		}
+		seen[key] = true
+	}
+	if len(seen) != keys {
+		t.Fatalf("streamed %d keys, want %d", len(seen), keys)
+	}
+	if seen["expired"] {
+		t.Fatal("expired key was streamed")
+	}
+}
+
+func TestKeysChanCancelShutsDown(t *testing.T) {
+	c := NewCache()
+	for i := range 10 * keysChanChunk {
+		c.Set(fmt.Sprintf("key:%d", i), i)
+	}
+	base := runtime.NumGoroutine()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := c.(InspectableCache).KeysChan(ctx)
+	for range 10 {
+		<-stream
+	}
+	cancel()
+
+	deadline := time.After(time.Second)
+	for open := true; open; {
+		select {
+		case _, open = <-stream:
+		case <-deadline:
+			t.Fatal("channel was not closed after cancel")
+		}
+	}
+	waitGoroutines(t, base+1, func() bool { return runtime.NumGoroutine() <= base })
+}
+
+func TestKeysChanDoesNotBlockWriters(t *testing.T) {
+	c := NewCache()
+	for i := range 2 * keysChanChunk {
+		c.Set(fmt.Sprintf("key:%d", i), i)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream := c.(InspectableCache).KeysChan(ctx)
+	key := <-stream
+
+	// The receiver stops reading with the stream open, then writes and reads back through the cache.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.Set("new", 1)
+		c.Delete(key)
+		c.Get("new")
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("writer blocked by an open KeysChan stream")
+	}
+}
+
+func TestKeysChanAfterClear(t *testing.T) {
+	c := NewCache()
+	for i := range 4 * keysChanChunk {
+		c.Set(fmt.Sprintf("key:%d", i), i)
+	}
+
+	stream := c.(InspectableCache).KeysChan(context.Background())
+	<-stream
+	c.Clear()
+
+	// Keys removed by Clear are not streamed after the chunk in flight.
+	count := 0
+	for range stream {
+		count++
+	}
+	if count >= keysChanChunk {
+		t.Fatalf("streamed %d keys after Clear, want fewer than one chunk", count)
+	}
+}
+
+func TestSetGroupExpiresTogether(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCacheWithConfig(CacheConfig{Clock: clock})
+	g := c.(GroupCache)
+	fields := map[string]any{"name": "ann", "age": 30, "email": "ann@example.com"}
+	g.SetGroup("user:1", fields, time.Minute)
+	fields["name"] = "changed"
+
+	got, ok := g.GetGroup("user:1")
+	if !ok || len(got) != 3 || got["name"] != "ann" || got["age"] != 30 {
+		t.Fatalf("GetGroup() = %v, %v, want all three fields as stored", got, ok)
+	}
+	got["age"] = 99
+	if again, _ := g.GetGroup("user:1"); again["age"] != 30 {
+		t.Fatal("mutating a returned group changed the cached fields")
+	}
+
+	clock.Advance(2 * time.Minute)
+	if got, ok := g.GetGroup("user:1"); ok || got != nil {
+		t.Fatalf("GetGroup() = %v, %v after the TTL, want nil, false", got, ok)
+	}
+
+	c.Set("plain", "value")
+	if _, ok := g.GetGroup("plain"); ok {
+		t.Fatal("GetGroup() ok = true for a key not stored by SetGroup")
+	}
+}
+
+func TestDeleteMultiReturnsPresentKeys(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCacheWithConfig(CacheConfig{Clock: clock})
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Set("kept", 3)
+	c.SetWithTTL("expired", 4, time.Second)
+	clock.Advance(time.Minute)
+
+	deleted := c.(BulkCache).DeleteMulti([]string{"missing", "b", "expired", "a", "a"})
+	if !slices.Equal(deleted, []string{"b", "a"}) {
+		t.Fatalf("DeleteMulti() = %q, want [b a]", deleted)
+	}
+	for _, key := range []string{"a", "b", "expired"} {
+		if _, ok := c.Get(key); ok {
+			t.Fatalf("Get(%s) ok = true after DeleteMulti", key)
+		}
+	}
+	if size := c.(StatsReporter).Stats().Size; size != 1 {
+		t.Fatalf("Size = %d, want only kept left", size)
+	}
+	if deleted := c.(BulkCache).DeleteMulti([]string{"missing"}); len(deleted) != 0 {
+		t.Fatalf("DeleteMulti() of absent keys = %q, want none", deleted)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCacheWithConfig(CacheConfig{Clock: clock})
+	b := c.(BulkCache)
+	c.Set("user:v1:1", "ann")
+
+	ok := b.Migrate("user:v1:1", "user:v2:1", func(old any) (any, time.Duration) {
+		return map[string]any{"name": old}, time.Minute
+	})
+	if !ok {
+		t.Fatal("Migrate() = false for a present key")
+	}
+	if _, ok := c.Get("user:v1:1"); ok {
+		t.Fatal("old key still present after Migrate")
+	}
+	value, ok := c.Get("user:v2:1")
+	if !ok || value.(map[string]any)["name"] != "ann" {
+		t.Fatalf("Get(new) = %v, %v, want the transformed value", value, ok)
+	}
+	if ttl, _ := c.(TTLCache).GetTTL("user:v2:1"); ttl != time.Minute {
+		t.Fatalf("GetTTL(new) = %v, want the TTL returned by transform", ttl)
+	}
+
+	called := false
+	transform := func(old any) (any, time.Duration) { called = true; return old, 0 }
+	c.SetWithTTL("expired", 1, time.Second)
+	clock.Advance(time.Hour)
+	for _, key := range []string{"missing", "expired"} {
+		if b.Migrate(key, "new", transform) || called {
+			t.Fatalf("Migrate(%s) = true or called transform, want false without a call", key)
+		}
+	}
+}
+
+func TestMigrateKeepsOldKeyWhenNewItemIsRejected(t *testing.T) {
+	tests := []struct {
+		name      string
+		newCache  func() Cache
+		oldKey    string
+		transform func(old any) (any, time.Duration)
+	}{
+		{
+			name: "value too large",
+			newCache: func() Cache {
+				c := NewCacheWithConfig(CacheConfig{MaxValueSize: 4, Sizer: func(value any) int { return len(value.(string)) }})
+				c.Set("old", "v1")
+				return c
+			},
+			oldKey:    "old",
+			transform: func(old any) (any, time.Duration) { return old.(string) + "-migrated", 0 },
+		},
+		{
+			name:      "full cache that cannot evict",
+			newCache:  func() Cache { return newPinnedFullCache(FullReject) },
+			oldKey:    "p1",
+			transform: func(old any) (any, time.Duration) { return old, 0 },
+		},
+		{
+			name: "empty value deletes",
+			newCache: func() Cache {
+				c := NewCacheWithConfig(CacheConfig{EmptyValueDeletes: true})
+				c.Set("old", "v1")
+				return c
+			},
+			oldKey:    "old",
+			transform: func(any) (any, time.Duration) { return "", 0 },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := tt.newCache()
+			want, _ := c.Get(tt.oldKey)
+
+			if c.(BulkCache).Migrate(tt.oldKey, "new", tt.transform) {
+				t.Fatal("Migrate() = true for a rejected new item")
+			}
+			if value, ok := c.Get(tt.oldKey); !ok || value != want {
+				t.Fatalf("Get(%s) = %v, %v after a rejected Migrate, want %v, true", tt.oldKey, value, ok, want)
+			}
+			if c.(LookupCache).Has("new") {
+				t.Fatal("the rejected new item was stored")
+			}
+		})
+	}
+}
+
+func TestMigrateToSameKey(t *testing.T) {
+	c := NewCache()
+	c.Set("a", 1)
+	if !c.(BulkCache).Migrate("a", "a", func(old any) (any, time.Duration) { return old.(int) + 1, 0 }) {
+		t.Fatal("Migrate(a, a) = false")
+	}
+	if value, ok := c.Get("a"); !ok || value != 2 {
+		t.Fatalf("Get(a) = %v, %v, want the transformed value kept", value, ok)
+	}
+}
+
+func TestMigrateIsAtomic(t *testing.T) {
+	c := NewCache()
+	b := c.(BulkCache)
+	c.Set("key:0", 0)
+
+	const migrations = 1000
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := range migrations {
+			b.Migrate(fmt.Sprintf("key:%d", i), fmt.Sprintf("key:%d", i+1), func(old any) (any, time.Duration) {
+				return old.(int) + 1, 0
+			})
+		}
+	}()
+
+	// A reader holding the lock must always find exactly one key.
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+		b.Transaction(func(tx Tx) {
+			found := 0
+			for i := range migrations + 1 {
+				if _, ok := tx.Get(fmt.Sprintf("key:%d", i)); ok {
+					found++
+				}
+			}
+			if found != 1 {
+				t.Errorf("found %d keys during migration, want 1", found)
+			}
+		})
+	}
+	if value, ok := c.Get(fmt.Sprintf("key:%d", migrations)); !ok || value != migrations {
+		t.Fatalf("final key = %v, %v, want %d, true", value, ok, migrations)
+	}
+}
+
+func TestExpiringWithin(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCacheWithConfig(CacheConfig{Clock: clock})
+	tc := c.(TTLCache)
+	c.SetWithTTL("soon", 1, 2*time.Minute)
+	c.SetWithTTL("sooner", 2, 90*time.Second)
+	c.SetWithTTL("boundary", 3, 6*time.Minute)
+	c.SetWithTTL("later", 4, time.Hour)
+	c.SetWithTTL("expired", 5, 30*time.Second)
+	c.Set("forever", 6)
+	clock.Advance(time.Minute)
+
+	keys := tc.ExpiringWithin(5 * time.Minute)
+	slices.Sort(keys)
+	if want := []string{"soon", "sooner"}; !slices.Equal(keys, want) {
+		t.Fatalf("ExpiringWithin(5m) = %q, want %q", keys, want)
+	}
+	if keys := tc.ExpiringWithin(0); len(keys) != 0 {
+		t.Fatalf("ExpiringWithin(0) = %q, want none", keys)
+	}
+}
+
+func TestExpiryLagStats(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCacheWithConfig(CacheConfig{Clock: clock})
+	c.SetWithTTL("a", 1, time.Second)
+	c.SetWithTTL("b", 2, time.Second)
+	clock.Advance(3 * time.Second)
+	c.(Cleanable).RemoveExpired()
+
+	// A lazy removal by Get is recorded too.
+	c.SetWithTTL("c", 3, time.Second)
+	clock.Advance(5 * time.Second)
+	c.Get("c")
+
+	stats := c.(StatsReporter).Stats()
+	if stats.Expired != 3 {
+		t.Fatalf("Expired = %d, want 3", stats.Expired)
+	}
+	if want := 8 * time.Second / 3; stats.ExpiryLagAvg != want {
+		t.Fatalf("ExpiryLagAvg = %v, want %v", stats.ExpiryLagAvg, want)
+	}
+	if stats.ExpiryLagMax != 4*time.Second {
+		t.Fatalf("ExpiryLagMax = %v, want 4s", stats.ExpiryLagMax)
+	}
+
+	// Deleting an expired item explicitly is not an expiration.
+	c.SetWithTTL("d", 4, time.Second)
+	clock.Advance(time.Hour)
+	c.Delete("d")
+	if stats := c.(StatsReporter).Stats(); stats.ExpiryLagMax != 4*time.Second {
+		t.Fatalf("ExpiryLagMax = %v after Delete, want it unchanged", stats.ExpiryLagMax)
+	}
+}
+
+func TestTryGet(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCacheWithConfig(CacheConfig{Clock: clock})
+	lookup := c.(LookupCache)
+	c.Set("a", 1)
+	c.SetWithTTL("expired", 2, time.Second)
+	clock.Advance(time.Minute)
+
+	if value, ok, acquired := lookup.TryGet("a"); !acquired || !ok || value != 1 {
+		t.Fatalf("TryGet(a) = %v, %v, %v, want 1, true, true", value, ok, acquired)
+	}
+	for _, key := range []string{"missing", "expired"} {
+		if _, ok, acquired := lookup.TryGet(key); !acquired || ok {
+			t.Fatalf("TryGet(%s) ok, acquired = %v, %v, want a miss", key, ok, acquired)
+		}
+	}
+
+	// Hold the write lock in a transaction until the test releases it.
+	before := c.(StatsReporter).Stats()
+	locked := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.(BulkCache).Transaction(func(Tx) {
+			close(locked)
+			<-release
+		})
+	}()
+	<-locked
+
+	if value, ok, acquired := lookup.TryGet("a"); acquired || ok || value != nil {
+		t.Fatalf("TryGet(a) = %v, %v, %v while the write lock is held, want nil, false, false", value, ok, acquired)
+	}
+	close(release)
+	<-done
+
+	after := c.(StatsReporter).Stats()
+	if after.Hits != before.Hits || after.Misses != before.Misses {
+		t.Fatal("TryGet counted a hit or miss without acquiring the lock")
+	}
+	if _, ok, acquired := lookup.TryGet("a"); !acquired || !ok {
+		t.Fatalf("TryGet(a) ok, acquired = %v, %v after the lock was released, want true, true", ok, acquired)
+	}
+}
+
+func TestSetMultiTTL(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCacheWithConfig(CacheConfig{Clock: clock})
+	tc := c.(TTLCache)
+
+	tc.SetMultiTTL(
+		map[string]any{"minute": 1, "hour": 2, "forever": 3, "zero": 4},
+		map[string]time.Duration{"minute": time.Minute, "hour": time.Hour, "zero": 0, "unused": time.Second},
+	)
+	for key, want := range map[string]time.Duration{"minute": time.Minute, "hour": time.Hour, "forever": 0, "zero": 0} {
+		if ttl, ok := tc.GetTTL(key); !ok || ttl != want {
+			t.Fatalf("GetTTL(%s) = %v, %v, want %v, true", key, ttl, ok, want)
+		}
+	}
+	if c.(LookupCache).Has("unused") {
+		t.Fatal("a key present only in ttls was stored")
+	}
+
+	clock.Advance(2 * time.Minute)
+	for key, live := range map[string]bool{"minute": false, "hour": true, "forever": true, "zero": true} {
+		if _, ok := c.Get(key); ok != live {
+			t.Fatalf("Get(%s) ok = %v after 2m, want %v", key, ok, live)
+		}
+	}
+}
+
+func TestNamespaceBytes(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCacheWithConfig(CacheConfig{Clock: clock, Sizer: func(value any) int { return len(value.(string)) }})
+	ic := c.(InspectableCache)
+
+	c.Set("acme:a", "12345")
+	c.Set("acme:b", "123")
+	c.Set("other:a", "1234567")
+	c.Set("acmeco:a", "12")
+	c.SetWithTTL("acme:expired", "1234567890", time.Second)
+	c.Set("acme:a", "1234")
+	clock.Advance(time.Minute)
+
+	for prefix, want := range map[string]int64{"acme:": 7, "other:": 7, "acme": 9, "": 16, "missing:": 0} {
+		if got := ic.NamespaceBytes(prefix); got != want {
+			t.Fatalf("NamespaceBytes(%q) = %d, want %d", prefix, got, want)
+		}
+	}
+
+	// Deleting an item takes its size out of the namespace total.
+	c.Delete("acme:b")
+	if got := ic.NamespaceBytes("acme:"); got != 4 {
+		t.Fatalf("NamespaceBytes(acme:) = %d after Delete, want 4", got)
+	}
+}
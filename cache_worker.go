@@ -2,56 +2,384 @@ package cache
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
+	"math"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
 	"time"
 )
 
+// DefaultWorkerInterval is the cleanup interval used when CacheWorkerConfig.Interval is not positive.
+const DefaultWorkerInterval = time.Minute
+
 // CacheWorkerConfig holds the configuration for starting the cache worker.
 type CacheWorkerConfig struct {
 	Cache    Cache           // Cache instance to clean.
-	Interval time.Duration   // Interval between cache cleanup cycles.
+	Interval time.Duration   // Interval between cache cleanup cycles. If <= 0, DefaultWorkerInterval is used.
 	StopCh   <-chan struct{} // Channel used to signal the worker to stop.
+	Logger   *log.Logger     // Logger for worker messages. If nil, the standard logger is used.
+	Tier     string          // If set, only items stored with SetWithTier and this tier are cleaned.
+	// OnCleanup, if set, is called after every cleanup cycle with the number of items removed
+	// and the time the cycle took.
+	OnCleanup func(removed int, duration time.Duration)
+	// OnCleanupResult, if set, is called after every cleanup cycle like OnCleanup, with the size
+	// of the removed items as well.
+	OnCleanupResult func(CleanupResult)
+	// StatsEveryN, if greater than zero, makes the worker log the cache stats every N cleanup cycles.
+	// It requires the cache to implement StatsReporter.
+	StatsEveryN int
+	// LogKeys makes the worker log every expired key it deletes, for debugging. By default each cycle
+	// logs a single summary line with the number of keys deleted. It requires the cache to implement
+	// KeyCleanable.
+	LogKeys bool
+	// OnExpire, if set, is called with the key and value of every expired item the worker is about to delete,
+	// for example to archive expired sessions to cold storage. It runs without the cache's lock, so it may call
+	// back into the cache. It requires the cache to implement HookCleanable.
+	OnExpire func(key string, value any)
+	// MaxDeletionsPerCycle, if positive, caps the number of expired items each cleanup cycle deletes,
+	// leaving the rest for the next cycles, which bounds how long a cycle holds the cache's lock.
+	// It requires the cache to implement BatchCleanable.
+	//
+	// Tier, LogKeys, OnExpire, and MaxDeletionsPerCycle each select a different cleanup method, so at most one
+	// of them can be set; a worker configured with more than one logs an error and exits.
+	MaxDeletionsPerCycle int
+	// MaxHeapFraction, if positive, makes every cleanup cycle also check memory pressure: while the heap in use
+	// exceeds this fraction of MemoryLimit, items are evicted in the eviction policy's order, or oldest first
+	// for a cache without a policy, in proportion to the excess. The garbage collector releases their memory later,
+	// so the heap is measured again the next cycle. It requires the cache to implement Evictable.
+	MaxHeapFraction float64
+	// MemoryLimit is the memory in bytes MaxHeapFraction is a fraction of. If 0, the Go runtime's soft memory limit,
+	// set with GOMEMLIMIT or debug.SetMemoryLimit, is used, and nothing is evicted if no limit is set.
+	MemoryLimit uint64
+	// HeapUsage, if set, replaces runtime.MemStats.HeapAlloc as the measure of the heap in use, for example in tests.
+	HeapUsage func() uint64
+	// PropagatePanics lets a panic in OnCleanup or in the cache's cleanup method stop the worker
+	// and crash the program. By default the panic is recovered, logged, and the worker keeps running.
+	PropagatePanics bool
+}
+
+// CleanupResult describes a cleanup cycle of a cache worker.
+type CleanupResult struct {
+	Removed  int           // Number of expired items removed.
+	Duration time.Duration // Time the cycle took.
+	// BytesFreed is the total size of the removed items. It is only known when the worker cleans
+	// the whole cache and the cache implements SizedCleanable or BatchCleanable; otherwise it is 0.
+	BytesFreed int64
+	// Evicted is the number of items evicted because the heap exceeded MaxHeapFraction.
+	Evicted int
 }
 
 // StartCacheWorker starts a background worker that periodically cleans expired items from the cache.
 // The worker will exit when the provided context is done or when a signal is received on StopCh.
+// If the configured interval is zero or negative, the worker logs a warning and uses DefaultWorkerInterval.
+// If the cache does not implement the interface the configured cleanup needs, the worker logs an error
+// and exits right away instead of skipping every cycle.
 func StartCacheWorker(ctx context.Context, cfg CacheWorkerConfig) {
-	ticker := time.NewTicker(cfg.Interval)
+	runCacheWorker(ctx, cfg, nil)
+}
+
+// CacheWorker is a handle to a cache worker started with GoCacheWorker.
+// Its channels let callers coordinate startup and shutdown without sleeping.
+type CacheWorker struct {
+	started chan struct{}
+	stopped chan struct{}
+}
+
+// GoCacheWorker starts a cache worker like StartCacheWorker, but in a new goroutine,
+// and returns a handle for observing its lifecycle. The worker is stopped the same way,
+// by cancelling ctx or signaling cfg.StopCh.
+func GoCacheWorker(ctx context.Context, cfg CacheWorkerConfig) *CacheWorker {
+	w := &CacheWorker{
+		started: make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+
+	var startOnce sync.Once
+	start := func() { startOnce.Do(func() { close(w.started) }) }
+
+	go func() {
+		defer close(w.stopped)
+		defer start()
+		runCacheWorker(ctx, cfg, start)
+	}()
+
+	return w
+}
+
+// Started returns a channel that is closed once the worker is running and will clean the cache on its next tick.
+func (w *CacheWorker) Started() <-chan struct{} {
+	return w.started
+}
+
+// Stopped returns a channel that is closed once the worker has exited. Started is always closed before Stopped,
+// even if the worker exits without starting because the cache cannot be cleaned.
+func (w *CacheWorker) Stopped() <-chan struct{} {
+	return w.stopped
+}
+
+// runCacheWorker runs the worker loop. If started is not nil, it is called once the worker is running.
+func runCacheWorker(ctx context.Context, cfg CacheWorkerConfig, started func()) {
+	logger := cfg.Logger
+	if logger == nil {
+		logger = log.Default()
+	}
+
+	interval := cfg.Interval
+	if interval <= 0 {
+		logger.Printf("Cache worker: invalid interval %v, using default %v", interval, DefaultWorkerInterval)
+		interval = DefaultWorkerInterval
+	}
+
+	if err := checkCleanable(cfg); err != nil {
+		logger.Printf("Cache worker: %v, stopping worker", err)
+		return
+	}
+
+	reporter, _ := cfg.Cache.(workerReporter)
+	if reporter != nil {
+		reporter.workerStarted()
+		defer reporter.workerStopped()
+	}
+
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
-	log.Println("Cache worker started")
-	for {
+	logger.Println("Cache worker started")
+	if started != nil {
+		started()
+	}
+	for cycle := 1; ; cycle++ {
 		select {
 		case <-ctx.Done():
-			log.Println("Cache worker: context done, stopping worker")
+			logger.Println("Cache worker: context done, stopping worker")
 			return
 		case <-cfg.StopCh:
-			log.Println("Cache worker: stop channel signaled, stopping worker")
+			logger.Println("Cache worker: stop channel signaled, stopping worker")
 			return
 		case <-ticker.C:
-			cleanupCache(cfg.Cache)
+			runCleanupCycle(cfg, logger, reporter)
+			if cfg.StatsEveryN > 0 && cycle%cfg.StatsEveryN == 0 {
+				stats := cfg.Cache.(StatsReporter).Stats()
+				logger.Printf("Cache worker: stats size=%d hits=%d misses=%d", stats.Size, stats.Hits, stats.Misses)
+			}
 		}
 	}
 }
 
-// cleanupCache removes expired items from the cache.
-// This function only works with the inMemoryCache implementation.
-func cleanupCache(cache Cache) {
-	memCache, ok := cache.(*inMemoryCache)
+// checkCleanable returns an error if the configuration sets more than one cleanup selector or if the configured
+// cache does not implement the interface cleanupCache needs.
+func checkCleanable(cfg CacheWorkerConfig) error {
+	var selectors []string
+	if cfg.Tier != "" {
+		selectors = append(selectors, "Tier")
+	}
+	if cfg.OnExpire != nil {
+		selectors = append(selectors, "OnExpire")
+	}
+	if cfg.LogKeys {
+		selectors = append(selectors, "LogKeys")
+	}
+	if cfg.MaxDeletionsPerCycle > 0 {
+		selectors = append(selectors, "MaxDeletionsPerCycle")
+	}
+	if len(selectors) > 1 {
+		return fmt.Errorf("%s cannot be combined", strings.Join(selectors, ", "))
+	}
+
+	switch {
+	case cfg.Tier != "":
+		if _, ok := cfg.Cache.(TierCleanable); !ok {
+			return errors.New("cache does not implement TierCleanable")
+		}
+	case cfg.OnExpire != nil:
+		if _, ok := cfg.Cache.(HookCleanable); !ok {
+			return errors.New("cache does not implement HookCleanable")
+		}
+	case cfg.LogKeys:
+		if _, ok := cfg.Cache.(KeyCleanable); !ok {
+			return errors.New("cache does not implement KeyCleanable")
+		}
+	case cfg.MaxDeletionsPerCycle > 0:
+		if _, ok := cfg.Cache.(BatchCleanable); !ok {
+			return errors.New("cache does not implement BatchCleanable")
+		}
+	default:
+		_, sized := cfg.Cache.(SizedCleanable)
+		if _, ok := cfg.Cache.(Cleanable); !ok && !sized {
+			return errors.New("cache does not implement Cleanable")
+		}
+	}
+	if cfg.MaxHeapFraction > 0 {
+		if _, ok := cfg.Cache.(Evictable); !ok {
+			return errors.New("cache does not implement Evictable")
+		}
+	}
+	if cfg.StatsEveryN > 0 {
+		if _, ok := cfg.Cache.(StatsReporter); !ok {
+			return errors.New("cache does not implement StatsReporter")
+		}
+	}
+
+	return nil
+}
+
+// runCleanupCycle cleans the cache, reports the cycle to reporter if it is not nil, and calls OnCleanup.
+// Unless the configuration propagates panics, a panic in the cleanup or in OnCleanup is recovered and logged.
+func runCleanupCycle(cfg CacheWorkerConfig, logger *log.Logger, reporter workerReporter) {
+	defer recoverCallback(logger, cfg.PropagatePanics, "cache worker cleanup", nil)
+
+	start := time.Now()
+	removed, freed := cleanupCache(cfg, logger)
+	evicted := relieveMemoryPressure(cfg, logger)
+	if reporter != nil {
+		reporter.cleanupDone(time.Now())
+	}
+	duration := time.Since(start)
+	if cfg.OnCleanup != nil {
+		cfg.OnCleanup(removed, duration)
+	}
+	if cfg.OnCleanupResult != nil {
+		cfg.OnCleanupResult(CleanupResult{Removed: removed, Duration: duration, BytesFreed: freed, Evicted: evicted})
+	}
+}
+
+// cleanupCache removes expired items from the configured cache and returns the number of items removed
+// and, if the cache reports it, their total size.
+// If a tier is set, only that tier is cleaned and the cache must implement TierCleanable;
+// if OnExpire is set, the cache must implement HookCleanable; if LogKeys is set, the cache must implement
+// KeyCleanable; if MaxDeletionsPerCycle is set, it must implement BatchCleanable; otherwise it must implement
+// SizedCleanable or Cleanable. checkCleanable ensures that at most one of them is set.
+func cleanupCache(cfg CacheWorkerConfig, logger *log.Logger) (int, int64) {
+	cache, tier := cfg.Cache, cfg.Tier
+	if tier != "" {
+		tierCleanable, ok := cache.(TierCleanable)
+		if !ok {
+			logger.Println("Cache worker: cache does not implement TierCleanable, skipping cleanup")
+			return 0, 0
+		}
+
+		removed := tierCleanable.RemoveExpiredInTier(tier)
+		if removed > 0 {
+			logger.Printf("Cache worker: deleted %d expired keys in tier %q", removed, tier)
+		}
+		return removed, 0
+	}
+
+	if cfg.OnExpire != nil {
+		hookCleanable, ok := cache.(HookCleanable)
+		if !ok {
+			logger.Println("Cache worker: cache does not implement HookCleanable, skipping cleanup")
+			return 0, 0
+		}
+
+		removed, freed := hookCleanable.RemoveExpiredFunc(cfg.OnExpire)
+		if removed > 0 {
+			logger.Printf("Cache worker: deleted %d expired keys (%d bytes)", removed, freed)
+		}
+		return removed, freed
+	}
+
+	if cfg.LogKeys {
+		keyCleanable, ok := cache.(KeyCleanable)
+		if !ok {
+			logger.Println("Cache worker: cache does not implement KeyCleanable, skipping cleanup")
+			return 0, 0
+		}
+
+		keys := keyCleanable.RemoveExpiredKeys()
+		for _, key := range keys {
+			logger.Printf("Cache worker: deleted expired key %q", key)
+		}
+		if len(keys) > 0 {
+			logger.Printf("Cache worker: deleted %d expired keys", len(keys))
+		}
+		return len(keys), 0
+	}
+
+	if cfg.MaxDeletionsPerCycle > 0 {
+		batchCleanable, ok := cache.(BatchCleanable)
+		if !ok {
+			logger.Println("Cache worker: cache does not implement BatchCleanable, skipping cleanup")
+			return 0, 0
+		}
+
+		removed, freed := batchCleanable.RemoveExpiredN(cfg.MaxDeletionsPerCycle)
+		if removed > 0 {
+			logger.Printf("Cache worker: deleted %d expired keys (%d bytes)", removed, freed)
+		}
+		return removed, freed
+	}
+
+	if sized, ok := cache.(SizedCleanable); ok {
+		removed, freed := sized.RemoveExpiredSized()
+		if removed > 0 {
+			logger.Printf("Cache worker: deleted %d expired keys (%d bytes)", removed, freed)
+		}
+		return removed, freed
+	}
+
+	cleanable, ok := cache.(Cleanable)
 	if !ok {
-		log.Println("Cache worker: cache type is not *inMemoryCache, skipping cleanup")
-		return
+		logger.Println("Cache worker: cache does not implement Cleanable, skipping cleanup")
+		return 0, 0
 	}
 
-	now := time.Now()
+	removed := cleanable.RemoveExpired()
+	if removed > 0 {
+		logger.Printf("Cache worker: deleted %d expired keys", removed)
+	}
 
-	memCache.mu.Lock()
-	defer memCache.mu.Unlock()
+	return removed, 0
+}
 
-	for key, item := range memCache.items {
-		if !item.expiration.IsZero() && now.After(item.expiration) {
-			delete(memCache.items, key)
-			log.Printf("Cache worker: deleted expired key: %s", key)
+// relieveMemoryPressure evicts items from the configured cache if the heap in use exceeds MaxHeapFraction
+// of the memory limit, and returns the number of items evicted. The share of the items evicted is the share
+// of the heap above the threshold, and at least one item.
+func relieveMemoryPressure(cfg CacheWorkerConfig, logger *log.Logger) int {
+	if cfg.MaxHeapFraction <= 0 {
+		return 0
+	}
+	evictable, ok := cfg.Cache.(Evictable)
+	if !ok {
+		return 0
+	}
+
+	limit := cfg.MemoryLimit
+	if limit == 0 {
+		runtimeLimit := debug.SetMemoryLimit(-1)
+		if runtimeLimit == math.MaxInt64 {
+			return 0
 		}
+		limit = uint64(runtimeLimit)
+	}
+
+	usage := heapUsage(cfg)
+	threshold := cfg.MaxHeapFraction * float64(limit)
+	if float64(usage) <= threshold {
+		return 0
+	}
+
+	excess := (float64(usage) - threshold) / float64(usage)
+	n := max(int(math.Ceil(excess*float64(evictable.Len()))), 1)
+	evicted := evictable.EvictN(n)
+	if evicted > 0 {
+		logger.Printf("Cache worker: heap %d bytes above %.0f, evicted %d items", usage, threshold, evicted)
 	}
+
+	return evicted
+}
+
+// heapUsage returns the heap in use as measured by the configured HeapUsage, or by runtime.MemStats.HeapAlloc.
+func heapUsage(cfg CacheWorkerConfig) uint64 {
+	if cfg.HeapUsage != nil {
+		return cfg.HeapUsage()
+	}
+
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return stats.HeapAlloc
 }
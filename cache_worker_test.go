@@ -0,0 +1,597 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// syncBuffer is a bytes.Buffer that can be written by a worker's logger while the test reads it.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.String()
+}
+
+// discardLogger returns a logger that drops worker messages.
+func discardLogger() *log.Logger {
+	return log.New(io.Discard, "", 0)
+}
+
+// startWorker starts a worker for cfg, waits until it runs, and stops it when the test ends.
+func startWorker(t *testing.T, cfg CacheWorkerConfig) *CacheWorker {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w := GoCacheWorker(ctx, cfg)
+	t.Cleanup(func() {
+		cancel()
+		<-w.Stopped()
+	})
+	<-w.Started()
+
+	return w
+}
+
+// eventually polls cond until it holds, failing the test with msg after a second.
+func eventually(t *testing.T, cond func() bool, msg string) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal(msg)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// countingCleanable is a cache that only implements Cleanable, counting the worker's calls.
+type countingCleanable struct {
+	Cache
+	calls atomic.Int64
+}
+
+func (c *countingCleanable) RemoveExpired() int {
+	c.calls.Add(1)
+	return 0
+}
+
+func TestWorkerCallsCustomCleanable(t *testing.T) {
+	c := &countingCleanable{Cache: NewCache()}
+	startWorker(t, CacheWorkerConfig{Cache: c, Interval: time.Millisecond, Logger: discardLogger()})
+
+	eventually(t, func() bool { return c.calls.Load() >= 3 }, "RemoveExpired was not called on every tick")
+}
+
+func TestWorkerRemovesExpiredItems(t *testing.T) {
+	c := NewCache()
+	c.SetWithTTL("short", 1, time.Millisecond)
+	c.Set("long", 2)
+
+	startWorker(t, CacheWorkerConfig{Cache: c, Interval: time.Millisecond, Logger: discardLogger()})
+
+	eventually(t, func() bool { return c.(StatsReporter).Stats().Size == 1 }, "expired item was not removed")
+	if _, ok := c.Get("long"); !ok {
+		t.Fatal("item without expiration was removed")
+	}
+}
+
+func TestWorkerOnCleanupReportsEachCycle(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCacheWithConfig(CacheConfig{Clock: clock})
+	for _, key := range []string{"a", "b", "c"} {
+		c.SetWithTTL(key, key, time.Minute)
+	}
+	c.Set("forever", 0)
+	clock.Advance(time.Hour)
+
+	removed := make(chan int, 100)
+	results := make(chan CleanupResult, 100)
+	startWorker(t, CacheWorkerConfig{
+		Cache:           c,
+		Interval:        time.Millisecond,
+		Logger:          discardLogger(),
+		OnCleanup:       func(n int, _ time.Duration) { removed <- n },
+		OnCleanupResult: func(result CleanupResult) { results <- result },
+	})
+
+	for i, want := range []int{3, 0, 0} {
+		select {
+		case n := <-removed:
+			if n != want {
+				t.Fatalf("cycle %d removed %d items, want %d", i+1, n, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("OnCleanup not called for cycle %d", i+1)
+		}
+	}
+	if result := <-results; result.Removed != 3 {
+		t.Fatalf("first CleanupResult.Removed = %d, want 3", result.Removed)
+	}
+}
+
+func TestWorkerLogsStatsEveryN(t *testing.T) {
+	c := NewCache()
+	c.Set("a", 1)
+	c.Get("a")
+	c.Get("missing")
+
+	var logs syncBuffer
+	var cycles atomic.Int64
+	ctx, cancel := context.WithCancel(context.Background())
+	w := GoCacheWorker(ctx, CacheWorkerConfig{
+		Cache:       c,
+		Interval:    time.Millisecond,
+		Logger:      log.New(&logs, "", 0),
+		StatsEveryN: 3,
+		OnCleanup:   func(int, time.Duration) { cycles.Add(1) },
+	})
+	eventually(t, func() bool { return cycles.Load() >= 10 }, "worker did not run 10 cycles")
+	cancel()
+	<-w.Stopped()
+
+	lines := strings.Count(logs.String(), "Cache worker: stats size=1 hits=1 misses=1")
+	if want := int(cycles.Load() / 3); lines != want {
+		t.Fatalf("logged stats %d times in %d cycles, want %d", lines, cycles.Load(), want)
+	}
+}
+
+func TestWorkersCleanTiersAtTheirOwnCadence(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCacheWithConfig(CacheConfig{Clock: clock})
+	tc := c.(TTLCache)
+	tc.SetWithTier("fast:1", 1, time.Second, "fast")
+	tc.SetWithTier("fast:2", 2, time.Second, "fast")
+	tc.SetWithTier("slow:1", 3, time.Second, "slow")
+	c.SetWithTTL("untiered", 4, time.Second)
+	clock.Advance(time.Minute)
+
+	var fastCycles, slowCycles atomic.Int64
+	startWorker(t, CacheWorkerConfig{
+		Cache: c, Interval: time.Millisecond, Tier: "fast", Logger: discardLogger(),
+		OnCleanup: func(int, time.Duration) { fastCycles.Add(1) },
+	})
+	startWorker(t, CacheWorkerConfig{
+		Cache: c, Interval: time.Hour, Tier: "slow", Logger: discardLogger(),
+		OnCleanup: func(int, time.Duration) { slowCycles.Add(1) },
+	})
+
+	eventually(t, func() bool { return fastCycles.Load() >= 3 }, "fast tier worker did not run")
+	if size := c.(StatsReporter).Stats().Size; size != 2 {
+		t.Fatalf("Size = %d, want only the fast tier cleaned", size)
+	}
+	if slowCycles.Load() != 0 {
+		t.Fatalf("slow tier worker ran %d cycles, want 0 within its interval", slowCycles.Load())
+	}
+}
+
+func TestWorkerInvalidIntervalUsesDefault(t *testing.T) {
+	for _, interval := range []time.Duration{0, -time.Second} {
+		var logs syncBuffer
+		w := startWorker(t, CacheWorkerConfig{Cache: NewCache(), Interval: interval, Logger: log.New(&logs, "", 0)})
+
+		select {
+		case <-w.Stopped():
+			t.Fatalf("worker with interval %v exited, want it running", interval)
+		default:
+		}
+		want := "invalid interval " + interval.String() + ", using default " + DefaultWorkerInterval.String()
+		if !strings.Contains(logs.String(), want) {
+			t.Fatalf("log = %q, want a warning containing %q", logs.String(), want)
+		}
+	}
+}
+
+// cleanupLog runs a worker over a cache holding n expired keys until it has run two cycles and returns its log.
+func cleanupLog(t *testing.T, n int, logKeys bool) string {
+	t.Helper()
+
+	clock := newFakeClock()
+	c := NewCacheWithConfig(CacheConfig{Clock: clock})
+	for _, key := range benchKeys(n) {
+		c.SetWithTTL(key, 1, time.Second)
+	}
+	clock.Advance(time.Minute)
+
+	var logs syncBuffer
+	var cycles atomic.Int64
+	ctx, cancel := context.WithCancel(context.Background())
+	w := GoCacheWorker(ctx, CacheWorkerConfig{
+		Cache:     c,
+		Interval:  time.Millisecond,
+		Logger:    log.New(&logs, "", 0),
+		LogKeys:   logKeys,
+		OnCleanup: func(int, time.Duration) { cycles.Add(1) },
+	})
+	eventually(t, func() bool { return cycles.Load() >= 2 }, "worker did not run two cycles")
+	cancel()
+	<-w.Stopped()
+
+	return logs.String()
+}
+
+func TestWorkerLogsOneSummaryLinePerCycle(t *testing.T) {
+	for _, n := range []int{1, 100, 5000} {
+		logs := cleanupLog(t, n, false)
+		if lines := strings.Count(logs, "Cache worker: deleted"); lines != 1 {
+			t.Fatalf("%d expired keys: logged %d cleanup lines, want 1:\n%s", n, lines, logs)
+		}
+		if want := fmt.Sprintf("Cache worker: deleted %d expired keys", n); !strings.Contains(logs, want) {
+			t.Fatalf("%d expired keys: log = %q, want %q", n, logs, want)
+		}
+	}
+}
+
+func TestWorkerLogKeys(t *testing.T) {
+	logs := cleanupLog(t, 3, true)
+	for _, key := range benchKeys(3) {
+		if want := fmt.Sprintf("Cache worker: deleted expired key %q", key); !strings.Contains(logs, want) {
+			t.Fatalf("log = %q, want a line for key %s", logs, key)
+		}
+	}
+	if !strings.Contains(logs, "Cache worker: deleted 3 expired keys") {
+		t.Fatalf("log = %q, want the summary line too", logs)
+	}
+}
+
+// waitClosed fails the test if ch is not closed within a second.
+func waitClosed(t *testing.T, ch <-chan struct{}, msg string) {
+	t.Helper()
+
+	select {
+	case <-ch:
+	case <-time.After(time.Second):
+		t.Fatal(msg)
+	}
+}
+
+func TestWorkerStartedAndStopped(t *testing.T) {
+	c := NewCache()
+	stop := make(chan struct{})
+	w := GoCacheWorker(context.Background(), CacheWorkerConfig{
+		Cache: c, Interval: time.Millisecond, StopCh: stop, Logger: discardLogger(),
+	})
+
+	waitClosed(t, w.Started(), "Started not closed")
+	if !c.(HealthReporter).Health().WorkerRunning {
+		t.Fatal("WorkerRunning = false once Started is closed")
+	}
+	select {
+	case <-w.Stopped():
+		t.Fatal("Stopped closed while the worker runs")
+	default:
+	}
+
+	close(stop)
+	waitClosed(t, w.Stopped(), "Stopped not closed after signaling the stop channel")
+	if c.(HealthReporter).Health().WorkerRunning {
+		t.Fatal("WorkerRunning = true once Stopped is closed")
+	}
+}
+
+func TestWorkerStartedClosedOnEarlyExit(t *testing.T) {
+	w := GoCacheWorker(context.Background(), CacheWorkerConfig{
+		Cache: NewCache(), Interval: time.Millisecond, Tier: "a", LogKeys: true, Logger: discardLogger(),
+	})
+
+	waitClosed(t, w.Stopped(), "Stopped not closed for a misconfigured worker")
+	waitClosed(t, w.Started(), "Started not closed for a worker that exited early")
+}
+
+func TestWorkerExpiryLagWithinInterval(t *testing.T) {
+	const interval = 20 * time.Millisecond
+	c := NewCache()
+	for _, key := range benchKeys(10) {
+		c.SetWithTTL(key, 1, time.Millisecond)
+	}
+	startWorker(t, CacheWorkerConfig{Cache: c, Interval: interval, Logger: discardLogger()})
+
+	eventually(t, func() bool { return c.(StatsReporter).Stats().Expired == 10 }, "worker did not remove the expired items")
+	stats := c.(StatsReporter).Stats()
+	// Items expire right after being set, so they linger for up to one interval, plus scheduling slack.
+	if limit := interval + 50*time.Millisecond; stats.ExpiryLagMax <= 0 || stats.ExpiryLagMax > limit {
+		t.Fatalf("ExpiryLagMax = %v, want within (0, %v]", stats.ExpiryLagMax, limit)
+	}
+	if stats.ExpiryLagAvg > stats.ExpiryLagMax {
+		t.Fatalf("ExpiryLagAvg = %v above ExpiryLagMax %v", stats.ExpiryLagAvg, stats.ExpiryLagMax)
+	}
+}
+
+func TestWorkerReportsBytesFreed(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCacheWithConfig(CacheConfig{Clock: clock})
+	c.SetWithTTL("a", strings.Repeat("a", 100), time.Second)
+	c.SetWithTTL("b", []byte(strings.Repeat("b", 250)), time.Second)
+	c.SetWithTTL("c", 42, time.Second)
+	c.Set("kept", strings.Repeat("k", 1000))
+	clock.Advance(time.Minute)
+
+	results := make(chan CleanupResult, 100)
+	startWorker(t, CacheWorkerConfig{
+		Cache:           c,
+		Interval:        time.Millisecond,
+		Logger:          discardLogger(),
+		OnCleanupResult: func(result CleanupResult) { results <- result },
+	})
+
+	// Strings and byte slices count their length and other values count 1.
+	const freed = 100 + 250 + 1
+	select {
+	case result := <-results:
+		if result.Removed != 3 || result.BytesFreed != freed {
+			t.Fatalf("CleanupResult = %+v, want 3 items and %d bytes freed", result, freed)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("OnCleanupResult not called")
+	}
+	stats := c.(StatsReporter).Stats()
+	if stats.ExpiredBytes != freed || stats.Bytes != 1000 {
+		t.Fatalf("ExpiredBytes, Bytes = %d, %d, want %d, 1000", stats.ExpiredBytes, stats.Bytes, freed)
+	}
+}
+
+func TestWorkerReportsBytesFreedWithSizer(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCacheWithConfig(CacheConfig{Clock: clock, Sizer: func(value any) int { return value.(int) }})
+	for i, key := range benchKeys(5) {
+		c.SetWithTTL(key, i*10, time.Second)
+	}
+	clock.Advance(time.Minute)
+
+	results := make(chan CleanupResult, 100)
+	startWorker(t, CacheWorkerConfig{
+		Cache:           c,
+		Interval:        time.Millisecond,
+		Logger:          discardLogger(),
+		OnCleanupResult: func(result CleanupResult) { results <- result },
+	})
+
+	if result := <-results; result.Removed != 5 || result.BytesFreed != 0+10+20+30+40 {
+		t.Fatalf("CleanupResult = %+v, want 5 items and 100 bytes freed", result)
+	}
+}
+
+// plainCache hides every optional interface of the cache it wraps.
+type plainCache struct {
+	Cache
+}
+
+func TestWorkerStopsOnNonCleanableCache(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  CacheWorkerConfig
+		want string
+	}{
+		{"default", CacheWorkerConfig{}, "cache does not implement Cleanable"},
+		{"Tier", CacheWorkerConfig{Tier: "hot"}, "cache does not implement TierCleanable"},
+		{"LogKeys", CacheWorkerConfig{LogKeys: true}, "cache does not implement KeyCleanable"},
+		{"MaxDeletionsPerCycle", CacheWorkerConfig{MaxDeletionsPerCycle: 10}, "cache does not implement BatchCleanable"},
+		{"combined selectors", CacheWorkerConfig{Tier: "hot", LogKeys: true}, "Tier, LogKeys cannot be combined"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs syncBuffer
+			cfg := tt.cfg
+			cfg.Cache = plainCache{NewCache()}
+			cfg.Interval = time.Millisecond
+			cfg.Logger = log.New(&logs, "", 0)
+			w := GoCacheWorker(context.Background(), cfg)
+
+			waitClosed(t, w.Stopped(), "worker kept running with a cache it cannot clean")
+			want := "Cache worker: " + tt.want + ", stopping worker\n"
+			if got := logs.String(); got != want {
+				t.Fatalf("log = %q, want the single line %q", got, want)
+			}
+		})
+	}
+}
+
+func TestWorkerMaxDeletionsPerCycle(t *testing.T) {
+	const (
+		expired = 25
+		limit   = 10
+	)
+	clock := newFakeClock()
+	c := NewCacheWithConfig(CacheConfig{Clock: clock})
+	for _, key := range benchKeys(expired) {
+		c.SetWithTTL(key, 1, time.Second)
+	}
+	c.Set("kept", 1)
+	clock.Advance(time.Minute)
+
+	removed := make(chan int, 100)
+	startWorker(t, CacheWorkerConfig{
+		Cache:                c,
+		Interval:             time.Millisecond,
+		Logger:               discardLogger(),
+		MaxDeletionsPerCycle: limit,
+		OnCleanup:            func(n int, _ time.Duration) { removed <- n },
+	})
+
+	for i, want := range []int{limit, limit, expired - 2*limit, 0} {
+		select {
+		case n := <-removed:
+			if n != want {
+				t.Fatalf("cycle %d removed %d items, want %d", i+1, n, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("OnCleanup not called for cycle %d", i+1)
+		}
+	}
+	if size := c.(StatsReporter).Stats().Size; size != 1 {
+		t.Fatalf("Size = %d, want only the live item left", size)
+	}
+}
+
+func TestWorkerEvictsUnderMemoryPressure(t *testing.T) {
+	c := NewBoundedCache(1000, NewLRUPolicy())
+	for _, key := range benchKeys(100) {
+		c.Set(key, 1)
+	}
+	for _, key := range benchKeys(10) {
+		c.Get(key)
+	}
+
+	// The stubbed heap holds 10 bytes per item, so it only falls under half the 1000-byte limit at 50 items.
+	evictable := c.(Evictable)
+	var evicted atomic.Int64
+	var logs syncBuffer
+	startWorker(t, CacheWorkerConfig{
+		Cache:           c,
+		Interval:        time.Millisecond,
+		Logger:          log.New(&logs, "", 0),
+		MaxHeapFraction: 0.5,
+		MemoryLimit:     1000,
+		HeapUsage:       func() uint64 { return uint64(10 * evictable.Len()) },
+		OnCleanupResult: func(result CleanupResult) { evicted.Add(int64(result.Evicted)) },
+	})
+
+	eventually(t, func() bool { return evictable.Len() <= 50 }, "the worker did not evict down to the heap threshold")
+	time.Sleep(20 * time.Millisecond)
+	if n := evictable.Len(); n != 50 {
+		t.Fatalf("Len() = %d, want eviction to stop at the threshold of 50 items", n)
+	}
+	eventually(t, func() bool { return evicted.Load() == 50 }, "OnCleanupResult did not report the 50 evictions")
+	if !strings.Contains(logs.String(), "above 500, evicted") {
+		t.Fatalf("log = %q, want the evictions logged", logs.String())
+	}
+
+	// Eviction follows the policy, so the recently read keys are kept.
+	for _, key := range benchKeys(10) {
+		if _, ok := c.Get(key); !ok {
+			t.Fatalf("recently read key %s was evicted before older ones", key)
+		}
+	}
+}
+
+func TestWorkerDoesNotEvictBelowHeapThreshold(t *testing.T) {
+	c := NewBoundedCache(1000, NewLRUPolicy())
+	for _, key := range benchKeys(100) {
+		c.Set(key, 1)
+	}
+
+	var cycles atomic.Int64
+	startWorker(t, CacheWorkerConfig{
+		Cache:           c,
+		Interval:        time.Millisecond,
+		Logger:          discardLogger(),
+		MaxHeapFraction: 0.5,
+		MemoryLimit:     1000,
+		HeapUsage:       func() uint64 { return 500 },
+		OnCleanupResult: func(result CleanupResult) {
+			if result.Evicted != 0 {
+				t.Errorf("Evicted = %d with the heap at the threshold, want 0", result.Evicted)
+			}
+			cycles.Add(1)
+		},
+	})
+
+	eventually(t, func() bool { return cycles.Load() >= 5 }, "the worker did not run its cycles")
+	if n := c.(Evictable).Len(); n != 100 {
+		t.Fatalf("Len() = %d, want nothing evicted", n)
+	}
+}
+
+func TestWorkerRequiresEvictableForMemoryPressure(t *testing.T) {
+	var logs syncBuffer
+	w := GoCacheWorker(context.Background(), CacheWorkerConfig{
+		Cache:           &countingCleanable{Cache: NewCache()},
+		Interval:        time.Millisecond,
+		Logger:          log.New(&logs, "", 0),
+		MaxHeapFraction: 0.5,
+		MemoryLimit:     1000,
+	})
+	waitClosed(t, w.Stopped(), "the worker kept running with a cache it cannot evict from")
+	if got, want := logs.String(), "Cache worker: cache does not implement Evictable, stopping worker\n"; got != want {
+		t.Fatalf("log = %q, want the single line %q", got, want)
+	}
+}
+
+func TestWorkerOnExpire(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCacheWithConfig(CacheConfig{Clock: clock})
+	for i, key := range benchKeys(5) {
+		c.SetWithTTL(key, i, time.Second)
+	}
+	c.Set("live", "kept")
+	clock.Advance(time.Minute)
+
+	var mu sync.Mutex
+	seen := make(map[string][]any)
+	startWorker(t, CacheWorkerConfig{
+		Cache:    c,
+		Interval: time.Millisecond,
+		Logger:   discardLogger(),
+		OnExpire: func(key string, value any) {
+			// The hook runs without the cache's lock, so it can read the cache.
+			if _, ok := c.Get("live"); !ok {
+				t.Errorf("Get(live) from OnExpire ok = false")
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			seen[key] = append(seen[key], value)
+		},
+	})
+
+	eventually(t, func() bool { return c.(StatsReporter).Stats().Size == 1 }, "the worker did not delete the expired items")
+	time.Sleep(20 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(seen) != 5 {
+		t.Fatalf("OnExpire saw %d keys, want the 5 expired ones", len(seen))
+	}
+	for i, key := range benchKeys(5) {
+		if values := seen[key]; len(values) != 1 || values[0] != i {
+			t.Fatalf("OnExpire calls for %s = %v, want exactly one with %d", key, values, i)
+		}
+	}
+	if _, ok := c.Get("live"); !ok {
+		t.Fatal("the live item was deleted")
+	}
+}
+
+func TestRemoveExpiredFuncKeepsItemsOverwrittenByTheHook(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCacheWithConfig(CacheConfig{Clock: clock})
+	c.SetWithTTL("renewed", 1, time.Second)
+	c.SetWithTTL("gone", 2, time.Second)
+	clock.Advance(time.Minute)
+
+	removed, _ := c.(HookCleanable).RemoveExpiredFunc(func(key string, value any) {
+		if key == "renewed" {
+			c.Set(key, "fresh")
+		}
+	})
+	if removed != 1 {
+		t.Fatalf("RemoveExpiredFunc() removed %d items, want only gone", removed)
+	}
+	if value, ok := c.Get("renewed"); !ok || value != "fresh" {
+		t.Fatalf("Get(renewed) = %v, %v, want the value stored by the hook kept", value, ok)
+	}
+	if c.(LookupCache).Has("gone") {
+		t.Fatal("the expired item was not removed")
+	}
+}
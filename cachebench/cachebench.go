@@ -0,0 +1,141 @@
+// Package cachebench replays synthetic workloads against a cache and reports its hit rate and throughput,
+// for comparing eviction policies and configurations on the same key sequence.
+package cachebench
+
+import (
+	"math/rand/v2"
+	"strconv"
+	"time"
+
+	cache "github.com/nordew/go-stash"
+)
+
+// DefaultZipfianSkew is the skew used by Zipfian when the given skew is not greater than 1.
+const DefaultZipfianSkew = 1.1
+
+// Generator produces the keys a workload reads, one per operation.
+// Generators are not safe for concurrent use.
+type Generator interface {
+	// Next returns the key of the next operation.
+	Next() string
+}
+
+// Workload describes a synthetic workload.
+type Workload struct {
+	// Keys produces the key of every operation.
+	Keys Generator
+	// Ops is the number of operations to run.
+	Ops int
+	// TTL is the TTL of the values stored on misses. If <= 0, they do not expire.
+	TTL time.Duration
+}
+
+// Result summarizes a workload run.
+type Result struct {
+	Ops      int
+	Hits     int
+	Misses   int
+	Duration time.Duration
+}
+
+// HitRate returns the fraction of operations that were hits, or 0 if no operation ran.
+func (r Result) HitRate() float64 {
+	if r.Ops == 0 {
+		return 0
+	}
+
+	return float64(r.Hits) / float64(r.Ops)
+}
+
+// Throughput returns the number of operations run per second.
+func (r Result) Throughput() float64 {
+	if r.Duration <= 0 {
+		return 0
+	}
+
+	return float64(r.Ops) / r.Duration.Seconds()
+}
+
+// RunWorkload runs w against c from the calling goroutine. Every operation reads a key with Get and,
+// on a miss, stores the key as its own value with the workload's TTL, like a read-through cache would.
+func RunWorkload(c cache.Cache, w Workload) Result {
+	result := Result{Ops: max(w.Ops, 0)}
+
+	start := time.Now()
+	for range result.Ops {
+		key := w.Keys.Next()
+		if _, ok := c.Get(key); ok {
+			result.Hits++
+			continue
+		}
+		result.Misses++
+		c.SetWithTTL(key, key, w.TTL)
+	}
+	result.Duration = time.Since(start)
+
+	return result
+}
+
+// zipfian draws keys whose popularity follows a Zipf distribution.
+type zipfian struct {
+	zipf *rand.Zipf
+}
+
+// Zipfian returns a generator of keys among keys distinct ones, where the k-th most popular key is drawn
+// with a probability proportional to 1/k^skew, as in many real access patterns. Larger skews concentrate
+// the accesses on fewer keys; a skew not greater than 1 is replaced by DefaultZipfianSkew.
+// The same seed always produces the same sequence.
+func Zipfian(keys int, skew float64, seed uint64) Generator {
+	if skew <= 1 {
+		skew = DefaultZipfianSkew
+	}
+	r := rand.New(rand.NewPCG(seed, seed))
+
+	return &zipfian{zipf: rand.NewZipf(r, skew, 1, uint64(max(keys, 1)-1))}
+}
+
+// Next returns the key of the next operation.
+func (z *zipfian) Next() string {
+	return key(z.zipf.Uint64())
+}
+
+// uniform draws keys uniformly at random.
+type uniform struct {
+	r    *rand.Rand
+	keys int
+}
+
+// Uniform returns a generator of keys among keys distinct ones, all equally likely.
+// The same seed always produces the same sequence.
+func Uniform(keys int, seed uint64) Generator {
+	return &uniform{r: rand.New(rand.NewPCG(seed, seed)), keys: max(keys, 1)}
+}
+
+// Next returns the key of the next operation.
+func (u *uniform) Next() string {
+	return key(uint64(u.r.IntN(u.keys)))
+}
+
+// scan reads keys in order.
+type scan struct {
+	next, keys int
+}
+
+// Scan returns a generator that reads keys distinct keys in order and starts over, which defeats
+// recency-based eviction once keys exceeds the cache's capacity.
+func Scan(keys int) Generator {
+	return &scan{keys: max(keys, 1)}
+}
+
+// Next returns the key of the next operation.
+func (s *scan) Next() string {
+	k := s.next
+	s.next = (s.next + 1) % s.keys
+
+	return key(uint64(k))
+}
+
+// key returns the cache key of the n-th key of a workload.
+func key(n uint64) string {
+	return "key:" + strconv.FormatUint(n, 10)
+}
@@ -0,0 +1,109 @@
+package cachebench
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	cache "github.com/nordew/go-stash"
+)
+
+func TestZipfianHitRateOrdering(t *testing.T) {
+	run := func(cfg cache.CacheConfig) float64 {
+		cfg.Capacity = 100
+		return RunWorkload(cache.NewCacheWithConfig(cfg), Workload{Keys: Zipfian(10_000, 0, 1), Ops: 50_000}).HitRate()
+	}
+	lru := run(cache.CacheConfig{Policy: cache.NewLRUPolicy()})
+	gdsf := run(cache.CacheConfig{Policy: cache.NewGDSFPolicy()})
+	tinyLFU := run(cache.CacheConfig{Policy: cache.NewLRUPolicy(), TinyLFUAdmission: true})
+
+	// Frequency-aware policies keep the popular head of the distribution that LRU cycles out.
+	if gdsf <= lru {
+		t.Fatalf("GDSF hit rate = %.3f, want above LRU's %.3f", gdsf, lru)
+	}
+	if tinyLFU <= lru {
+		t.Fatalf("TinyLFU hit rate = %.3f, want above LRU's %.3f", tinyLFU, lru)
+	}
+}
+
+func TestUniformHitRateMatchesCapacity(t *testing.T) {
+	c := cache.NewBoundedCache(100, cache.NewLRUPolicy())
+	RunWorkload(c, Workload{Keys: Uniform(1000, 1), Ops: 10_000})
+
+	result := RunWorkload(c, Workload{Keys: Uniform(1000, 2), Ops: 50_000})
+	if rate := result.HitRate(); rate < 0.07 || rate > 0.13 {
+		t.Fatalf("hit rate = %.3f, want about capacity/keys = 0.1", rate)
+	}
+}
+
+func TestScanDefeatsLRU(t *testing.T) {
+	result := RunWorkload(cache.NewBoundedCache(100, cache.NewLRUPolicy()), Workload{Keys: Scan(101), Ops: 1000})
+	if result.Hits != 0 || result.Misses != 1000 {
+		t.Fatalf("hits, misses = %d, %d, want 0, 1000 for a scan one key larger than the cache", result.Hits, result.Misses)
+	}
+
+	result = RunWorkload(cache.NewBoundedCache(100, cache.NewLRUPolicy()), Workload{Keys: Scan(100), Ops: 1000})
+	if result.Hits != 900 {
+		t.Fatalf("hits = %d, want 900 for a scan that fits in the cache", result.Hits)
+	}
+}
+
+func TestGeneratorsAreReproducible(t *testing.T) {
+	draw := func(g Generator) []string {
+		keys := make([]string, 100)
+		for i := range keys {
+			keys[i] = g.Next()
+		}
+		return keys
+	}
+
+	all := draw(Scan(50))
+	for name, newGenerator := range map[string]func(seed uint64) Generator{
+		"zipfian": func(seed uint64) Generator { return Zipfian(50, 1.5, seed) },
+		"uniform": func(seed uint64) Generator { return Uniform(50, seed) },
+	} {
+		first := draw(newGenerator(1))
+		if !slices.Equal(first, draw(newGenerator(1))) {
+			t.Fatalf("%s: the same seed produced different keys", name)
+		}
+		if slices.Equal(first, draw(newGenerator(2))) {
+			t.Fatalf("%s: different seeds produced the same keys", name)
+		}
+		for _, key := range first {
+			if !slices.Contains(all, key) {
+				t.Fatalf("%s: key %q is outside the 50 workload keys", name, key)
+			}
+		}
+	}
+
+	if keys := draw(Scan(3))[:4]; !slices.Equal(keys, []string{"key:0", "key:1", "key:2", "key:0"}) {
+		t.Fatalf("Scan(3) keys = %q, want the keys in order, starting over", keys)
+	}
+}
+
+func TestRunWorkloadStoresMissesWithTTL(t *testing.T) {
+	c := cache.NewCache()
+	result := RunWorkload(c, Workload{Keys: Scan(10), Ops: 25, TTL: time.Hour})
+	if result.Ops != 25 || result.Hits != 15 || result.Misses != 10 {
+		t.Fatalf("result = %+v, want 25 ops, 15 hits, 10 misses", result)
+	}
+	if value, ok := c.Get("key:3"); !ok || value != "key:3" {
+		t.Fatalf("Get(key:3) = %v, %v, want the key stored as its own value", value, ok)
+	}
+	if ttl, ok := c.(cache.TTLCache).GetTTL("key:3"); !ok || ttl <= 0 || ttl > time.Hour {
+		t.Fatalf("GetTTL(key:3) = %v, %v, want the workload TTL", ttl, ok)
+	}
+	if result.Throughput() <= 0 {
+		t.Fatalf("Throughput() = %v, want > 0", result.Throughput())
+	}
+}
+
+func TestResultOfNoOps(t *testing.T) {
+	result := RunWorkload(cache.NewCache(), Workload{Keys: Scan(1), Ops: -1})
+	if result != (Result{Duration: result.Duration}) || result.HitRate() != 0 {
+		t.Fatalf("result = %+v with hit rate %v, want no operations", result, result.HitRate())
+	}
+	if (Result{}).Throughput() != 0 {
+		t.Fatal("Throughput() of a zero Result != 0")
+	}
+}
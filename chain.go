@@ -0,0 +1,361 @@
+package cache
+
+import (
+	"fmt"
+	"time"
+)
+
+// ChainConfig holds the configuration for creating a chain of caches.
+type ChainConfig struct {
+	// Caches are the levels of the chain, from the fastest to the largest. There must be at least one.
+	Caches []Cache
+	// WriteLevels, if positive, is the number of levels, starting from the first, that Set and SetWithTTL write to.
+	// Lower levels are then only read, for example when they are filled by another process. By default all levels are written.
+	WriteLevels int
+}
+
+// chainCache decorates the first cache of a chain so that misses fall back to the later ones.
+type chainCache struct {
+	Cache
+	levels []Cache
+	writes []Cache
+}
+
+// ttlGetter, presenceChecker, and invalidator are the optional operations the chain uses on its levels.
+type (
+	ttlGetter interface {
+		GetTTL(key string) (time.Duration, bool)
+	}
+	presenceChecker interface {
+		Has(key string) bool
+	}
+	invalidator interface {
+		InvalidateAll()
+	}
+)
+
+// NewChain returns a cache that reads from caches in order, like an L1 cache in front of an L2 cache,
+// and writes to all of them. See NewChainWithConfig. It panics if no caches are given.
+func NewChain(caches ...Cache) Cache {
+	return NewChainWithConfig(ChainConfig{Caches: caches})
+}
+
+// NewChainWithConfig returns a cache that reads from cfg.Caches in order. A value found in a later level
+// is promoted to the earlier levels with the TTL it has left there, so levels never keep a value longer
+// than the level it came from; a value that does not expire is promoted without expiration.
+// Set and SetWithTTL write to the configured levels with the same TTL, and Delete, Clear, and InvalidateAll
+// apply to all levels.
+// GetTTL and Has consult the levels in order. Values are only promoted from levels that can report their TTL.
+// The chain also forwards the worker cleanup interfaces, Evictable, MembershipFilter, and Verifier to its levels;
+// it does not implement the other optional interfaces. It panics if cfg.Caches is empty.
+func NewChainWithConfig(cfg ChainConfig) Cache {
+	if len(cfg.Caches) == 0 {
+		panic("cache: NewChainWithConfig needs at least one cache")
+	}
+
+	writeLevels := len(cfg.Caches)
+	if cfg.WriteLevels > 0 {
+		writeLevels = min(cfg.WriteLevels, writeLevels)
+	}
+
+	return &chainCache{
+		Cache:  cfg.Caches[0],
+		levels: cfg.Caches,
+		writes: cfg.Caches[:writeLevels],
+	}
+}
+
+// Get retrieves the value for the specified key from the first level that has it,
+// and promotes it to the earlier levels.
+func (ch *chainCache) Get(key string) (any, bool) {
+	for i, level := range ch.levels {
+		value, ok := level.Get(key)
+		if !ok {
+			continue
+		}
+		if i > 0 {
+			ch.promote(key, value, level, ch.levels[:i])
+		}
+		return value, true
+	}
+
+	return nil, false
+}
+
+// GetWithSource is like Get. The source is SourceHit when any level has the value and SourceNone otherwise.
+func (ch *chainCache) GetWithSource(key string) (any, Source, bool) {
+	value, ok := ch.Get(key)
+	if !ok {
+		return nil, SourceNone, false
+	}
+
+	return value, SourceHit, true
+}
+
+// promote stores value, found in from, in the given earlier levels with the TTL it has left in from.
+// If the item expired in from since it was read, or from cannot report its TTL, it is not promoted.
+func (ch *chainCache) promote(key string, value any, from Cache, to []Cache) {
+	getter, ok := from.(ttlGetter)
+	if !ok {
+		return
+	}
+	ttl, ok := getter.GetTTL(key)
+	if !ok {
+		return
+	}
+
+	for _, level := range to {
+		level.SetWithTTL(key, value, ttl)
+	}
+}
+
+// Has reports whether any level holds a live item under the specified key.
+// Levels that do not implement Has are checked with Get, which misses stored nil values.
+func (ch *chainCache) Has(key string) bool {
+	for _, level := range ch.levels {
+		if checker, ok := level.(presenceChecker); ok {
+			if checker.Has(key) {
+				return true
+			}
+			continue
+		}
+		if _, ok := level.Get(key); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// GetTTL returns the remaining TTL of the item in the first level that has it, skipping levels that cannot report a TTL.
+func (ch *chainCache) GetTTL(key string) (time.Duration, bool) {
+	for _, level := range ch.levels {
+		getter, ok := level.(ttlGetter)
+		if !ok {
+			continue
+		}
+		if ttl, ok := getter.GetTTL(key); ok {
+			return ttl, true
+		}
+	}
+
+	return 0, false
+}
+
+// Set assigns a value to the specified key without expiration in every written level.
+func (ch *chainCache) Set(key string, value any) {
+	for _, level := range ch.writes {
+		level.Set(key, value)
+	}
+}
+
+// SetWithTTL assigns a value to the specified key with the given TTL in every written level.
+func (ch *chainCache) SetWithTTL(key string, value any, ttl time.Duration) {
+	for _, level := range ch.writes {
+		level.SetWithTTL(key, value, ttl)
+	}
+}
+
+// Delete removes the specified key from every level, so a later Get cannot promote a stale value.
+func (ch *chainCache) Delete(key string) {
+	for _, level := range ch.levels {
+		level.Delete(key)
+	}
+}
+
+// Clear removes all items from every level.
+func (ch *chainCache) Clear() {
+	for _, level := range ch.levels {
+		level.Clear()
+	}
+}
+
+// InvalidateAll invalidates the items of every level, clearing the levels that cannot invalidate.
+func (ch *chainCache) InvalidateAll() {
+	for _, level := range ch.levels {
+		if inv, ok := level.(invalidator); ok {
+			inv.InvalidateAll()
+		} else {
+			level.Clear()
+		}
+	}
+}
+
+// RemoveExpired removes expired items from every level that implements Cleanable
+// and returns the total number of items removed.
+func (ch *chainCache) RemoveExpired() int {
+	removed := 0
+	for _, level := range ch.levels {
+		if cleanable, ok := level.(Cleanable); ok {
+			removed += cleanable.RemoveExpired()
+		}
+	}
+
+	return removed
+}
+
+// RemoveExpiredSized removes expired items from every level like RemoveExpired, and returns the total
+// size of the items removed from the levels that implement SizedCleanable.
+func (ch *chainCache) RemoveExpiredSized() (int, int64) {
+	removed, size := 0, int64(0)
+	for _, level := range ch.levels {
+		switch cleanable := level.(type) {
+		case SizedCleanable:
+			n, s := cleanable.RemoveExpiredSized()
+			removed += n
+			size += s
+		case Cleanable:
+			removed += cleanable.RemoveExpired()
+		}
+	}
+
+	return removed, size
+}
+
+// RemoveExpiredN removes at most n expired items in total from the levels that implement BatchCleanable,
+// starting with the first level.
+func (ch *chainCache) RemoveExpiredN(n int) (int, int64) {
+	removed, size := 0, int64(0)
+	for _, level := range ch.levels {
+		if removed >= n {
+			break
+		}
+		if cleanable, ok := level.(BatchCleanable); ok {
+			r, s := cleanable.RemoveExpiredN(n - removed)
+			removed += r
+			size += s
+		}
+	}
+
+	return removed, size
+}
+
+// RemoveExpiredKeys removes expired items from every level that implements KeyCleanable and returns their keys.
+// A key that expired in several levels is returned once per level.
+func (ch *chainCache) RemoveExpiredKeys() []string {
+	var keys []string
+	for _, level := range ch.levels {
+		if cleanable, ok := level.(KeyCleanable); ok {
+			keys = append(keys, cleanable.RemoveExpiredKeys()...)
+		}
+	}
+
+	return keys
+}
+
+// RemoveExpiredFunc removes expired items from every level that implements HookCleanable, passing them to fn first.
+// An item that expired in several levels is passed to fn once per level.
+func (ch *chainCache) RemoveExpiredFunc(fn func(key string, value any)) (int, int64) {
+	removed, size := 0, int64(0)
+	for _, level := range ch.levels {
+		if cleanable, ok := level.(HookCleanable); ok {
+			r, s := cleanable.RemoveExpiredFunc(fn)
+			removed += r
+			size += s
+		}
+	}
+
+	return removed, size
+}
+
+// RemoveExpiredInTier removes the expired items of a tier from every level that implements TierCleanable
+// and returns the total number of items removed.
+func (ch *chainCache) RemoveExpiredInTier(tier string) int {
+	removed := 0
+	for _, level := range ch.levels {
+		if cleanable, ok := level.(TierCleanable); ok {
+			removed += cleanable.RemoveExpiredInTier(tier)
+		}
+	}
+
+	return removed
+}
+
+// EvictN evicts at most n items in total from the levels that implement Evictable, starting with the first level.
+func (ch *chainCache) EvictN(n int) int {
+	evicted := 0
+	for _, level := range ch.levels {
+		if evicted >= n {
+			break
+		}
+		if evictable, ok := level.(Evictable); ok {
+			evicted += evictable.EvictN(n - evicted)
+		}
+	}
+
+	return evicted
+}
+
+// Len returns the total number of items stored in the levels that implement Evictable.
+func (ch *chainCache) Len() int {
+	n := 0
+	for _, level := range ch.levels {
+		if evictable, ok := level.(Evictable); ok {
+			n += evictable.Len()
+		}
+	}
+
+	return n
+}
+
+// MayContain reports whether key may be stored in any level. Levels that do not implement MembershipFilter
+// may contain any key.
+func (ch *chainCache) MayContain(key string) bool {
+	for _, level := range ch.levels {
+		filter, ok := level.(MembershipFilter)
+		if !ok || filter.MayContain(key) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AddToFilter adds keys to the filter of every level that implements MembershipFilter.
+func (ch *chainCache) AddToFilter(keys ...string) {
+	for _, level := range ch.levels {
+		if filter, ok := level.(MembershipFilter); ok {
+			filter.AddToFilter(keys...)
+		}
+	}
+}
+
+// Verify checks the internal consistency of every level that implements Verifier and returns the first error.
+func (ch *chainCache) Verify() error {
+	for i, level := range ch.levels {
+		if verifier, ok := level.(Verifier); ok {
+			if err := verifier.Verify(); err != nil {
+				return fmt.Errorf("cache: chain level %d: %w", i, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// workerStarted forwards the worker activity to every level so that their Health reflects it.
+func (ch *chainCache) workerStarted() {
+	for _, level := range ch.levels {
+		if reporter, ok := level.(workerReporter); ok {
+			reporter.workerStarted()
+		}
+	}
+}
+
+// workerStopped forwards the worker activity to every level.
+func (ch *chainCache) workerStopped() {
+	for _, level := range ch.levels {
+		if reporter, ok := level.(workerReporter); ok {
+			reporter.workerStopped()
+		}
+	}
+}
+
+// cleanupDone forwards the worker activity to every level.
+func (ch *chainCache) cleanupDone(at time.Time) {
+	for _, level := range ch.levels {
+		if reporter, ok := level.(workerReporter); ok {
+			reporter.cleanupDone(at)
+		}
+	}
+}
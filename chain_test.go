@@ -0,0 +1,98 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+// newChainLevels returns two in-memory levels sharing clock.
+func newChainLevels(clock Clock) (l1, l2 Cache) {
+	return NewCacheWithConfig(CacheConfig{Clock: clock}), NewCacheWithConfig(CacheConfig{Clock: clock})
+}
+
+func TestChainPromotesWithRemainingTTL(t *testing.T) {
+	clock := newFakeClock()
+	l1, l2 := newChainLevels(clock)
+	chain := NewChain(l1, l2)
+	l2.SetWithTTL("a", 1, time.Hour)
+	l2.Set("forever", 2)
+	clock.Advance(10 * time.Minute)
+
+	if value, ok := chain.Get("a"); !ok || value != 1 {
+		t.Fatalf("Get(a) = %v, %v, want the value from the second level", value, ok)
+	}
+	if value, ok := l1.Get("a"); !ok || value != 1 {
+		t.Fatalf("first level Get(a) = %v, %v, want the value promoted", value, ok)
+	}
+	if ttl, _ := l1.(TTLCache).GetTTL("a"); ttl != 50*time.Minute {
+		t.Fatalf("promoted TTL = %v, want the 50m left in the second level", ttl)
+	}
+
+	chain.Get("forever")
+	if ttl, ok := l1.(TTLCache).GetTTL("forever"); !ok || ttl != 0 {
+		t.Fatalf("promoted GetTTL(forever) = %v, %v, want a value without expiration", ttl, ok)
+	}
+
+	if _, ok := chain.Get("missing"); ok {
+		t.Fatal("Get(missing) ok = true")
+	}
+}
+
+func TestChainWritesToAllLevels(t *testing.T) {
+	l1, l2 := newChainLevels(nil)
+	chain := NewChain(l1, l2)
+
+	chain.SetWithTTL("a", 1, time.Minute)
+	chain.Set("b", 2)
+	for i, level := range []Cache{l1, l2} {
+		for _, key := range []string{"a", "b"} {
+			if _, ok := level.Get(key); !ok {
+				t.Fatalf("level %d Get(%s) ok = false after a write through the chain", i+1, key)
+			}
+		}
+		if ttl, _ := level.(TTLCache).GetTTL("a"); ttl <= 0 || ttl > time.Minute {
+			t.Fatalf("level %d GetTTL(a) = %v, want the TTL given to the chain", i+1, ttl)
+		}
+	}
+
+	chain.Delete("a")
+	for i, level := range []Cache{l1, l2} {
+		if _, ok := level.Get("a"); ok {
+			t.Fatalf("level %d Get(a) ok = true after Delete through the chain", i+1)
+		}
+	}
+	chain.Clear()
+	for i, level := range []Cache{l1, l2} {
+		if size := level.(StatsReporter).Stats().Size; size != 0 {
+			t.Fatalf("level %d Size = %d after Clear through the chain, want 0", i+1, size)
+		}
+	}
+}
+
+func TestChainWriteLevels(t *testing.T) {
+	l1, l2 := newChainLevels(nil)
+	chain := NewChainWithConfig(ChainConfig{Caches: []Cache{l1, l2}, WriteLevels: 1})
+
+	chain.Set("a", 1)
+	if _, ok := l1.Get("a"); !ok {
+		t.Fatal("first level Get(a) ok = false, want it written")
+	}
+	if _, ok := l2.Get("a"); ok {
+		t.Fatal("second level Get(a) ok = true, want only the first level written")
+	}
+
+	// A read-only level is still read and promoted from.
+	l2.Set("b", 2)
+	if value, ok := chain.Get("b"); !ok || value != 2 {
+		t.Fatalf("Get(b) = %v, %v, want the value from the read-only level", value, ok)
+	}
+}
+
+func TestNewChainPanicsWithoutCaches(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("NewChain() with no caches did not panic")
+		}
+	}()
+	NewChain()
+}
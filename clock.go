@@ -0,0 +1,76 @@
+package cache
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Clock supplies the current time to a cache. It can be replaced in tests by a fake clock
+// to make expirations deterministic.
+type Clock interface {
+	Now() time.Time
+}
+
+// coarseClock caches the current time and refreshes it from a background goroutine at a fixed resolution,
+// so reading it is an atomic load instead of a call to time.Now.
+type coarseClock struct {
+	now  atomic.Pointer[time.Time]
+	stop chan struct{}
+	// pool, if set, runs each refresh as a delayed task instead of a dedicated goroutine.
+	pool       *TaskPool
+	resolution time.Duration
+	closed     atomic.Bool
+}
+
+// newCoarseClock starts a clock that is refreshed every resolution, on pool if it is not nil.
+// The caller must call close to stop it.
+func newCoarseClock(resolution time.Duration, pool *TaskPool) *coarseClock {
+	clock := &coarseClock{stop: make(chan struct{}), pool: pool, resolution: resolution}
+	now := time.Now()
+	clock.now.Store(&now)
+
+	if pool != nil {
+		pool.afterFunc(resolution, clock.tick)
+	} else {
+		go clock.run(resolution)
+	}
+
+	return clock
+}
+
+// tick refreshes the cached time and schedules the next refresh on the pool, until the clock is closed.
+func (c *coarseClock) tick() {
+	if c.closed.Load() {
+		return
+	}
+
+	now := time.Now()
+	c.now.Store(&now)
+	c.pool.afterFunc(c.resolution, c.tick)
+}
+
+// run refreshes the cached time until the clock is closed.
+func (c *coarseClock) run(resolution time.Duration) {
+	ticker := time.NewTicker(resolution)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			c.now.Store(&now)
+		case <-c.stop:
+			return
+		}
+	}
+}
+
+// Now returns the cached time, which lags the real time by at most the clock's resolution.
+func (c *coarseClock) Now() time.Time {
+	return *c.now.Load()
+}
+
+// close stops refreshing the clock.
+func (c *coarseClock) close() {
+	c.closed.Store(true)
+	close(c.stop)
+}
@@ -0,0 +1,107 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+// checkCoarseClock samples clock for a while and fails the test if it lags time.Now by more than tolerance
+// or does not advance.
+func checkCoarseClock(t *testing.T, clock *coarseClock, tolerance time.Duration) {
+	t.Helper()
+
+	first := clock.Now()
+	prev := first
+	for start := time.Now(); time.Since(start) < 50*time.Millisecond; time.Sleep(time.Millisecond) {
+		now := clock.Now()
+		if lag := time.Since(now); lag < 0 || lag > tolerance {
+			t.Fatalf("coarse clock lags time.Now by %v, want at most %v", lag, tolerance)
+		}
+		if now.Before(prev) {
+			t.Fatalf("coarse clock went back from %v to %v", prev, now)
+		}
+		prev = now
+	}
+	if !prev.After(first) {
+		t.Fatal("coarse clock did not advance")
+	}
+}
+
+func TestCoarseClockTracksTime(t *testing.T) {
+	const resolution = 5 * time.Millisecond
+	clock := newCoarseClock(resolution, nil)
+	defer clock.close()
+
+	// The refresh goroutine can be scheduled late, so allow some slack over the resolution.
+	checkCoarseClock(t, clock, resolution+20*time.Millisecond)
+}
+
+func TestCoarseClockOnPool(t *testing.T) {
+	const resolution = 5 * time.Millisecond
+	clock := newCoarseClock(resolution, NewTaskPool(1))
+	defer clock.close()
+
+	checkCoarseClock(t, clock, resolution+20*time.Millisecond)
+}
+
+func TestCoarseClockClose(t *testing.T) {
+	const resolution = time.Millisecond
+	for _, pool := range []*TaskPool{nil, NewTaskPool(1)} {
+		clock := newCoarseClock(resolution, pool)
+		clock.close()
+		// A refresh in flight when close was called may still land.
+		time.Sleep(5 * resolution)
+
+		stopped := clock.Now()
+		time.Sleep(10 * resolution)
+		if now := clock.Now(); !now.Equal(stopped) {
+			t.Fatalf("coarse clock moved from %v to %v after close", stopped, now)
+		}
+	}
+}
+
+func TestTimeResolutionExpiresWithinTolerance(t *testing.T) {
+	const (
+		resolution = 5 * time.Millisecond
+		ttl        = 30 * time.Millisecond
+	)
+	c := NewCacheWithConfig(CacheConfig{TimeResolution: resolution})
+
+	start := time.Now()
+	c.SetWithTTL("a", 1, ttl)
+	for {
+		if _, ok := c.Get("a"); !ok {
+			break
+		}
+		if time.Since(start) > time.Second {
+			t.Fatal("item did not expire")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	elapsed := time.Since(start)
+	// The clock may be up to a resolution behind when the item is stored and when it is read.
+	if elapsed < ttl-resolution || elapsed > ttl+2*resolution+20*time.Millisecond {
+		t.Fatalf("item expired after %v, want %v within the clock resolution %v", elapsed, ttl, resolution)
+	}
+}
+
+// BenchmarkClockNow compares reading the coarse clock with calling time.Now, from many goroutines at once.
+func BenchmarkClockNow(b *testing.B) {
+	b.Run("TimeNow", func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				_ = time.Now()
+			}
+		})
+	})
+	b.Run("CoarseClock", func(b *testing.B) {
+		clock := newCoarseClock(time.Millisecond, nil)
+		defer clock.close()
+
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				_ = clock.Now()
+			}
+		})
+	})
+}
@@ -0,0 +1,80 @@
+package cache
+
+import (
+	"reflect"
+)
+
+// DeepCopy returns a deep copy of v.
+// Pointers, slices, arrays, maps, interfaces, and exported struct fields are copied recursively.
+// Map keys, unexported struct fields, channels, and functions are copied by value.
+// The value must not contain reference cycles.
+func DeepCopy(v any) any {
+	switch v := v.(type) {
+	case nil, string, bool, int, int8, int16, int32, int64,
+		uint, uint8, uint16, uint32, uint64, uintptr, float32, float64, complex64, complex128:
+		return v
+	case []byte:
+		if v == nil {
+			return v
+		}
+		return append([]byte(nil), v...)
+	}
+
+	return deepCopyValue(reflect.ValueOf(v)).Interface()
+}
+
+// deepCopyValue returns a deep copy of v as described by DeepCopy.
+func deepCopyValue(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+		dst := reflect.New(v.Elem().Type())
+		dst.Elem().Set(deepCopyValue(v.Elem()))
+		return dst
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		dst := reflect.New(v.Type()).Elem()
+		dst.Set(deepCopyValue(v.Elem()))
+		return dst
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		dst := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := range v.Len() {
+			dst.Index(i).Set(deepCopyValue(v.Index(i)))
+		}
+		return dst
+	case reflect.Array:
+		dst := reflect.New(v.Type()).Elem()
+		for i := range v.Len() {
+			dst.Index(i).Set(deepCopyValue(v.Index(i)))
+		}
+		return dst
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		dst := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			dst.SetMapIndex(iter.Key(), deepCopyValue(iter.Value()))
+		}
+		return dst
+	case reflect.Struct:
+		dst := reflect.New(v.Type()).Elem()
+		dst.Set(v)
+		for i := range v.NumField() {
+			if field := dst.Field(i); field.CanSet() {
+				field.Set(deepCopyValue(v.Field(i)))
+			}
+		}
+		return dst
+	default:
+		return v
+	}
+}
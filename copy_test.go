@@ -0,0 +1,118 @@
+package cache
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+)
+
+type copyTestProfile struct {
+	Name  string
+	Tags  []string
+	Attrs map[string]int
+	Owner *copyTestProfile
+}
+
+func newCopyTestProfile() *copyTestProfile {
+	return &copyTestProfile{
+		Name:  "a",
+		Tags:  []string{"x", "y"},
+		Attrs: map[string]int{"n": 1},
+		Owner: &copyTestProfile{Name: "owner"},
+	}
+}
+
+// mutate changes every reference reachable from p.
+func (p *copyTestProfile) mutate() {
+	p.Name = "changed"
+	p.Tags[0] = "changed"
+	p.Attrs["n"] = 99
+	p.Owner.Name = "changed"
+}
+
+func TestDeepCopy(t *testing.T) {
+	original := newCopyTestProfile()
+	copied := DeepCopy(original).(*copyTestProfile)
+	if !reflect.DeepEqual(copied, original) {
+		t.Fatalf("DeepCopy() = %+v, want a value equal to %+v", copied, original)
+	}
+
+	original.mutate()
+	if !reflect.DeepEqual(copied, newCopyTestProfile()) {
+		t.Fatalf("copy changed with the original: %+v", copied)
+	}
+}
+
+func TestCopyingCacheIsolatesValues(t *testing.T) {
+	c := NewCacheWithCopy(nil)
+
+	original := newCopyTestProfile()
+	c.Set("profile", original)
+	original.mutate()
+
+	value, _ := c.Get("profile")
+	if !reflect.DeepEqual(value, newCopyTestProfile()) {
+		t.Fatalf("cached value changed with the original: %+v", value)
+	}
+
+	value.(*copyTestProfile).mutate()
+	again, _ := c.Get("profile")
+	if !reflect.DeepEqual(again, newCopyTestProfile()) {
+		t.Fatalf("cached value changed with a read value: %+v", again)
+	}
+}
+
+func TestDefaultCacheSharesValues(t *testing.T) {
+	c := NewCache()
+
+	original := newCopyTestProfile()
+	c.Set("profile", original)
+	if value, _ := c.Get("profile"); value != original {
+		t.Fatal("Get() returned a copy from a cache without copying")
+	}
+}
+
+func TestGetCopy(t *testing.T) {
+	c := NewCache()
+	lookup := c.(LookupCache)
+	original := newCopyTestProfile()
+	c.Set("profile", original)
+
+	copied, ok := lookup.GetCopy("profile")
+	if !ok || copied == original || !reflect.DeepEqual(copied, original) {
+		t.Fatalf("GetCopy(profile) = %+v, %v, want an equal value that is not the stored one", copied, ok)
+	}
+	copied.(*copyTestProfile).mutate()
+	if !reflect.DeepEqual(original, newCopyTestProfile()) {
+		t.Fatalf("stored value changed with a copy from GetCopy: %+v", original)
+	}
+
+	// Get still shares the stored value.
+	if shared, _ := c.Get("profile"); shared != original {
+		t.Fatal("Get() returned a copy after GetCopy, want the stored value shared")
+	}
+
+	if value, ok := lookup.GetCopy("missing"); ok || value != nil {
+		t.Fatalf("GetCopy(missing) = %v, %v, want nil, false", value, ok)
+	}
+}
+
+func TestGetCopyFunc(t *testing.T) {
+	calls := 0
+	c := NewCacheWithConfig(CacheConfig{GetCopyFunc: func(value any) any {
+		calls++
+		return slices.Clone(value.([]int))
+	}})
+	stored := []int{1, 2}
+	c.Set("a", stored)
+
+	copied, _ := c.(LookupCache).GetCopy("a")
+	copied.([]int)[0] = 99
+	if stored[0] != 1 || calls != 1 {
+		t.Fatalf("stored = %v after %d GetCopyFunc calls, want [1 2] copied by one call", stored, calls)
+	}
+	c.Get("a")
+	if calls != 1 {
+		t.Fatalf("GetCopyFunc calls = %d after Get, want Get not to copy", calls)
+	}
+}
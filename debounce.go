@@ -0,0 +1,70 @@
+package cache
+
+import (
+	"time"
+)
+
+// debounceState holds the pending write to a single key of a cache configured with WriteDebounce.
+// It exists only until the write is stored, so the cache keeps no state for keys whose writes have settled.
+type debounceState struct {
+	// pending is the latest write received since the last stored one. It is stored by timer.
+	pending *cachedItem
+	timer   stopper
+}
+
+// debounceLocked decides whether the write of item to key is stored now. It returns false, and records
+// the time of the write in item, if at least the debounce interval has passed since the last stored write
+// to key. Otherwise it keeps item as the pending write, replacing any earlier one, and schedules it to be
+// stored once the interval has passed. The caller must hold the write lock.
+func (c *inMemoryCache) debounceLocked(key string, item *cachedItem) bool {
+	now := time.Now()
+	if state, ok := c.debounced[key]; ok {
+		state.pending = item
+		return true
+	}
+
+	old, ok := c.items[key]
+	if !ok || now.Sub(old.written) >= c.debounce {
+		item.written = now
+		return false
+	}
+
+	if c.debounced == nil {
+		c.debounced = make(map[string]*debounceState)
+	}
+	state := &debounceState{pending: item}
+	state.timer = c.pool.afterFunc(old.written.Add(c.debounce).Sub(now), func() {
+		c.flushDebounced(key, state)
+	})
+	c.debounced[key] = state
+
+	return true
+}
+
+// flushDebounced stores the pending write of state and drops the state, unless the key has been removed
+// since the write was scheduled.
+func (c *inMemoryCache) flushDebounced(key string, state *debounceState) {
+	c.mu.Lock()
+	defer c.unlock()
+
+	if c.debounced[key] != state {
+		return
+	}
+	delete(c.debounced, key)
+
+	item := *state.pending
+	item.written = time.Now()
+	c.storeLocked(key, item)
+}
+
+// forgetDebounceLocked drops the debounce state of key and discards its pending write, if any.
+// The caller must hold the write lock.
+func (c *inMemoryCache) forgetDebounceLocked(key string) {
+	state, ok := c.debounced[key]
+	if !ok {
+		return
+	}
+
+	state.timer.Stop()
+	delete(c.debounced, key)
+}
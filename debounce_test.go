@@ -0,0 +1,110 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWriteDebounceKeepsTrailingWrite(t *testing.T) {
+	const interval = 50 * time.Millisecond
+	c := NewCacheWithConfig(CacheConfig{WriteDebounce: interval})
+
+	c.Set("hot", 1)
+	c.Set("hot", 2)
+	c.Set("hot", 3)
+	if value, _ := c.Get("hot"); value != 1 {
+		t.Fatalf("Get(hot) = %v right after the writes, want the first write 1", value)
+	}
+	c.Set("other", 1)
+	if value, _ := c.Get("other"); value != 1 {
+		t.Fatalf("Get(other) = %v, want writes to other keys stored right away", value)
+	}
+
+	eventually(t, func() bool {
+		value, _ := c.Get("hot")
+		return value == 3
+	}, "trailing write was not stored")
+}
+
+func TestWriteDebounceCoalescesHammeredKey(t *testing.T) {
+	const (
+		interval = 10 * time.Millisecond
+		duration = 100 * time.Millisecond
+	)
+	c := NewCacheWithConfig(CacheConfig{WriteDebounce: interval})
+	events, cancel := c.(WatchableCache).Watch("hot")
+	defer cancel()
+
+	stored := make(chan int)
+	go func() {
+		n := 0
+		for event := range events {
+			if event.Type == EventSet {
+				n++
+			}
+		}
+		stored <- n
+	}()
+
+	writes := 0
+	for start := time.Now(); time.Since(start) < duration; writes++ {
+		c.Set("hot", writes)
+	}
+	last := writes - 1
+	eventually(t, func() bool {
+		value, _ := c.Get("hot")
+		return value == last
+	}, "last write was not stored")
+	cancel()
+
+	// One write is stored at most every interval, plus the first write and the trailing one.
+	n := <-stored
+	if limit := int(duration/interval) + 2; n < 2 || n > limit {
+		t.Fatalf("stored %d of %d writes, want between 2 and %d", n, writes, limit)
+	}
+}
+
+func TestWriteDebounceDeleteDiscardsPendingWrite(t *testing.T) {
+	const interval = 20 * time.Millisecond
+	c := NewCacheWithConfig(CacheConfig{WriteDebounce: interval})
+	c.Set("a", 1)
+	c.Set("a", 2)
+	c.Delete("a")
+
+	time.Sleep(3 * interval)
+	if value, ok := c.Get("a"); ok {
+		t.Fatalf("Get(a) = %v after Delete, want the pending write discarded", value)
+	}
+}
+
+func TestWriteDebounceForgetsSettledKeys(t *testing.T) {
+	const interval = 10 * time.Millisecond
+	c := NewCacheWithConfig(CacheConfig{WriteDebounce: interval})
+	ic := c.(*inMemoryCache)
+	pending := func() int {
+		ic.mu.RLock()
+		defer ic.mu.RUnlock()
+		return len(ic.debounced)
+	}
+
+	keys := benchKeys(100)
+	for _, key := range keys {
+		c.Set(key, 1)
+	}
+	if n := pending(); n != 0 {
+		t.Fatalf("debounced keys = %d after writes stored right away, want 0", n)
+	}
+	for _, key := range keys {
+		c.Set(key, 2)
+	}
+	if n := pending(); n != len(keys) {
+		t.Fatalf("debounced keys = %d with a pending write to every key, want %d", n, len(keys))
+	}
+
+	eventually(t, func() bool { return pending() == 0 }, "debounce state was kept after the pending writes were stored")
+	for _, key := range keys {
+		if value, _ := c.Get(key); value != 2 {
+			t.Fatalf("Get(%s) = %v, want the trailing write 2", key, value)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package cache
+
+import (
+	"cmp"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+)
+
+// maxDumpValueLen is the number of runes of a formatted value that String shows before truncating it.
+const maxDumpValueLen = 64
+
+// String renders the live items of the cache for debugging, one "key=value (ttl remaining)" line per item
+// sorted by key. Values are formatted with %v and truncated to a readable length. Expired items are left out.
+func (c *inMemoryCache) String() string {
+	now := c.now()
+
+	var b strings.Builder
+	for _, entry := range c.sortedEntries() {
+		ttl := "no expiration"
+		if !entry.ExpiresAt.IsZero() {
+			ttl = entry.ExpiresAt.Sub(now).Round(time.Millisecond).String()
+		}
+		fmt.Fprintf(&b, "%s=%s (%s)\n", entry.Key, truncate(fmt.Sprintf("%v", entry.Value), maxDumpValueLen), ttl)
+	}
+
+	return b.String()
+}
+
+// ExportCSV writes the live items of the cache to w as CSV for analysis in a spreadsheet: a "key,value,expires_at"
+// header and one row per item sorted by key. Values are rendered with fmt.Sprint and expirations in RFC 3339,
+// or as "never" for items that do not expire. Expired items are left out.
+func (c *inMemoryCache) ExportCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"key", "value", "expires_at"}); err != nil {
+		return fmt.Errorf("cache: write CSV header: %w", err)
+	}
+
+	for _, entry := range c.sortedEntries() {
+		expiresAt := "never"
+		if !entry.ExpiresAt.IsZero() {
+			expiresAt = entry.ExpiresAt.Format(time.RFC3339)
+		}
+		if err := cw.Write([]string{entry.Key, fmt.Sprint(entry.Value), expiresAt}); err != nil {
+			return fmt.Errorf("cache: write CSV row for key %q: %w", entry.Key, err)
+		}
+	}
+
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("cache: write CSV: %w", err)
+	}
+
+	return nil
+}
+
+// sortedEntries returns the live items of the cache sorted by key.
+// Values are not copied, so callers must only format them.
+func (c *inMemoryCache) sortedEntries() []Entry {
+	c.mu.RLock()
+	entries := make([]Entry, 0, len(c.items))
+	for key, item := range c.items {
+		if c.isExpired(item) {
+			continue
+		}
+		entries = append(entries, Entry{Key: key, Value: settledValue(item.value), ExpiresAt: item.expiration})
+	}
+	c.mu.RUnlock()
+
+	slices.SortFunc(entries, func(a, b Entry) int {
+		return cmp.Compare(a.Key, b.Key)
+	})
+
+	return entries
+}
+
+// truncate shortens s to at most n runes, marking a cut with an ellipsis.
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+
+	return string(runes[:n-1]) + "…"
+}
@@ -0,0 +1,81 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestString(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCacheWithConfig(CacheConfig{Clock: clock})
+	c.Set("b", 2)
+	c.SetWithTTL("a", "one", time.Minute)
+	c.SetWithTTL("expired", 3, time.Second)
+	c.Set("long", strings.Repeat("x", 100))
+	clock.Advance(10 * time.Second)
+
+	got := c.(fmt.Stringer).String()
+	want := "a=one (50s)\n" +
+		"b=2 (no expiration)\n" +
+		"long=" + strings.Repeat("x", maxDumpValueLen-1) + "… (no expiration)\n"
+	if got != want {
+		t.Fatalf("String() =\n%s\nwant\n%s", got, want)
+	}
+	if strings.Contains(got, "expired") {
+		t.Fatalf("String() = %q, want the expired key left out", got)
+	}
+}
+
+func TestStringEmpty(t *testing.T) {
+	if got := NewCache().(fmt.Stringer).String(); got != "" {
+		t.Fatalf("String() of an empty cache = %q, want empty", got)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	for _, tt := range []struct {
+		s    string
+		n    int
+		want string
+	}{
+		{"short", 10, "short"},
+		{"exact", 5, "exact"},
+		{"toolong", 5, "tool…"},
+		{"héllo wörld", 6, "héllo…"},
+	} {
+		if got := truncate(tt.s, tt.n); got != tt.want {
+			t.Fatalf("truncate(%q, %d) = %q, want %q", tt.s, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestExportCSV(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCacheWithConfig(CacheConfig{Clock: clock})
+	c.SetWithTTL("b", "hello, world", time.Hour)
+	c.Set("a", []int{1, 2})
+	c.SetWithTTL("expired", 3, time.Second)
+	clock.Advance(time.Minute)
+
+	var buf strings.Builder
+	if err := c.(InspectableCache).ExportCSV(&buf); err != nil {
+		t.Fatalf("ExportCSV() error = %v", err)
+	}
+	want := "key,value,expires_at\n" +
+		"a,[1 2],never\n" +
+		"b,\"hello, world\",2024-01-01T01:00:00Z\n"
+	if got := buf.String(); got != want {
+		t.Fatalf("ExportCSV() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestExportCSVWriteError(t *testing.T) {
+	c := NewCache()
+	c.Set("a", 1)
+	if err := c.(InspectableCache).ExportCSV(failingWriter{}); !errors.Is(err, errWrite) {
+		t.Fatalf("ExportCSV() error = %v, want the writer's error", err)
+	}
+}
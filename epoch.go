@@ -0,0 +1,24 @@
+package cache
+
+import (
+	"sync/atomic"
+)
+
+// epoch is a generation of items. Every item records the epoch it was stored in,
+// and InvalidateAll ends the current epoch, which makes all of its items stale at once.
+type epoch struct {
+	ended atomic.Bool
+}
+
+// invalidated reports whether the item was stored in an epoch ended by InvalidateAll.
+func (ci cachedItem) invalidated() bool {
+	return ci.epoch != nil && ci.epoch.ended.Load()
+}
+
+// InvalidateAll makes every item currently stored absent in O(1), without taking the write lock,
+// by starting a new epoch. Items from older epochs count as expired for reads, but unlike expired items
+// their removal is notified as EventDelete and does not run expiration callbacks, as with Clear.
+// They keep their memory until they are read, overwritten, or removed by a cache worker.
+func (c *inMemoryCache) InvalidateAll() {
+	c.epoch.Swap(new(epoch)).ended.Store(true)
+}
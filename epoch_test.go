@@ -0,0 +1,72 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInvalidateAll(t *testing.T) {
+	c := NewCache()
+	var expired int
+	c.(TTLCache).SetWithExpireCallback("callback", 1, time.Hour, func(any) { expired++ })
+	c.Set("a", 1)
+	c.Set("b", 2)
+	events, cancel := c.(WatchableCache).Watch("a")
+	defer cancel()
+
+	c.(BulkCache).InvalidateAll()
+	for _, key := range []string{"a", "b", "callback"} {
+		if _, ok := c.Get(key); ok {
+			t.Fatalf("Get(%s) ok = true after InvalidateAll", key)
+		}
+	}
+	if event := nextEvent(t, events); event.Type != EventDelete || event.Key != "a" {
+		t.Fatalf("event = %+v, want a delete for the stale item", event)
+	}
+	if expired != 0 {
+		t.Fatalf("expiration callback ran %d times for an invalidated item, want 0", expired)
+	}
+
+	// Items stored after the invalidation are live.
+	c.Set("a", "new")
+	if value, ok := c.Get("a"); !ok || value != "new" {
+		t.Fatalf("Get(a) = %v, %v after setting it again, want new, true", value, ok)
+	}
+}
+
+func TestInvalidateAllReclaimedByCleanup(t *testing.T) {
+	c := NewCache()
+	for _, key := range benchKeys(100) {
+		c.Set(key, 1)
+	}
+	c.(BulkCache).InvalidateAll()
+	c.Set("live", 1)
+
+	// Stale items keep their memory until cleanup removes them.
+	if size := c.(StatsReporter).Stats().Size; size != 101 {
+		t.Fatalf("Size = %d before cleanup, want the stale items still stored", size)
+	}
+	if removed := c.(Cleanable).RemoveExpired(); removed != 100 {
+		t.Fatalf("RemoveExpired() = %d, want the 100 stale items", removed)
+	}
+	if stats := c.(StatsReporter).Stats(); stats.Size != 1 || stats.Expired != 0 {
+		t.Fatalf("Size, Expired = %d, %d, want 1, 0 since invalidation is not expiration", stats.Size, stats.Expired)
+	}
+}
+
+func TestInvalidateAllTwice(t *testing.T) {
+	c := NewCache()
+	c.Set("a", 1)
+	c.(BulkCache).InvalidateAll()
+	c.Set("b", 2)
+	c.(BulkCache).InvalidateAll()
+
+	for _, key := range []string{"a", "b"} {
+		if _, ok := c.Get(key); ok {
+			t.Fatalf("Get(%s) ok = true after InvalidateAll", key)
+		}
+	}
+	if err := c.(Verifier).Verify(); err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+}
@@ -0,0 +1,33 @@
+package cache
+
+import (
+	"errors"
+)
+
+// Sentinel errors returned by the error-returning cache APIs.
+// Returned errors may wrap these values, so compare them with errors.Is.
+var (
+	// ErrEmptyKey is returned when an operation receives an empty key.
+	ErrEmptyKey = errors.New("cache: empty key")
+	// ErrNotFound is returned when the requested key does not exist or is expired.
+	ErrNotFound = errors.New("cache: key not found")
+	// ErrWrongType is returned when the stored value does not have the type an operation expects.
+	ErrWrongType = errors.New("cache: wrong value type")
+	// ErrNilValue is returned by SetChecked when the cache rejects nil values.
+	ErrNilValue = errors.New("cache: nil value")
+	// ErrCacheFull is returned by SetChecked when a bounded cache is full, none of its items can be evicted,
+	// and it is configured with FullReject.
+	ErrCacheFull = errors.New("cache: cache is full")
+	// ErrNotAdmitted is returned by SetChecked when a full cache with TinyLFU admission rejects a new key
+	// because it was accessed less often than the item it would evict.
+	ErrNotAdmitted = errors.New("cache: key not admitted")
+	// ErrValueTooLarge is returned by SetChecked when a value's size, as measured by the cache's Sizer,
+	// exceeds the configured MaxValueSize.
+	ErrValueTooLarge = errors.New("cache: value too large")
+	// ErrNoTenant is returned by the checked methods of TenantCache when the context carries no tenant ID.
+	ErrNoTenant = errors.New("cache: no tenant in context")
+	// ErrPanic is returned when a user-supplied callback panicked and the panic was recovered.
+	ErrPanic = errors.New("cache: callback panicked")
+	// ErrSnapshotVersion is returned when a snapshot was written in a format version this package cannot read.
+	ErrSnapshotVersion = errors.New("cache: unsupported snapshot version")
+)
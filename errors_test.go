@@ -0,0 +1,152 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"io"
+	"log"
+	"testing"
+	"time"
+)
+
+func TestSentinelErrors(t *testing.T) {
+	type tenantKey struct{}
+
+	tests := []struct {
+		name string
+		err  func() error
+		want error
+	}{
+		{
+			name: "SetChecked empty key",
+			err: func() error {
+				return NewCache().(CheckedCache).SetChecked("", 1, 0)
+			},
+			want: ErrEmptyKey,
+		},
+		{
+			name: "SetChecked nil value",
+			err: func() error {
+				c := NewCacheWithConfig(CacheConfig{RejectNilValues: true})
+				return c.(CheckedCache).SetChecked("a", nil, 0)
+			},
+			want: ErrNilValue,
+		},
+		{
+			name: "SetChecked value too large",
+			err: func() error {
+				c := NewCacheWithConfig(CacheConfig{MaxValueSize: 4})
+				return c.(CheckedCache).SetChecked("a", "hello", 0)
+			},
+			want: ErrValueTooLarge,
+		},
+		{
+			name: "SetChecked full cache",
+			err: func() error {
+				c := NewCacheWithConfig(CacheConfig{Capacity: 1, FullBehavior: FullReject})
+				c.Set("pinned", 1)
+				c.(EvictionCache).Pin("pinned")
+				return c.(CheckedCache).SetChecked("b", 2, 0)
+			},
+			want: ErrCacheFull,
+		},
+		{
+			name: "SetChecked not admitted",
+			err: func() error {
+				c := NewCacheWithConfig(CacheConfig{Capacity: 1, TinyLFUAdmission: true})
+				c.Set("hot", 1)
+				for range 10 {
+					c.Get("hot")
+				}
+				return c.(CheckedCache).SetChecked("cold", 2, 0)
+			},
+			want: ErrNotAdmitted,
+		},
+		{
+			name: "Increment wrong type",
+			err: func() error {
+				c := NewCache()
+				c.Set("a", "text")
+				_, err := c.(AtomicCache).Increment("a", 1)
+				return err
+			},
+			want: ErrWrongType,
+		},
+		{
+			name: "GetJSON wrong type",
+			err: func() error {
+				c := NewCache()
+				c.Set("a", 42)
+				_, _, err := GetJSON[map[string]any](c, "a")
+				return err
+			},
+			want: ErrWrongType,
+		},
+		{
+			name: "GetCtxChecked missing key",
+			err: func() error {
+				tenants := NewTenantCache(NewCache(), TenantConfig{ContextKey: tenantKey{}})
+				ctx := context.WithValue(context.Background(), tenantKey{}, "acme")
+				_, err := tenants.GetCtxChecked(ctx, "missing")
+				return err
+			},
+			want: ErrNotFound,
+		},
+		{
+			name: "GetCtxChecked no tenant",
+			err: func() error {
+				tenants := NewTenantCache(NewCache(), TenantConfig{ContextKey: tenantKey{}})
+				_, err := tenants.GetCtxChecked(context.Background(), "a")
+				return err
+			},
+			want: ErrNoTenant,
+		},
+		{
+			name: "GetOrComputeContext panic",
+			err: func() error {
+				c := NewCacheWithConfig(CacheConfig{Logger: log.New(io.Discard, "", 0)})
+				_, err := c.(ComputeCache).GetOrComputeContext(context.Background(), "a", func(ctx context.Context) (any, time.Duration, error) {
+					panic("boom")
+				})
+				return err
+			},
+			want: ErrPanic,
+		},
+		{
+			name: "ReadSnapshot unsupported version",
+			err: func() error {
+				var buf bytes.Buffer
+				if err := gob.NewEncoder(&buf).Encode(snapshotHeader{Version: SnapshotVersion + 1}); err != nil {
+					return err
+				}
+				return NewCache().(SnapshotCache).ReadSnapshot(&buf)
+			},
+			want: ErrSnapshotVersion,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.err(); !errors.Is(err, tt.want) {
+				t.Fatalf("error = %v, want one wrapping %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestMaxValueSizeDropsLargeValues(t *testing.T) {
+	c := NewCacheWithConfig(CacheConfig{MaxValueSize: 4})
+
+	c.Set("a", "abc")
+	c.Set("a", "too large")
+	if value, ok := c.Get("a"); !ok || value != "abc" {
+		t.Fatalf("Get(a) = %v, %v, want the previous value abc, true", value, ok)
+	}
+
+	c.Set("b", "too large")
+	if _, ok := c.Get("b"); ok {
+		t.Fatal("a value over MaxValueSize was stored")
+	}
+}
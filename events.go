@@ -0,0 +1,168 @@
+package cache
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// WatchBufferSize is the capacity of the channels returned by Watch.
+// When a watcher's buffer is full, new events for it are dropped rather than blocking the cache.
+const WatchBufferSize = 16
+
+// EventType identifies the kind of change described by a CacheEvent.
+type EventType int
+
+const (
+	// EventSet is emitted when a value is stored.
+	EventSet EventType = iota + 1
+	// EventDelete is emitted when an item is deleted or cleared.
+	EventDelete
+	// EventExpire is emitted when an expired item is removed.
+	EventExpire
+	// EventEvict is emitted when a bounded cache evicts an item to make room.
+	EventEvict
+)
+
+// String returns the name of the event type.
+func (t EventType) String() string {
+	switch t {
+	case EventSet:
+		return "set"
+	case EventDelete:
+		return "delete"
+	case EventExpire:
+		return "expire"
+	case EventEvict:
+		return "evict"
+	default:
+		return "unknown"
+	}
+}
+
+// CacheEvent describes a change to a single key.
+type CacheEvent struct {
+	Type  EventType
+	Key   string
+	Value any // The stored value for EventSet, the removed value otherwise.
+}
+
+// watchers holds the channels subscribed to individual keys.
+type watchers struct {
+	mu   sync.Mutex
+	subs map[string]map[chan CacheEvent]struct{}
+	// count is the number of subscribed channels, read without the lock to skip unwatched caches quickly.
+	count atomic.Int64
+	// coalesce, if positive, is the window in which events for a key are merged into the latest one.
+	// pending holds the latest event of each key whose window is open.
+	coalesce time.Duration
+	pending  map[string]CacheEvent
+	// pool, if set, runs the flushes of coalescing windows.
+	pool *TaskPool
+}
+
+// watch subscribes a new channel to key and returns it with a function that unsubscribes and closes it.
+func (w *watchers) watch(key string) (<-chan CacheEvent, func()) {
+	ch := make(chan CacheEvent, WatchBufferSize)
+
+	w.mu.Lock()
+	if w.subs == nil {
+		w.subs = make(map[string]map[chan CacheEvent]struct{})
+	}
+	if w.subs[key] == nil {
+		w.subs[key] = make(map[chan CacheEvent]struct{})
+	}
+	w.subs[key][ch] = struct{}{}
+	w.count.Add(1)
+	w.mu.Unlock()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			w.mu.Lock()
+			defer w.mu.Unlock()
+
+			delete(w.subs[key], ch)
+			if len(w.subs[key]) == 0 {
+				delete(w.subs, key)
+			}
+			w.count.Add(-1)
+			close(ch)
+		})
+	}
+
+	return ch, cancel
+}
+
+// emit delivers event to the channels watching its key without blocking.
+// If events are coalesced, the first event for a key opens a window at the end of which only
+// the latest event received for the key in the window is delivered.
+func (w *watchers) emit(event CacheEvent) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.coalesce <= 0 {
+		w.deliverLocked(event)
+		return
+	}
+
+	if _, ok := w.pending[event.Key]; !ok {
+		w.pool.afterFunc(w.coalesce, func() { w.flush(event.Key) })
+	}
+	if w.pending == nil {
+		w.pending = make(map[string]CacheEvent)
+	}
+	w.pending[event.Key] = event
+}
+
+// flush delivers the pending event for key and closes its coalescing window.
+func (w *watchers) flush(key string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	event, ok := w.pending[key]
+	if !ok {
+		return
+	}
+	delete(w.pending, key)
+	w.deliverLocked(event)
+}
+
+// deliverLocked sends event to the channels watching its key without blocking. The caller must hold w.mu.
+func (w *watchers) deliverLocked(event CacheEvent) {
+	for ch := range w.subs[event.Key] {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// watched reports whether any channel watches key.
+func (w *watchers) watched(key string) bool {
+	if w.count.Load() == 0 {
+		return false
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return len(w.subs[key]) > 0
+}
+
+// keys returns the watched keys.
+func (w *watchers) keys() []string {
+	if w.count.Load() == 0 {
+		return nil
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	keys := make([]string, 0, len(w.subs))
+	for key := range w.subs {
+		keys = append(keys, key)
+	}
+
+	return keys
+}
@@ -0,0 +1,101 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+// nextEvent receives the next event from ch, failing the test if none arrives within a second.
+func nextEvent(t *testing.T, ch <-chan CacheEvent) CacheEvent {
+	t.Helper()
+
+	select {
+	case event := <-ch:
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("no event received")
+		return CacheEvent{}
+	}
+}
+
+// noEvent fails the test if ch holds an event.
+func noEvent(t *testing.T, ch <-chan CacheEvent) {
+	t.Helper()
+
+	select {
+	case event := <-ch:
+		t.Fatalf("unexpected event %+v", event)
+	default:
+	}
+}
+
+func TestWatchKey(t *testing.T) {
+	c := NewCache()
+	events, cancel := c.(WatchableCache).Watch("watched")
+	defer cancel()
+
+	c.Set("watched", 1)
+	c.Set("other", 2)
+	c.Delete("other")
+	c.Delete("watched")
+
+	want := []CacheEvent{
+		{Type: EventSet, Key: "watched", Value: 1},
+		{Type: EventDelete, Key: "watched", Value: 1},
+	}
+	for _, w := range want {
+		if event := nextEvent(t, events); event != w {
+			t.Fatalf("event = %+v, want %+v", event, w)
+		}
+	}
+	noEvent(t, events)
+}
+
+func TestWatchCancel(t *testing.T) {
+	c := NewCache()
+	events, cancel := c.(WatchableCache).Watch("watched")
+	cancel()
+	cancel()
+
+	c.Set("watched", 1)
+	if _, open := <-events; open {
+		t.Fatal("channel still open after cancel")
+	}
+}
+
+func TestEventTypeString(t *testing.T) {
+	for eventType, want := range map[EventType]string{
+		EventSet: "set", EventDelete: "delete", EventExpire: "expire", EventEvict: "evict", EventType(0): "unknown",
+	} {
+		if got := eventType.String(); got != want {
+			t.Fatalf("EventType(%d).String() = %q, want %q", eventType, got, want)
+		}
+	}
+}
+
+func TestWatchCoalesce(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCacheWithConfig(CacheConfig{Clock: clock, WatchCoalesce: 50 * time.Millisecond})
+	events, cancel := c.(WatchableCache).Watch("hot")
+	defer cancel()
+
+	for i := range 10 {
+		c.SetWithTTL("hot", i, time.Second)
+	}
+	clock.Advance(time.Minute)
+	c.Get("hot")
+
+	// The ten sets and the expiration within the window arrive as one event carrying the expiration.
+	noEvent(t, events)
+	if event := nextEvent(t, events); event != (CacheEvent{Type: EventExpire, Key: "hot", Value: 9}) {
+		t.Fatalf("event = %+v, want a single expire event for the last value", event)
+	}
+	time.Sleep(100 * time.Millisecond)
+	noEvent(t, events)
+
+	// A change after the window opens a new one.
+	c.Set("hot", "again")
+	if event := nextEvent(t, events); event != (CacheEvent{Type: EventSet, Key: "hot", Value: "again"}) {
+		t.Fatalf("event = %+v, want the set after the window", event)
+	}
+}
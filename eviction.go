@@ -0,0 +1,501 @@
+package cache
+
+import (
+	"cmp"
+	"container/heap"
+	"container/list"
+	"iter"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultEvictionSamples is the number of keys sampled by NewRandomSamplePolicy when no sample size is given.
+const DefaultEvictionSamples = 5
+
+// EvictionPolicy decides which items a bounded cache evicts when it exceeds its capacity.
+// The cache calls the hooks while holding its lock, so implementations must not call back into the cache.
+// OnAccess may be called by concurrent readers, and so may OnRemove for the keys removed by Clear,
+// so implementations must be safe for concurrent use.
+type EvictionPolicy interface {
+	// OnAdd is called after a new key is added to the cache.
+	OnAdd(key string)
+	// OnAccess is called after an existing key is read or overwritten.
+	OnAccess(key string)
+	// OnRemove is called after a key is removed from the cache, including evictions.
+	OnRemove(key string)
+	// Victim returns the key that should be evicted next.
+	// Returns ("", false) if the policy tracks no keys.
+	Victim() (key string, ok bool)
+}
+
+// OrderedPolicy is implemented by eviction policies that can list the keys they track in eviction order.
+// A bounded cache uses it to evict the items of the lowest priority first and to skip pinned items.
+// With a policy that does not implement it, the cache always evicts the policy's Victim, regardless of priority,
+// and treats itself as full when that victim is pinned. The built-in policies implement it.
+// The cache does not call any other hook while it iterates over Candidates.
+type OrderedPolicy interface {
+	EvictionPolicy
+	// Candidates returns the tracked keys in the order the policy would evict them.
+	Candidates() iter.Seq[string]
+}
+
+// FullBehavior decides what a bounded cache does when it is full and its eviction policy offers no item to evict,
+// for example because all items are pinned.
+type FullBehavior int
+
+const (
+	// FullReject refuses to store new keys. SetChecked and Increment return ErrCacheFull;
+	// other setters drop the value.
+	FullReject FullBehavior = iota
+	// FullEvictLowestPriority evicts the item with the lowest priority anyway, even if it is pinned.
+	FullEvictLowestPriority
+)
+
+// resettablePolicy is implemented by the built-in eviction policies so that
+// clearing a cache can drop all tracked keys at once instead of calling OnRemove for each.
+type resettablePolicy interface {
+	// reset stops tracking all keys.
+	reset()
+}
+
+// seedablePolicy is implemented by the built-in eviction policies that make random choices,
+// so that a cache configured with a seed can make them reproducible.
+type seedablePolicy interface {
+	// seed replaces the policy's random source with one seeded with s.
+	seed(s uint64)
+}
+
+// listPolicy tracks keys in a doubly linked list ordered from newest to oldest.
+type listPolicy struct {
+	mu       sync.Mutex
+	order    *list.List
+	elements map[string]*list.Element
+	// moveOnAccess moves accessed keys to the front of the list.
+	moveOnAccess bool
+}
+
+// NewLRUPolicy returns an eviction policy that evicts the least recently used key.
+func NewLRUPolicy() EvictionPolicy {
+	return newListPolicy(true)
+}
+
+// NewFIFOPolicy returns an eviction policy that evicts the earliest inserted key, regardless of access.
+func NewFIFOPolicy() EvictionPolicy {
+	return newListPolicy(false)
+}
+
+// newListPolicy creates an empty listPolicy.
+func newListPolicy(moveOnAccess bool) *listPolicy {
+	return &listPolicy{
+		order:        list.New(),
+		elements:     make(map[string]*list.Element),
+		moveOnAccess: moveOnAccess,
+	}
+}
+
+// OnAdd places the key at the front of the list.
+func (p *listPolicy) OnAdd(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if elem, ok := p.elements[key]; ok {
+		p.order.MoveToFront(elem)
+		return
+	}
+	p.elements[key] = p.order.PushFront(key)
+}
+
+// OnAccess moves the key to the front of the list if the policy tracks recency.
+func (p *listPolicy) OnAccess(key string) {
+	if !p.moveOnAccess {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if elem, ok := p.elements[key]; ok {
+		p.order.MoveToFront(elem)
+	}
+}
+
+// OnRemove removes the key from the list.
+func (p *listPolicy) OnRemove(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if elem, ok := p.elements[key]; ok {
+		p.order.Remove(elem)
+		delete(p.elements, key)
+	}
+}
+
+// reset empties the list.
+func (p *listPolicy) reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.order.Init()
+	p.elements = make(map[string]*list.Element)
+}
+
+// Victim returns the key at the back of the list.
+func (p *listPolicy) Victim() (string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	elem := p.order.Back()
+	if elem == nil {
+		return "", false
+	}
+
+	return elem.Value.(string), true
+}
+
+// Candidates returns the keys from the back of the list to the front.
+func (p *listPolicy) Candidates() iter.Seq[string] {
+	return func(yield func(string) bool) {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		for elem := p.order.Back(); elem != nil; elem = elem.Prev() {
+			if !yield(elem.Value.(string)) {
+				return
+			}
+		}
+	}
+}
+
+// sampledKey is a key tracked by randomSamplePolicy together with its last access tick.
+type sampledKey struct {
+	key        string
+	lastAccess atomic.Uint64
+}
+
+// randomSamplePolicy approximates LRU by sampling a few random keys and evicting the least recently used among them.
+type randomSamplePolicy struct {
+	mu      sync.RWMutex
+	keys    []*sampledKey
+	index   map[string]int
+	tick    atomic.Uint64
+	samples int
+	rand    *rand.Rand
+}
+
+// NewRandomSamplePolicy returns an eviction policy that samples the given number of random keys
+// and evicts the least recently accessed key among the sample.
+// Accesses only record a tick under a shared lock, which is cheaper than maintaining an exact LRU order.
+// If samples <= 0, DefaultEvictionSamples is used.
+func NewRandomSamplePolicy(samples int) EvictionPolicy {
+	if samples <= 0 {
+		samples = DefaultEvictionSamples
+	}
+
+	return &randomSamplePolicy{
+		index:   make(map[string]int),
+		samples: samples,
+		rand:    rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64())),
+	}
+}
+
+// seed replaces the policy's random source with one seeded with s.
+func (p *randomSamplePolicy) seed(s uint64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.rand = rand.New(rand.NewPCG(s, s))
+}
+
+// OnAdd starts tracking the key.
+func (p *randomSamplePolicy) OnAdd(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.index[key]; ok {
+		return
+	}
+
+	entry := &sampledKey{key: key}
+	entry.lastAccess.Store(p.tick.Add(1))
+	p.index[key] = len(p.keys)
+	p.keys = append(p.keys, entry)
+}
+
+// OnAccess records the current tick as the key's last access. The tick only advances when a key is added,
+// so concurrent reads do not contend on it, and a key already accessed at the current tick is not written again.
+func (p *randomSamplePolicy) OnAccess(key string) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if i, ok := p.index[key]; ok {
+		entry, tick := p.keys[i], p.tick.Load()
+		if entry.lastAccess.Load() != tick {
+			entry.lastAccess.Store(tick)
+		}
+	}
+}
+
+// OnRemove stops tracking the key.
+func (p *randomSamplePolicy) OnRemove(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	i, ok := p.index[key]
+	if !ok {
+		return
+	}
+
+	last := len(p.keys) - 1
+	p.keys[i] = p.keys[last]
+	p.index[p.keys[i].key] = i
+	p.keys[last] = nil
+	p.keys = p.keys[:last]
+	delete(p.index, key)
+}
+
+// reset stops tracking all keys.
+func (p *randomSamplePolicy) reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.keys = nil
+	p.index = make(map[string]int)
+}
+
+// Victim returns the least recently accessed key among a random sample of tracked keys.
+func (p *randomSamplePolicy) Victim() (string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.keys) == 0 {
+		return "", false
+	}
+
+	var victim *sampledKey
+	for range min(p.samples, len(p.keys)) {
+		candidate := p.keys[p.rand.IntN(len(p.keys))]
+		if victim == nil || candidate.lastAccess.Load() < victim.lastAccess.Load() {
+			victim = candidate
+		}
+	}
+
+	return victim.key, true
+}
+
+// Candidates returns a random sample of keys ordered from least to most recently accessed,
+// followed by all tracked keys starting at a random position.
+func (p *randomSamplePolicy) Candidates() iter.Seq[string] {
+	return func(yield func(string) bool) {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		n := len(p.keys)
+		if n == 0 {
+			return
+		}
+
+		sample := make([]*sampledKey, 0, min(p.samples, n))
+		for range cap(sample) {
+			sample = append(sample, p.keys[p.rand.IntN(n)])
+		}
+		slices.SortFunc(sample, func(a, b *sampledKey) int {
+			return cmp.Compare(a.lastAccess.Load(), b.lastAccess.Load())
+		})
+		for _, entry := range sample {
+			if !yield(entry.key) {
+				return
+			}
+		}
+
+		offset := p.rand.IntN(n)
+		for i := range n {
+			if !yield(p.keys[(offset+i)%n].key) {
+				return
+			}
+		}
+	}
+}
+
+// CostAwarePolicy is implemented by eviction policies that rank keys by the cost of reloading them.
+// A bounded cache calls OnCost, while holding its lock, every time a key is stored,
+// right after OnAdd or OnAccess.
+type CostAwarePolicy interface {
+	EvictionPolicy
+	// OnCost records the reload cost of the value stored under key and the value's size.
+	// Cost is the value given to SetWithCost, or 0 if the key was stored without one.
+	OnCost(key string, cost float64, size int)
+}
+
+// gdsfEntry is a key tracked by gdsfPolicy.
+type gdsfEntry struct {
+	key string
+	// cost is the reload cost per unit of size.
+	cost float64
+	hits float64
+	// value is the key's rank: the policy's inflation plus hits times cost.
+	value float64
+	index int
+}
+
+// gdsfHeap is a min-heap of gdsfEntry ordered by value.
+type gdsfHeap []*gdsfEntry
+
+// Len, Less, Swap, Push, and Pop implement heap.Interface.
+func (h gdsfHeap) Len() int           { return len(h) }
+func (h gdsfHeap) Less(i, j int) bool { return h[i].value < h[j].value }
+
+func (h gdsfHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *gdsfHeap) Push(x any) {
+	entry := x.(*gdsfEntry)
+	entry.index = len(*h)
+	*h = append(*h, entry)
+}
+
+func (h *gdsfHeap) Pop() any {
+	old := *h
+	n := len(old)
+	entry := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+
+	return entry
+}
+
+// gdsfPolicy implements Greedy-Dual-Size-Frequency eviction.
+type gdsfPolicy struct {
+	mu      sync.Mutex
+	heap    gdsfHeap
+	entries map[string]*gdsfEntry
+	// inflation is the value of the last evicted key. Adding it to the value of every stored or accessed key
+	// ages out keys that were valuable once but are no longer accessed.
+	inflation float64
+	// victim is the key last returned by Victim or Candidates, whose removal is treated as an eviction.
+	victim string
+}
+
+// NewGDSFPolicy returns a cost-aware eviction policy that evicts the key with the lowest
+// frequency * cost / size first, so values that are cheap to reload or large are evicted before
+// small values that are expensive to reload. Keys stored without a cost count as cost 1,
+// and values other than strings and byte slices count as size 1.
+func NewGDSFPolicy() CostAwarePolicy {
+	return &gdsfPolicy{
+		entries: make(map[string]*gdsfEntry),
+	}
+}
+
+// OnAdd starts tracking the key with cost 1.
+func (p *gdsfPolicy) OnAdd(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.entries[key]; ok {
+		return
+	}
+
+	entry := &gdsfEntry{key: key, cost: 1, hits: 1}
+	entry.value = p.inflation + entry.cost
+	p.entries[key] = entry
+	heap.Push(&p.heap, entry)
+}
+
+// OnAccess counts a hit for the key and raises its value.
+func (p *gdsfPolicy) OnAccess(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if entry, ok := p.entries[key]; ok {
+		entry.hits++
+		p.updateLocked(entry)
+	}
+}
+
+// OnCost records the cost per unit of size of the key and recomputes its value.
+func (p *gdsfPolicy) OnCost(key string, cost float64, size int) {
+	if cost <= 0 {
+		cost = 1
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if entry, ok := p.entries[key]; ok {
+		entry.cost = cost / float64(max(size, 1))
+		p.updateLocked(entry)
+	}
+}
+
+// OnRemove stops tracking the key. If the key is the last victim, the inflation is raised to its value.
+func (p *gdsfPolicy) OnRemove(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	entry, ok := p.entries[key]
+	if !ok {
+		return
+	}
+
+	if key == p.victim {
+		p.inflation = entry.value
+		p.victim = ""
+	}
+	heap.Remove(&p.heap, entry.index)
+	delete(p.entries, key)
+}
+
+// Victim returns the key with the lowest value.
+func (p *gdsfPolicy) Victim() (string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.heap) == 0 {
+		return "", false
+	}
+	p.victim = p.heap[0].key
+
+	return p.victim, true
+}
+
+// Candidates returns the keys from the lowest value to the highest.
+// Sorting a copy of the heap costs O(n log n), so it is only worth it while the cache holds items of several priorities.
+func (p *gdsfPolicy) Candidates() iter.Seq[string] {
+	return func(yield func(string) bool) {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		sorted := slices.Clone(p.heap)
+		slices.SortFunc(sorted, func(a, b *gdsfEntry) int {
+			return cmp.Compare(a.value, b.value)
+		})
+		for _, entry := range sorted {
+			if !yield(entry.key) {
+				// The cache stops at the key it evicts.
+				p.victim = entry.key
+				return
+			}
+		}
+	}
+}
+
+// reset stops tracking all keys.
+func (p *gdsfPolicy) reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.heap = nil
+	p.entries = make(map[string]*gdsfEntry)
+	p.inflation = 0
+	p.victim = ""
+}
+
+// updateLocked recomputes the value of entry and restores the heap order. The caller must hold p.mu.
+func (p *gdsfPolicy) updateLocked(entry *gdsfEntry) {
+	entry.value = p.inflation + entry.hits*entry.cost
+	heap.Fix(&p.heap, entry.index)
+}
@@ -0,0 +1,526 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingPolicy is a trivial eviction policy that evicts the oldest added key and records every hook call.
+type recordingPolicy struct {
+	mu    sync.Mutex
+	keys  []string
+	calls []string
+}
+
+func (p *recordingPolicy) record(call string) {
+	p.calls = append(p.calls, call)
+}
+
+func (p *recordingPolicy) OnAdd(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.record("add:" + key)
+	p.keys = append(p.keys, key)
+}
+
+func (p *recordingPolicy) OnAccess(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.record("access:" + key)
+}
+
+func (p *recordingPolicy) OnRemove(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.record("remove:" + key)
+	p.keys = slices.DeleteFunc(p.keys, func(k string) bool { return k == key })
+}
+
+func (p *recordingPolicy) Victim() (string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.record("victim")
+	if len(p.keys) == 0 {
+		return "", false
+	}
+
+	return p.keys[0], true
+}
+
+func (p *recordingPolicy) Calls() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return slices.Clone(p.calls)
+}
+
+func TestCustomPolicyHookOrder(t *testing.T) {
+	policy := &recordingPolicy{}
+	c := NewBoundedCache(2, policy)
+
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Get("a")
+	c.Set("a", 3)
+	c.Set("c", 4)
+	c.Delete("c")
+
+	want := []string{
+		"add:a",
+		"add:b",
+		"access:a",
+		"access:a",
+		// A full cache asks for a victim before storing a new key, to check that it can make room.
+		"victim", "add:c", "victim", "remove:a",
+		"remove:c",
+	}
+	if got := policy.Calls(); !slices.Equal(got, want) {
+		t.Fatalf("hook calls = %q, want %q", got, want)
+	}
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("the policy's victim was not evicted")
+	}
+	if _, ok := c.Get("b"); !ok {
+		t.Fatal("an item the policy did not choose was evicted")
+	}
+}
+
+func TestLRUPolicyEvictsLeastRecentlyUsed(t *testing.T) {
+	c := NewBoundedCache(3, NewLRUPolicy())
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Set("c", 3)
+	c.Get("a")
+
+	c.Set("d", 4)
+	if _, ok := c.Get("b"); ok {
+		t.Fatal("least recently used key b was not evicted")
+	}
+	for _, key := range []string{"a", "c", "d"} {
+		if _, ok := c.Get(key); !ok {
+			t.Fatalf("key %s was evicted", key)
+		}
+	}
+}
+
+func TestFIFOPolicyIgnoresReads(t *testing.T) {
+	c := NewBoundedCache(3, NewFIFOPolicy())
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Set("c", 3)
+	for range 5 {
+		c.Get("a")
+	}
+
+	c.Set("d", 4)
+	c.Set("e", 5)
+	for _, key := range []string{"a", "b"} {
+		if _, ok := c.Get(key); ok {
+			t.Fatalf("earliest inserted key %s was not evicted", key)
+		}
+	}
+	for _, key := range []string{"c", "d", "e"} {
+		if _, ok := c.Get(key); !ok {
+			t.Fatalf("key %s was evicted", key)
+		}
+	}
+}
+
+func TestRandomSamplePolicyKeepsCapacity(t *testing.T) {
+	const capacity = 100
+	c := NewBoundedCache(capacity, NewRandomSamplePolicy(5))
+
+	for i := range 10 * capacity {
+		c.Set(fmt.Sprintf("key:%d", i), i)
+		if size := c.(StatsReporter).Stats().Size; size > capacity {
+			t.Fatalf("Size = %d after %d inserts, want at most %d", size, i+1, capacity)
+		}
+	}
+	if size := c.(StatsReporter).Stats().Size; size != capacity {
+		t.Fatalf("Size = %d, want %d", size, capacity)
+	}
+	if err := c.(Verifier).Verify(); err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+}
+
+func TestRandomSamplePolicyPrefersStaleKeys(t *testing.T) {
+	const capacity = 100
+	c := NewCacheWithConfig(CacheConfig{Capacity: capacity, Policy: NewRandomSamplePolicy(10), Seed: 1})
+	for i := range capacity {
+		c.Set(fmt.Sprintf("key:%d", i), i)
+	}
+	// Keep the first half recently used while the second half is replaced by new keys.
+	for i := range capacity / 2 {
+		for j := range capacity / 2 {
+			c.Get(fmt.Sprintf("key:%d", j))
+		}
+		c.Set(fmt.Sprintf("new:%d", i), i)
+	}
+
+	kept := 0
+	for j := range capacity / 2 {
+		if _, ok := c.Get(fmt.Sprintf("key:%d", j)); ok {
+			kept++
+		}
+	}
+	if kept < capacity/4 {
+		t.Fatalf("kept %d of %d recently used keys, want most of them", kept, capacity/2)
+	}
+}
+
+func TestGDSFPolicyEvictsLowCostFirst(t *testing.T) {
+	c := NewBoundedCache(4, NewGDSFPolicy()).(EvictionCache)
+	// Cheap and expensive entries are interleaved, so recency alone would evict an expensive one.
+	c.SetWithCost("cheap:1", "v", 0, 1)
+	c.SetWithCost("costly:1", "v", 0, 100)
+	c.SetWithCost("cheap:2", "v", 0, 1)
+	c.SetWithCost("costly:2", "v", 0, 100)
+
+	c.SetWithCost("costly:3", "v", 0, 100)
+	c.SetWithCost("costly:4", "v", 0, 100)
+	for _, key := range []string{"cheap:1", "cheap:2"} {
+		if _, ok := c.Get(key); ok {
+			t.Fatalf("low-cost key %s was not evicted", key)
+		}
+	}
+	for _, key := range []string{"costly:1", "costly:2", "costly:3", "costly:4"} {
+		if _, ok := c.Get(key); !ok {
+			t.Fatalf("high-cost key %s was evicted", key)
+		}
+	}
+}
+
+func TestGDSFPolicyEvictsLargeValuesFirst(t *testing.T) {
+	c := NewBoundedCache(2, NewGDSFPolicy()).(EvictionCache)
+	// At the same cost, the larger value has the lower cost per byte.
+	c.SetWithCost("large", strings.Repeat("v", 1000), 0, 10)
+	c.SetWithCost("small", "v", 0, 10)
+
+	c.SetWithCost("new", "v", 0, 10)
+	if _, ok := c.Get("large"); ok {
+		t.Fatal("large value was not evicted")
+	}
+	for _, key := range []string{"small", "new"} {
+		if _, ok := c.Get(key); !ok {
+			t.Fatalf("key %s was evicted", key)
+		}
+	}
+}
+
+// BenchmarkPolicyGet compares the cost of a Get on a full cache between exact LRU, which moves the key in a list
+// under a mutex, and random sampling, which records an access tick under a shared lock.
+func BenchmarkPolicyGet(b *testing.B) {
+	policies := []struct {
+		name   string
+		policy func() EvictionPolicy
+	}{
+		{"LRU", NewLRUPolicy},
+		{"RandomSample", func() EvictionPolicy { return NewRandomSamplePolicy(5) }},
+	}
+
+	const capacity = 10_000
+	keys := benchKeys(capacity)
+	for _, p := range policies {
+		c := NewBoundedCache(capacity, p.policy())
+		for _, key := range keys {
+			c.Set(key, 1)
+		}
+
+		b.Run(p.name, func(b *testing.B) {
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					c.Get(keys[i%capacity])
+					i++
+				}
+			})
+		})
+	}
+}
+
+func TestPriorityEvictsLowestFirst(t *testing.T) {
+	c := NewBoundedCache(3, NewLRUPolicy())
+	ec := c.(EvictionCache)
+	ec.SetWithPriority("low-old", 1, 0, 0)
+	ec.SetWithPriority("high", 2, 0, 10)
+	ec.SetWithPriority("low-new", 3, 0, 0)
+	// Reading high makes low-old the least recently used key of all.
+	c.Get("high")
+	c.Get("low-old")
+
+	ec.SetWithPriority("mid", 4, 0, 5)
+	if _, ok := c.Get("low-new"); ok {
+		t.Fatal("low-new survived, want the least recently used low-priority key evicted first")
+	}
+
+	ec.SetWithPriority("other", 5, 0, 5)
+	if _, ok := c.Get("low-old"); ok {
+		t.Fatal("low-old survived, want low-priority keys evicted before higher ones")
+	}
+	for _, key := range []string{"high", "mid", "other"} {
+		if _, ok := c.Get(key); !ok {
+			t.Fatalf("key %s was evicted", key)
+		}
+	}
+}
+
+func TestPriorityWithUnorderedPolicy(t *testing.T) {
+	// A policy without Candidates decides alone, regardless of priority.
+	policy := &recordingPolicy{}
+	c := NewBoundedCache(2, policy)
+	ec := c.(EvictionCache)
+	ec.SetWithPriority("high", 1, 0, 10)
+	ec.SetWithPriority("low", 2, 0, 0)
+
+	ec.SetWithPriority("new", 3, 0, 0)
+	if _, ok := c.Get("high"); ok {
+		t.Fatal("the policy's victim was not evicted")
+	}
+	if _, ok := c.Get("low"); !ok {
+		t.Fatal("low was evicted instead of the policy's victim")
+	}
+}
+
+func TestEvictBatchNeverExceedsCapacity(t *testing.T) {
+	const capacity = 100
+	for _, batch := range []int{1, 10, capacity, 10 * capacity} {
+		c := NewCacheWithConfig(CacheConfig{Capacity: capacity, EvictBatch: batch})
+		for i := range 10 * capacity {
+			c.Set(fmt.Sprintf("key:%d", i), i)
+			if size := c.(StatsReporter).Stats().Size; size > capacity {
+				t.Fatalf("batch %d: Size = %d, want at most %d", batch, size, capacity)
+			}
+		}
+		// The newest key always survives the batch it triggered.
+		if _, ok := c.Get(fmt.Sprintf("key:%d", 10*capacity-1)); !ok {
+			t.Fatalf("batch %d: newest key was evicted", batch)
+		}
+		if err := c.(Verifier).Verify(); err != nil {
+			t.Fatalf("batch %d: Verify() error = %v", batch, err)
+		}
+	}
+}
+
+func TestEvictBatchEvictsTogether(t *testing.T) {
+	c := NewCacheWithConfig(CacheConfig{Capacity: 10, EvictBatch: 4})
+	for i := range 11 {
+		c.Set(fmt.Sprintf("key:%d", i), i)
+	}
+	if size := c.(StatsReporter).Stats().Size; size != 7 {
+		t.Fatalf("Size = %d after overflowing by one, want 7 with a batch of 4", size)
+	}
+}
+
+func BenchmarkEvictBatch(b *testing.B) {
+	const capacity = 10_000
+	keys := benchKeys(100_000)
+	for _, batch := range []int{1, 100} {
+		b.Run(fmt.Sprintf("batch=%d", batch), func(b *testing.B) {
+			c := NewCacheWithConfig(CacheConfig{Capacity: capacity, EvictBatch: batch})
+			for _, key := range keys[:capacity] {
+				c.Set(key, 1)
+			}
+
+			i := 0
+			for b.Loop() {
+				c.Set(keys[i%len(keys)], 1)
+				i++
+			}
+		})
+	}
+}
+
+// newPinnedFullCache returns a full bounded cache whose items are all pinned, with priorities 5, 1, and 3.
+func newPinnedFullCache(behavior FullBehavior) Cache {
+	c := NewCacheWithConfig(CacheConfig{Capacity: 3, Policy: NewLRUPolicy(), FullBehavior: behavior})
+	ec := c.(EvictionCache)
+	for key, priority := range map[string]int{"p5": 5, "p1": 1, "p3": 3} {
+		ec.SetWithPriority(key, priority, 0, priority)
+		ec.Pin(key)
+	}
+
+	return c
+}
+
+func TestFullRejectWithPinnedEntries(t *testing.T) {
+	c := newPinnedFullCache(FullReject)
+	checked := c.(CheckedCache)
+
+	if err := checked.SetChecked("new", 1, 0); !errors.Is(err, ErrCacheFull) {
+		t.Fatalf("SetChecked(new) error = %v, want ErrCacheFull", err)
+	}
+	c.Set("other", 1)
+	for _, key := range []string{"new", "other"} {
+		if _, ok := c.Get(key); ok {
+			t.Fatalf("key %s was stored in a full cache of pinned items", key)
+		}
+	}
+	for _, key := range []string{"p5", "p1", "p3"} {
+		if _, ok := c.Get(key); !ok {
+			t.Fatalf("pinned key %s was evicted", key)
+		}
+	}
+
+	// Overwriting a stored key needs no room.
+	if err := checked.SetChecked("p1", "updated", 0); err != nil {
+		t.Fatalf("SetChecked(p1) error = %v, want an overwrite accepted", err)
+	}
+}
+
+func TestFullEvictLowestPriorityWithPinnedEntries(t *testing.T) {
+	c := newPinnedFullCache(FullEvictLowestPriority)
+
+	if err := c.(CheckedCache).SetChecked("new", 1, 0); err != nil {
+		t.Fatalf("SetChecked(new) error = %v, want the lowest priority item evicted", err)
+	}
+	if _, ok := c.Get("p1"); ok {
+		t.Fatal("lowest priority pinned key p1 was not evicted")
+	}
+	for _, key := range []string{"p5", "p3", "new"} {
+		if _, ok := c.Get(key); !ok {
+			t.Fatalf("key %s was evicted", key)
+		}
+	}
+	if err := c.(Verifier).Verify(); err != nil {
+		t.Fatalf("Verify() error = %v after a forced eviction", err)
+	}
+}
+
+func TestPinnedKeySurvivesEviction(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCacheWithConfig(CacheConfig{Capacity: 3, Policy: NewLRUPolicy(), Clock: clock})
+	ec := c.(EvictionCache)
+	c.Set("flags", "config")
+	if !ec.Pin("flags") {
+		t.Fatal("Pin(flags) = false for a stored key")
+	}
+
+	// flags is never read again, so it would be the first LRU victim.
+	for i := range 10 {
+		c.Set(fmt.Sprintf("key:%d", i), i)
+	}
+	if _, ok := c.Get("flags"); !ok {
+		t.Fatal("pinned key flags was evicted")
+	}
+	for i := range 8 {
+		if _, ok := c.Get(fmt.Sprintf("key:%d", i)); ok {
+			t.Fatalf("key:%d survived, want unpinned keys evicted", i)
+		}
+	}
+
+	if !ec.Unpin("flags") {
+		t.Fatal("Unpin(flags) = false for a stored key")
+	}
+	c.Get("key:8")
+	c.Get("key:9")
+	c.Set("next", 1)
+	if _, ok := c.Get("flags"); ok {
+		t.Fatal("unpinned key flags was not evicted")
+	}
+
+	if ec.Pin("missing") || ec.Unpin("missing") {
+		t.Fatal("Pin or Unpin of a missing key = true")
+	}
+}
+
+func TestPinnedKeyStillExpires(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCacheWithConfig(CacheConfig{Capacity: 3, Policy: NewLRUPolicy(), Clock: clock})
+	ec := c.(EvictionCache)
+	c.SetWithTTL("b", 1, time.Second)
+	ec.Pin("b")
+	clock.Advance(time.Minute)
+	if _, ok := c.Get("b"); ok {
+		t.Fatal("pinned key b did not expire")
+	}
+	if ec.Pin("b") {
+		t.Fatal("Pin(b) = true for an expired key")
+	}
+	if err := c.(Verifier).Verify(); err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+}
+
+// evictionTrace runs a fixed sequence of operations on a randomly evicting cache created with seed
+// and returns the sorted keys stored after every tenth operation.
+func evictionTrace(seed uint64) [][]string {
+	clock := newFakeClock()
+	c := NewCacheWithConfig(CacheConfig{
+		Capacity: 16,
+		Policy:   NewRandomSamplePolicy(3),
+		Clock:    clock,
+		Seed:     seed,
+	})
+
+	var trace [][]string
+	for i, key := range benchKeys(200) {
+		c.SetWithTTL(key, i, time.Duration(i%7+1)*time.Second)
+		c.Get(fmt.Sprintf("key:%d", i/2))
+		clock.Advance(300 * time.Millisecond)
+		if i%10 == 9 {
+			var keys []string
+			for _, entry := range c.(InspectableCache).Entries() {
+				keys = append(keys, entry.Key)
+			}
+			slices.Sort(keys)
+			trace = append(trace, keys)
+		}
+	}
+
+	return trace
+}
+
+func TestSeedMakesEvictionReproducible(t *testing.T) {
+	first, second := evictionTrace(42), evictionTrace(42)
+	if !slices.EqualFunc(first, second, slices.Equal) {
+		t.Fatalf("two runs with the same seed differ:\n%q\n%q", first, second)
+	}
+	if other := evictionTrace(7); slices.EqualFunc(first, other, slices.Equal) {
+		t.Fatal("runs with different seeds evicted the same keys, want the seed to drive the random choices")
+	}
+}
+
+// hotKeyHits reads a hot key every fourth operation of a scan of keys read once, storing every miss like
+// a read-through cache, and returns how many of the hot key's reads were hits.
+func hotKeyHits(c Cache) int {
+	hits := 0
+	for i := range 4000 {
+		key := fmt.Sprintf("scan:%d", i)
+		if i%4 == 0 {
+			key = "hot"
+		}
+		if _, ok := c.Get(key); ok {
+			if key == "hot" {
+				hits++
+			}
+			continue
+		}
+		c.Set(key, i)
+	}
+
+	return hits
+}
+
+func TestTinyLFUAdmissionRetainsHotKey(t *testing.T) {
+	const capacity = 2
+	lru := hotKeyHits(NewCacheWithConfig(CacheConfig{Capacity: capacity, Policy: NewLRUPolicy()}))
+	tinyLFU := hotKeyHits(NewCacheWithConfig(CacheConfig{Capacity: capacity, Policy: NewLRUPolicy(), TinyLFUAdmission: true}))
+
+	// Three scan keys are stored between two reads of the hot key, so plain LRU always evicts it.
+	if lru != 0 {
+		t.Fatalf("LRU hot key hits = %d, want 0", lru)
+	}
+	if want := 1000 * 9 / 10; tinyLFU < want {
+		t.Fatalf("TinyLFU hot key hits = %d of 1000, want at least %d", tinyLFU, want)
+	}
+}
@@ -24,12 +24,13 @@ func main() {
 	// Create a stop channel to signal the cache worker to stop.
 	stopCh := make(chan struct{})
 
-	// Start the cache cleanup worker in a separate goroutine.
-	go cache.StartCacheWorker(ctx, cache.CacheWorkerConfig{
+	// Start the cache cleanup worker in a separate goroutine and wait until it is running.
+	worker := cache.GoCacheWorker(ctx, cache.CacheWorkerConfig{
 		Cache:    c,
 		Interval: 2 * time.Second, // Cleanup every 2 seconds.
 		StopCh:   stopCh,
 	})
+	<-worker.Started()
 
 	// Set up a channel to listen for OS interrupt signals (e.g., Ctrl+C).
 	sigCh := make(chan os.Signal, 1)
@@ -55,8 +56,8 @@ func main() {
 			log.Printf("Received signal: %v. Shutting down.", sig)
 			close(stopCh) // Signal the cache worker to stop.
 			cancel()      // Cancel the context.
-			// Allow some time for the worker to finish cleanup.
-			time.Sleep(1 * time.Second)
+			// Wait for the worker to exit.
+			<-worker.Stopped()
 			return
 		}
 	}
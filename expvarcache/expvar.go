@@ -0,0 +1,29 @@
+// Package expvarcache publishes cache statistics through the standard library's expvar package.
+// It is kept separate so that the core cache package does not import expvar.
+package expvarcache
+
+import (
+	"expvar"
+
+	cache "github.com/nordew/go-stash"
+)
+
+// PublishExpvar registers an expvar variable with the given name that reports the cache's
+// hits, misses, and size as a JSON object. The values are read from Stats each time the variable is read.
+// A cache that does not implement cache.StatsReporter is reported as an empty object.
+// Like expvar.Publish, it panics if a variable with the same name is already registered.
+func PublishExpvar(name string, c cache.Cache) {
+	reporter, ok := c.(cache.StatsReporter)
+	expvar.Publish(name, expvar.Func(func() any {
+		if !ok {
+			return map[string]any{}
+		}
+		stats := reporter.Stats()
+
+		return map[string]any{
+			"hits":   stats.Hits,
+			"misses": stats.Misses,
+			"size":   stats.Size,
+		}
+	}))
+}
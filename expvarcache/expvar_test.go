@@ -0,0 +1,60 @@
+package expvarcache
+
+import (
+	"encoding/json"
+	"expvar"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	cache "github.com/nordew/go-stash"
+)
+
+// published counts the variables the tests publish, so that every run uses new names in the process-wide
+// expvar registry, which cannot unregister them.
+var published atomic.Int64
+
+// publish publishes c under a name unique to this run of the test and returns the name.
+func publish(t *testing.T, c cache.Cache) string {
+	t.Helper()
+
+	name := t.Name() + "/" + strconv.FormatInt(published.Add(1), 10)
+	PublishExpvar(name, c)
+
+	return name
+}
+
+func TestPublishExpvar(t *testing.T) {
+	c := cache.NewCache()
+	name := publish(t, c)
+
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Get("a")
+	c.Get("a")
+	c.Get("missing")
+
+	var got struct {
+		Hits   uint64 `json:"hits"`
+		Misses uint64 `json:"misses"`
+		Size   int    `json:"size"`
+	}
+	if err := json.Unmarshal([]byte(expvar.Get(name).String()), &got); err != nil {
+		t.Fatalf("decode expvar: %v", err)
+	}
+	if got.Hits != 2 || got.Misses != 1 || got.Size != 2 {
+		t.Fatalf("expvar = %+v, want 2 hits, 1 miss, and size 2", got)
+	}
+}
+
+// plainCache hides the optional interfaces of the cache it wraps, including cache.StatsReporter.
+type plainCache struct {
+	cache.Cache
+}
+
+func TestPublishExpvarWithoutStats(t *testing.T) {
+	name := publish(t, plainCache{cache.NewCache()})
+	if got := expvar.Get(name).String(); got != "{}" {
+		t.Fatalf("expvar = %s, want an empty object for a cache without stats", got)
+	}
+}
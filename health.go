@@ -0,0 +1,65 @@
+package cache
+
+import (
+	"time"
+)
+
+// HealthStatus summarizes the state of a cache for liveness and readiness checks.
+type HealthStatus struct {
+	Size           int           // Number of items stored, including expired items not yet removed.
+	OldestEntryAge time.Duration // Time since the oldest live item was set, or 0 if there are no live items.
+	WorkerRunning  bool          // Whether at least one cache worker is running for the cache.
+	LastCleanup    time.Time     // When a cache worker last finished a cleanup cycle, or the zero time.
+}
+
+// workerReporter is implemented by caches that record the activity of their cache workers for Health.
+type workerReporter interface {
+	// workerStarted is called when a cache worker for the cache starts.
+	workerStarted()
+	// workerStopped is called when a cache worker for the cache stops.
+	workerStopped()
+	// cleanupDone is called when a cache worker for the cache finishes a cleanup cycle at the given time.
+	cleanupDone(at time.Time)
+}
+
+// Health returns the size of the cache, the age of its oldest live item, and the state reported by its workers.
+// Finding the oldest item visits every item under the read lock.
+func (c *inMemoryCache) Health() HealthStatus {
+	status := HealthStatus{
+		WorkerRunning: c.workers.Load() > 0,
+	}
+	if last := c.lastCleanup.Load(); last != 0 {
+		status.LastCleanup = time.Unix(0, last)
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	status.Size = len(c.items)
+	var oldest time.Time
+	for _, item := range c.items {
+		if !c.isExpired(item) && (oldest.IsZero() || item.created.Before(oldest)) {
+			oldest = item.created
+		}
+	}
+	if !oldest.IsZero() {
+		status.OldestEntryAge = c.now().Sub(oldest)
+	}
+
+	return status
+}
+
+// workerStarted counts a running cache worker.
+func (c *inMemoryCache) workerStarted() {
+	c.workers.Add(1)
+}
+
+// workerStopped stops counting a cache worker.
+func (c *inMemoryCache) workerStopped() {
+	c.workers.Add(-1)
+}
+
+// cleanupDone records the time of the last cleanup cycle.
+func (c *inMemoryCache) cleanupDone(at time.Time) {
+	c.lastCleanup.Store(at.UnixNano())
+}
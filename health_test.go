@@ -0,0 +1,74 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestHealthWithoutWorker(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCacheWithConfig(CacheConfig{Clock: clock})
+	if status := c.(HealthReporter).Health(); status != (HealthStatus{}) {
+		t.Fatalf("Health() of an empty cache = %+v, want the zero status", status)
+	}
+
+	c.Set("old", 1)
+	clock.Advance(time.Minute)
+	c.Set("new", 2)
+	c.SetWithTTL("expired", 3, time.Second)
+	clock.Advance(time.Hour)
+
+	status := c.(HealthReporter).Health()
+	if status.Size != 3 {
+		t.Fatalf("Size = %d, want 3 including the expired item", status.Size)
+	}
+	if want := time.Hour + time.Minute; status.OldestEntryAge != want {
+		t.Fatalf("OldestEntryAge = %v, want %v", status.OldestEntryAge, want)
+	}
+	if status.WorkerRunning || !status.LastCleanup.IsZero() {
+		t.Fatalf("Health() = %+v, want no worker reported", status)
+	}
+}
+
+func TestHealthReflectsWorker(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCacheWithConfig(CacheConfig{Clock: clock})
+	c.Set("kept", 1)
+	c.SetWithTTL("expired", 2, time.Second)
+	clock.Advance(time.Minute)
+
+	cleaned := make(chan struct{}, 1)
+	before := time.Now()
+	ctx, cancel := context.WithCancel(context.Background())
+	w := GoCacheWorker(ctx, CacheWorkerConfig{
+		Cache:    c,
+		Interval: time.Millisecond,
+		Logger:   discardLogger(),
+		OnCleanup: func(int, time.Duration) {
+			select {
+			case cleaned <- struct{}{}:
+			default:
+			}
+		},
+	})
+	<-w.Started()
+	<-cleaned
+
+	status := c.(HealthReporter).Health()
+	if status.Size != 1 || status.OldestEntryAge != time.Minute {
+		t.Fatalf("Health() = %+v, want one item a minute old after the cleanup", status)
+	}
+	if !status.WorkerRunning {
+		t.Fatal("WorkerRunning = false with a worker running")
+	}
+	if status.LastCleanup.Before(before) || status.LastCleanup.After(time.Now()) {
+		t.Fatalf("LastCleanup = %v, want the time of the cleanup", status.LastCleanup)
+	}
+
+	cancel()
+	<-w.Stopped()
+	if status := c.(HealthReporter).Health(); status.WorkerRunning {
+		t.Fatal("WorkerRunning = true after the worker stopped")
+	}
+}
@@ -0,0 +1,118 @@
+// Package httpcache stores HTTP response bodies in a cache precompressed with gzip, so they can be served
+// to clients that accept gzip without compressing them on every request.
+// It is kept separate so that the core cache package does not import net/http.
+package httpcache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	cache "github.com/nordew/go-stash"
+)
+
+// response is the value stored for a cached response. gzipped reports whether body is gzip-compressed.
+type response struct {
+	body    []byte
+	gzipped bool
+}
+
+// HTTPCache stores response bodies in a Cache, compressed with gzip once when they are set.
+type HTTPCache struct {
+	cache cache.Cache
+}
+
+// NewHTTPCache returns an HTTPCache that stores responses in c.
+func NewHTTPCache(c cache.Cache) *HTTPCache {
+	return &HTTPCache{cache: c}
+}
+
+// SetResponse compresses body with gzip and stores it under key with the given TTL.
+// If compressing does not make body smaller, as for tiny or already compressed bodies, body is stored as is.
+// If ttl <= 0, the response does not expire.
+func (h *HTTPCache) SetResponse(key string, body []byte, ttl time.Duration) error {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(body); err != nil {
+		return fmt.Errorf("httpcache: compress response %q: %w", key, err)
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("httpcache: compress response %q: %w", key, err)
+	}
+
+	resp := response{body: buf.Bytes(), gzipped: true}
+	if buf.Len() >= len(body) {
+		resp = response{body: bytes.Clone(body)}
+	}
+	h.cache.SetWithTTL(key, resp, ttl)
+
+	return nil
+}
+
+// GetResponse returns the body stored under key and whether it is gzip-compressed.
+// Returns ok=false if the key is missing, expired, or does not hold a response stored by SetResponse.
+// The returned body is shared with the cache and must not be modified.
+func (h *HTTPCache) GetResponse(key string) (body []byte, gzipped bool, ok bool) {
+	value, ok := h.cache.Get(key)
+	if !ok {
+		return nil, false, false
+	}
+
+	resp, ok := value.(response)
+	if !ok {
+		return nil, false, false
+	}
+
+	return resp.body, resp.gzipped, true
+}
+
+// ServeResponse writes the response stored under key to w and reports whether there was one.
+// A gzipped body is sent as is, with Content-Encoding: gzip, to clients whose Accept-Encoding includes gzip,
+// and decompressed for the others. Callers set the other headers, such as Content-Type, before calling it.
+func (h *HTTPCache) ServeResponse(w http.ResponseWriter, r *http.Request, key string) (bool, error) {
+	body, gzipped, ok := h.GetResponse(key)
+	if !ok {
+		return false, nil
+	}
+
+	w.Header().Add("Vary", "Accept-Encoding")
+
+	var src io.Reader = bytes.NewReader(body)
+	if gzipped && acceptsGzip(r) {
+		w.Header().Set("Content-Encoding", "gzip")
+	} else if gzipped {
+		zr, err := gzip.NewReader(src)
+		if err != nil {
+			return true, fmt.Errorf("httpcache: decompress response %q: %w", key, err)
+		}
+		src = zr
+	}
+
+	if _, err := io.Copy(w, src); err != nil {
+		return true, fmt.Errorf("httpcache: write response %q: %w", key, err)
+	}
+
+	return true, nil
+}
+
+// acceptsGzip reports whether the Accept-Encoding header of r lists gzip with a non-zero quality.
+func acceptsGzip(r *http.Request) bool {
+	for _, header := range r.Header.Values("Accept-Encoding") {
+		for _, coding := range strings.Split(header, ",") {
+			name, params, _ := strings.Cut(strings.TrimSpace(coding), ";")
+			if !strings.EqualFold(strings.TrimSpace(name), "gzip") {
+				continue
+			}
+			if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok && strings.Trim(q, "0.") == "" {
+				return false
+			}
+			return true
+		}
+	}
+
+	return false
+}
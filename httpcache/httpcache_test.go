@@ -0,0 +1,127 @@
+package httpcache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	cache "github.com/nordew/go-stash"
+)
+
+// gunzip decompresses body, failing the test if it is not valid gzip.
+func gunzip(t *testing.T, body []byte) []byte {
+	t.Helper()
+
+	zr, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("gzip.NewReader() error = %v", err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("decompress: %v", err)
+	}
+
+	return data
+}
+
+func TestSetAndGetResponse(t *testing.T) {
+	h := NewHTTPCache(cache.NewCache())
+	page := []byte(strings.Repeat("<p>hello</p>", 100))
+	if err := h.SetResponse("page", page, time.Minute); err != nil {
+		t.Fatalf("SetResponse() error = %v", err)
+	}
+
+	body, gzipped, ok := h.GetResponse("page")
+	if !ok || !gzipped {
+		t.Fatalf("GetResponse(page) gzipped, ok = %v, %v, want true, true", gzipped, ok)
+	}
+	if len(body) >= len(page) {
+		t.Fatalf("stored body is %d bytes, want it smaller than the %d byte page", len(body), len(page))
+	}
+	if got := gunzip(t, body); !bytes.Equal(got, page) {
+		t.Fatal("decompressed body differs from the page")
+	}
+
+	if _, _, ok := h.GetResponse("missing"); ok {
+		t.Fatal("GetResponse(missing) ok = true")
+	}
+}
+
+func TestSetResponseStoresIncompressibleBodyAsIs(t *testing.T) {
+	h := NewHTTPCache(cache.NewCache())
+	tiny := []byte("ok")
+	if err := h.SetResponse("tiny", tiny, 0); err != nil {
+		t.Fatalf("SetResponse() error = %v", err)
+	}
+	tiny[0] = 'x'
+
+	body, gzipped, ok := h.GetResponse("tiny")
+	if !ok || gzipped || string(body) != "ok" {
+		t.Fatalf("GetResponse(tiny) = %q, %v, %v, want the body uncompressed and copied", body, gzipped, ok)
+	}
+}
+
+func TestGetResponseIgnoresOtherValues(t *testing.T) {
+	c := cache.NewCache()
+	c.Set("raw", []byte("not a response"))
+
+	if _, _, ok := NewHTTPCache(c).GetResponse("raw"); ok {
+		t.Fatal("GetResponse() ok = true for a value not stored by SetResponse")
+	}
+}
+
+func TestServeResponse(t *testing.T) {
+	h := NewHTTPCache(cache.NewCache())
+	page := strings.Repeat("<p>hello</p>", 100)
+	if err := h.SetResponse("page", []byte(page), 0); err != nil {
+		t.Fatalf("SetResponse() error = %v", err)
+	}
+
+	tests := []struct {
+		acceptEncoding string
+		gzipped        bool
+	}{
+		{"gzip, deflate", true},
+		{"br;q=1.0, GZIP;q=0.5", true},
+		{"gzip;q=0", false},
+		{"deflate", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tt.acceptEncoding != "" {
+			r.Header.Set("Accept-Encoding", tt.acceptEncoding)
+		}
+		w := httptest.NewRecorder()
+
+		ok, err := h.ServeResponse(w, r, "page")
+		if !ok || err != nil {
+			t.Fatalf("Accept-Encoding %q: ServeResponse() = %v, %v, want true, nil", tt.acceptEncoding, ok, err)
+		}
+		if got := w.Header().Get("Vary"); got != "Accept-Encoding" {
+			t.Fatalf("Accept-Encoding %q: Vary = %q, want Accept-Encoding", tt.acceptEncoding, got)
+		}
+
+		body := w.Body.Bytes()
+		if tt.gzipped {
+			if w.Header().Get("Content-Encoding") != "gzip" {
+				t.Fatalf("Accept-Encoding %q: Content-Encoding = %q, want gzip", tt.acceptEncoding, w.Header().Get("Content-Encoding"))
+			}
+			body = gunzip(t, body)
+		} else if w.Header().Get("Content-Encoding") != "" {
+			t.Fatalf("Accept-Encoding %q: Content-Encoding = %q, want none", tt.acceptEncoding, w.Header().Get("Content-Encoding"))
+		}
+		if string(body) != page {
+			t.Fatalf("Accept-Encoding %q: served body differs from the page", tt.acceptEncoding)
+		}
+	}
+
+	if ok, err := h.ServeResponse(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil), "missing"); ok || err != nil {
+		t.Fatalf("ServeResponse(missing) = %v, %v, want false, nil", ok, err)
+	}
+}
@@ -0,0 +1,38 @@
+package cache
+
+import (
+	"hash/fnv"
+	"time"
+)
+
+// hashedKeyPrefix starts every hashed key, which tells hashed keys apart from plain ones in reports.
+const hashedKeyPrefix = "\x00"
+
+// hashedKeyLen is the length of a hashed key: the prefix and a 128-bit hash.
+const hashedKeyLen = len(hashedKeyPrefix) + 16
+
+// hashKey returns the fixed-size key a cache configured with HashKeys stores for key.
+func hashKey(key string) string {
+	h := fnv.New128a()
+	h.Write([]byte(key))
+
+	return string(h.Sum([]byte(hashedKeyPrefix)))
+}
+
+// GetStored retrieves the value for a key as stored, without applying KeyFunc or hashing it.
+func (c *inMemoryCache) GetStored(storedKey string) (any, bool) {
+	return c.get(storedKey)
+}
+
+// SetStored assigns a value to a key as stored with a TTL, without applying KeyFunc or hashing it.
+func (c *inMemoryCache) SetStored(storedKey string, value any, ttl time.Duration) {
+	c.setNormalized(storedKey, c.newItem(value, ttl))
+}
+
+// DeleteStored removes the item stored under a key as stored, without applying KeyFunc or hashing it.
+func (c *inMemoryCache) DeleteStored(storedKey string) {
+	c.mu.Lock()
+	defer c.unlock()
+
+	c.removeLocked(storedKey, EventDelete)
+}
@@ -0,0 +1,130 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHashKeysLookups(t *testing.T) {
+	c := NewCacheWithConfig(CacheConfig{HashKeys: true})
+	long := "https://example.com/" + strings.Repeat("path/", 100)
+
+	c.Set(long, 1)
+	c.Set(long+"?page=2", 2)
+	if value, ok := c.Get(long); !ok || value != 1 {
+		t.Fatalf("Get(long) = %v, %v, want 1, true", value, ok)
+	}
+	if value, ok := c.Get(long + "?page=2"); !ok || value != 2 {
+		t.Fatalf("Get(long?page=2) = %v, %v, want 2, true", value, ok)
+	}
+
+	for _, entry := range c.(InspectableCache).Entries() {
+		if len(entry.Key) != hashedKeyLen {
+			t.Fatalf("stored key has length %d, want %d", len(entry.Key), hashedKeyLen)
+		}
+		if value, ok := c.(StoredKeyCache).GetStored(entry.Key); !ok || value != entry.Value {
+			t.Fatalf("GetStored of a reported key = %v, %v, want %v, true", value, ok, entry.Value)
+		}
+	}
+
+	c.Delete(long)
+	if _, ok := c.Get(long); ok {
+		t.Fatal("Get() after Delete ok = true")
+	}
+}
+
+func TestHashKeysCraftedHashDoesNotAlias(t *testing.T) {
+	c := NewCacheWithConfig(CacheConfig{HashKeys: true})
+	c.Set("victim", "secret")
+
+	// A caller who knows the hash function can send the stored form of another key.
+	crafted := hashKey("victim")
+	if value, ok := c.Get(crafted); ok {
+		t.Fatalf("Get(hash of victim) = %v, true, want the crafted key hashed like any other", value)
+	}
+	c.Set(crafted, "overwritten")
+	c.Delete(crafted)
+	if value, ok := c.Get("victim"); !ok || value != "secret" {
+		t.Fatalf("Get(victim) = %v, %v, want secret, true after writes to a crafted key", value, ok)
+	}
+}
+
+func TestStoredKeys(t *testing.T) {
+	c := NewCacheWithConfig(CacheConfig{Clock: newFakeClock(), KeyFunc: strings.ToLower, HashKeys: true})
+	sc := c.(StoredKeyCache)
+	c.Set("Key", 1)
+	stored := c.(InspectableCache).Entries()[0].Key
+
+	if value, ok := sc.GetStored(stored); !ok || value != 1 {
+		t.Fatalf("GetStored() = %v, %v, want 1, true", value, ok)
+	}
+	sc.SetStored(stored, 2, time.Minute)
+	if value, ok := c.Get("KEY"); !ok || value != 2 {
+		t.Fatalf("Get(KEY) = %v, %v after SetStored, want 2, true", value, ok)
+	}
+	if ttl, _ := c.(TTLCache).GetTTL("key"); ttl != time.Minute {
+		t.Fatalf("GetTTL(key) = %v after SetStored, want 1m", ttl)
+	}
+	sc.DeleteStored(stored)
+	if _, ok := c.Get("key"); ok {
+		t.Fatal("Get(key) ok = true after DeleteStored")
+	}
+}
+
+func TestHashKeysWithKeyFuncGetOrCompute(t *testing.T) {
+	c := NewCacheWithConfig(CacheConfig{KeyFunc: strings.ToLower, HashKeys: true})
+
+	for i := range 100 {
+		key := fmt.Sprintf("Key-%d", i)
+		value, err := c.(ComputeCache).GetOrComputeContext(context.Background(), key, func(ctx context.Context) (any, time.Duration, error) {
+			return i, 0, nil
+		})
+		if err != nil || value != i {
+			t.Fatalf("GetOrComputeContext(%s) = %v, %v, want %d, nil", key, value, err, i)
+		}
+		if value, ok := c.Get(strings.ToUpper(key)); !ok || value != i {
+			t.Fatalf("Get(%s) = %v, %v, want %d, true", strings.ToUpper(key), value, ok, i)
+		}
+	}
+}
+
+func TestHashKeysWithKeyFuncSnapshotRoundTrip(t *testing.T) {
+	cfg := CacheConfig{KeyFunc: strings.ToLower, HashKeys: true}
+	src := NewCacheWithConfig(cfg)
+	const keys = 200
+	for i := range keys {
+		src.Set(fmt.Sprintf("Key-%d", i), i)
+	}
+
+	var buf bytes.Buffer
+	if err := src.(SnapshotCache).WriteSnapshot(&buf); err != nil {
+		t.Fatalf("WriteSnapshot() error = %v", err)
+	}
+	dst := NewCacheWithConfig(cfg)
+	if err := dst.(SnapshotCache).ReadSnapshot(&buf); err != nil {
+		t.Fatalf("ReadSnapshot() error = %v", err)
+	}
+
+	for i := range keys {
+		key := fmt.Sprintf("KEY-%d", i)
+		if value, ok := dst.Get(key); !ok || value != i {
+			t.Fatalf("Get(%s) after restore = %v, %v, want %d, true", key, value, ok, i)
+		}
+	}
+}
+
+func TestHashKeysDeleteMultiReturnsInputKeys(t *testing.T) {
+	c := NewCacheWithConfig(CacheConfig{KeyFunc: strings.ToLower, HashKeys: true})
+	c.Set("a", 1)
+	c.Set("b", 2)
+
+	deleted := c.(BulkCache).DeleteMulti([]string{"A", "missing", "b"})
+	if !slices.Equal(deleted, []string{"A", "b"}) {
+		t.Fatalf("DeleteMulti() = %q, want [A b]", deleted)
+	}
+}
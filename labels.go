@@ -0,0 +1,56 @@
+package cache
+
+import (
+	"maps"
+	"time"
+)
+
+// SetWithLabels assigns a value to the specified key with a TTL and attaches a copy of labels to it.
+// Labels are descriptive only; overwriting the key with another setter drops them.
+func (c *inMemoryCache) SetWithLabels(key string, value any, ttl time.Duration, labels map[string]string) {
+	item := c.newItem(value, ttl)
+	if len(labels) > 0 {
+		item.labels = maps.Clone(labels)
+	}
+	c.set(key, item)
+}
+
+// LabelsOf returns a copy of the labels attached to the live item stored under key.
+// It returns an empty map for an item stored without labels, and (nil, false) if the key does not exist or is expired.
+func (c *inMemoryCache) LabelsOf(key string) (map[string]string, bool) {
+	key = c.normalizeKey(key)
+
+	c.mu.RLock()
+	item, ok := c.items[key]
+	c.mu.RUnlock()
+
+	if !ok || c.isExpired(item) {
+		return nil, false
+	}
+
+	labels := maps.Clone(item.labels)
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+
+	return labels, true
+}
+
+// CountByLabel returns the number of live items whose label name is set to value.
+// It visits every item, so its cost grows with the size of the cache.
+func (c *inMemoryCache) CountByLabel(name, value string) int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	count := 0
+	for _, item := range c.items {
+		if c.isExpired(item) {
+			continue
+		}
+		if v, ok := item.labels[name]; ok && v == value {
+			count++
+		}
+	}
+
+	return count
+}
@@ -0,0 +1,70 @@
+package cache
+
+import (
+	"maps"
+	"testing"
+	"time"
+)
+
+func TestLabels(t *testing.T) {
+	c := NewCache()
+	lc := c.(LabelCache)
+
+	labels := map[string]string{"source": "db", "tenant": "acme"}
+	lc.SetWithLabels("a", 1, 0, labels)
+	labels["source"] = "changed"
+
+	got, ok := lc.LabelsOf("a")
+	if want := map[string]string{"source": "db", "tenant": "acme"}; !ok || !maps.Equal(got, want) {
+		t.Fatalf("LabelsOf(a) = %v, %v, want %v, true", got, ok, want)
+	}
+	got["source"] = "changed"
+	if again, _ := lc.LabelsOf("a"); again["source"] != "db" {
+		t.Fatal("mutating the map returned by LabelsOf changed the stored labels")
+	}
+
+	c.Set("plain", 2)
+	if got, ok := lc.LabelsOf("plain"); !ok || got == nil || len(got) != 0 {
+		t.Fatalf("LabelsOf(plain) = %v, %v, want an empty map, true", got, ok)
+	}
+	if got, ok := lc.LabelsOf("missing"); ok || got != nil {
+		t.Fatalf("LabelsOf(missing) = %v, %v, want nil, false", got, ok)
+	}
+
+	// Overwriting the key with another setter drops its labels.
+	c.Set("a", 3)
+	if got, _ := lc.LabelsOf("a"); len(got) != 0 {
+		t.Fatalf("LabelsOf(a) = %v after Set, want no labels", got)
+	}
+}
+
+func TestCountByLabel(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCacheWithConfig(CacheConfig{Clock: clock})
+	lc := c.(LabelCache)
+
+	lc.SetWithLabels("a", 1, 0, map[string]string{"source": "db", "tenant": "acme"})
+	lc.SetWithLabels("b", 2, 0, map[string]string{"source": "db", "tenant": "other"})
+	lc.SetWithLabels("c", 3, 0, map[string]string{"source": "api", "tenant": "acme"})
+	lc.SetWithLabels("expired", 4, time.Second, map[string]string{"source": "db"})
+	c.Set("plain", 5)
+	clock.Advance(time.Minute)
+
+	for _, tc := range []struct {
+		name, value string
+		want        int
+	}{
+		{"source", "db", 2},
+		{"source", "api", 1},
+		{"tenant", "acme", 2},
+		{"tenant", "none", 0},
+		{"missing", "", 0},
+	} {
+		if got := lc.CountByLabel(tc.name, tc.value); got != tc.want {
+			t.Fatalf("CountByLabel(%s, %q) = %d, want %d", tc.name, tc.value, got, tc.want)
+		}
+	}
+	if _, ok := lc.LabelsOf("expired"); ok {
+		t.Fatal("LabelsOf(expired) ok = true for an expired item")
+	}
+}
@@ -0,0 +1,140 @@
+package cache
+
+import (
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// lazyValue is the value of an item stored with SetLazy. It computes the real value on the first read.
+type lazyValue struct {
+	mu    sync.Mutex
+	fn    func() (any, error)
+	value any
+	done  atomic.Bool
+	// logger receives a panic recovered from fn unless propagatePanics is set.
+	logger          *log.Logger
+	propagatePanics bool
+}
+
+// get returns the computed value, computing it first if no read has done so successfully.
+// Concurrent callers wait for the computation in progress. An error is returned without being kept,
+// so the next caller computes again.
+func (l *lazyValue) get() (any, error) {
+	if l.done.Load() {
+		return l.value, nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.done.Load() {
+		return l.value, nil
+	}
+	value, err := l.call()
+	if err != nil {
+		return nil, err
+	}
+	l.value, l.fn = value, nil
+	l.done.Store(true)
+
+	return value, nil
+}
+
+// call runs fn. Unless panics propagate, a panic in fn is recovered, logged, and returned as an error wrapping ErrPanic,
+// so the read that called it is a miss and the next read calls fn again.
+func (l *lazyValue) call() (value any, err error) {
+	defer recoverCallback(l.logger, l.propagatePanics, "lazy value function", &err)
+
+	return l.fn()
+}
+
+// peek returns the computed value without computing it. Returns (nil, false) if it has not been computed yet.
+func (l *lazyValue) peek() (any, bool) {
+	if !l.done.Load() {
+		return nil, false
+	}
+
+	return l.value, true
+}
+
+// settledValue returns value, or the computed value if it was stored with SetLazy and has been computed,
+// and nil if it has not.
+func settledValue(value any) any {
+	if lazy, ok := value.(*lazyValue); ok {
+		value, _ = lazy.peek()
+	}
+
+	return value
+}
+
+// pendingValue reports whether value was stored with SetLazy and has not been computed yet.
+func pendingValue(value any) bool {
+	lazy, ok := value.(*lazyValue)
+	if !ok {
+		return false
+	}
+	_, computed := lazy.peek()
+
+	return !computed
+}
+
+// resolveItem returns the value of the item stored under key, computing it first if it was stored with SetLazy.
+// The item was measured before its value existed, so the read that computes it measures it again, and drops it
+// if it is larger than MaxValueSize; that read still returns the value.
+func (c *inMemoryCache) resolveItem(key string, item cachedItem) (any, error) {
+	lazy, ok := item.value.(*lazyValue)
+	if !ok {
+		return item.value, nil
+	}
+	if value, computed := lazy.peek(); computed {
+		return value, nil
+	}
+
+	value, err := lazy.get()
+	if err != nil {
+		return nil, err
+	}
+	c.resizeLazy(key, lazy, value)
+
+	return value, nil
+}
+
+// resizeLazy measures value, computed by lazy, and updates the size of the item stored under key
+// if it still holds lazy.
+func (c *inMemoryCache) resizeLazy(key string, lazy *lazyValue, value any) {
+	size := c.sizer(value)
+
+	c.mu.Lock()
+	defer c.unlock()
+
+	item, ok := c.items[key]
+	if !ok || item.value != any(lazy) || item.size == size {
+		return
+	}
+	if c.maxSize > 0 && size > c.maxSize {
+		c.removeLocked(key, EventDelete)
+		return
+	}
+
+	c.unindexLocked(key, item)
+	item.size = size
+	c.items[key] = item
+	c.indexLocked(key, item)
+	if c.policy != nil {
+		c.costLocked(key, item)
+	}
+}
+
+// SetLazy stores an item under key with a TTL whose value is computed by fn on the first Get, outside the cache's lock.
+// Concurrent first reads wait for a single call of fn and share its result. If fn returns an error, the read that
+// called it is a miss and the error is not kept, so the next read calls fn again. Until a read has computed the value,
+// methods that return values without computing them, such as GetAndRefresh, Entries, and Snapshot, see nil, TryGet
+// returns acquired=false, and WriteSnapshot leaves the item out. The item is measured by the Sizer, and checked against
+// MaxValueSize, once more when its value is computed. A panic in fn is handled like one in a GetOrComputeContext function.
+func (c *inMemoryCache) SetLazy(key string, fn func() (any, error), ttl time.Duration) {
+	item := c.newItem(nil, ttl)
+	item.value = &lazyValue{fn: fn, logger: c.logger, propagatePanics: c.propagatePanics}
+	c.set(key, item)
+}
@@ -0,0 +1,151 @@
+package cache
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSetLazyComputesOnceUnderConcurrentReads(t *testing.T) {
+	c := NewCache()
+	var calls atomic.Int64
+	c.(ComputeCache).SetLazy("report", func() (any, error) {
+		calls.Add(1)
+		time.Sleep(20 * time.Millisecond)
+		return "done", nil
+	}, 0)
+	if calls.Load() != 0 {
+		t.Fatal("SetLazy called fn before the first read")
+	}
+
+	runConcurrently(20, func(int) {
+		if value, ok := c.Get("report"); !ok || value != "done" {
+			t.Errorf("Get(report) = %v, %v, want done, true", value, ok)
+		}
+	})
+	if value, ok := c.Get("report"); !ok || value != "done" {
+		t.Fatalf("Get(report) = %v, %v, want the computed value kept", value, ok)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("fn calls = %d, want 1", n)
+	}
+}
+
+func TestSetLazyDoesNotCacheErrors(t *testing.T) {
+	c := NewCache()
+	calls := 0
+	c.(ComputeCache).SetLazy("a", func() (any, error) {
+		calls++
+		if calls == 1 {
+			return nil, errors.New("unavailable")
+		}
+		return calls, nil
+	}, 0)
+
+	if value, ok := c.Get("a"); ok {
+		t.Fatalf("Get(a) = %v, true when fn failed, want a miss", value)
+	}
+	if value, ok := c.Get("a"); !ok || value != 2 {
+		t.Fatalf("Get(a) = %v, %v after a failed computation, want 2, true from a retry", value, ok)
+	}
+	if value, _ := c.Get("a"); value != 2 || calls != 2 {
+		t.Fatalf("Get(a) = %v with %d calls, want the result kept after success", value, calls)
+	}
+}
+
+func TestSetLazyBeforeFirstRead(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCacheWithConfig(CacheConfig{Clock: clock})
+	c.(ComputeCache).SetLazy("a", func() (any, error) { return 1, nil }, time.Minute)
+
+	if snapshot := c.(InspectableCache).Snapshot(); len(snapshot) != 1 || snapshot["a"] != nil {
+		t.Fatalf("Snapshot() = %v before the first read, want a nil value", snapshot)
+	}
+	if _, _, acquired := c.(LookupCache).TryGet("a"); acquired {
+		t.Fatal("TryGet(a) acquired = true for a value not computed yet")
+	}
+	if ttl, ok := c.(TTLCache).GetTTL("a"); !ok || ttl != time.Minute {
+		t.Fatalf("GetTTL(a) = %v, %v, want 1m, true", ttl, ok)
+	}
+	if value, ok := c.Get("a"); !ok || value != 1 {
+		t.Fatalf("Get(a) = %v, %v, want 1, true", value, ok)
+	}
+	if value, ok, acquired := c.(LookupCache).TryGet("a"); !acquired || !ok || value != 1 {
+		t.Fatalf("TryGet(a) = %v, %v, %v after the first read, want 1, true, true", value, ok, acquired)
+	}
+
+	clock.Advance(2 * time.Minute)
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("Get(a) ok = true after the TTL")
+	}
+}
+
+func TestSetLazyWriteSnapshotLeavesOutPendingValues(t *testing.T) {
+	c := NewCache()
+	c.(ComputeCache).SetLazy("lazy", func() (any, error) { return "computed", nil }, 0)
+	c.Set("plain", "value")
+
+	var buf bytes.Buffer
+	if err := c.(SnapshotCache).WriteSnapshot(&buf); err != nil {
+		t.Fatalf("WriteSnapshot() = %v", err)
+	}
+	restored := NewCache()
+	if err := restored.(SnapshotCache).ReadSnapshot(&buf); err != nil {
+		t.Fatalf("ReadSnapshot() = %v", err)
+	}
+	if value, ok := restored.Get("lazy"); ok {
+		t.Fatalf("Get(lazy) = %v, true after restoring a value not computed yet, want a miss", value)
+	}
+	if value, ok := restored.Get("plain"); !ok || value != "value" {
+		t.Fatalf("Get(plain) = %v, %v, want value, true", value, ok)
+	}
+
+	c.Get("lazy")
+	buf.Reset()
+	if err := c.(SnapshotCache).WriteSnapshot(&buf); err != nil {
+		t.Fatalf("WriteSnapshot() = %v", err)
+	}
+	restored = NewCache()
+	if err := restored.(SnapshotCache).ReadSnapshot(&buf); err != nil {
+		t.Fatalf("ReadSnapshot() = %v", err)
+	}
+	if value, ok := restored.Get("lazy"); !ok || value != "computed" {
+		t.Fatalf("Get(lazy) = %v, %v after it was computed, want computed, true", value, ok)
+	}
+}
+
+func TestSetLazyMeasuresComputedValue(t *testing.T) {
+	c := NewCache()
+	report := strings.Repeat("x", 100)
+	c.(ComputeCache).SetLazy("report", func() (any, error) { return report, nil }, 0)
+	if n := c.(StatsReporter).Stats().Bytes; n != 1 {
+		t.Fatalf("Stats().Bytes = %d before the first read, want 1", n)
+	}
+
+	c.Get("report")
+	if n := c.(StatsReporter).Stats().Bytes; n != 100 {
+		t.Fatalf("Stats().Bytes = %d after the first read, want 100", n)
+	}
+	if err := c.(Verifier).Verify(); err != nil {
+		t.Fatalf("Verify() = %v", err)
+	}
+}
+
+func TestSetLazyDropsComputedValueOverMaxValueSize(t *testing.T) {
+	c := NewCacheWithConfig(CacheConfig{MaxValueSize: 10})
+	report := strings.Repeat("x", 100)
+	c.(ComputeCache).SetLazy("report", func() (any, error) { return report, nil }, 0)
+
+	if value, ok := c.Get("report"); !ok || value != report {
+		t.Fatalf("Get(report) = %v, %v, want the computed value returned to the read that computed it", value, ok)
+	}
+	if c.(LookupCache).Has("report") {
+		t.Fatal("Has(report) = true for a computed value over MaxValueSize")
+	}
+	if n := c.(StatsReporter).Stats().Bytes; n != 0 {
+		t.Fatalf("Stats().Bytes = %d, want 0", n)
+	}
+}
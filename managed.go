@@ -0,0 +1,61 @@
+package cache
+
+import (
+	"context"
+	"sync"
+)
+
+// ManagedCache is a Cache that owns a cache worker. Start and Stop are idempotent and safe for concurrent use,
+// so a managed cache can be started and stopped from several places without running two workers
+// or closing a stop channel twice.
+type ManagedCache struct {
+	Cache
+
+	cfg       CacheWorkerConfig
+	startOnce sync.Once
+	stopOnce  sync.Once
+	stopCh    chan struct{}
+	// done is closed when the worker has exited, or by Stop if the worker was never started.
+	done chan struct{}
+}
+
+// NewManagedCache creates a managed cache whose worker cleans cfg.Cache with the given configuration.
+// If cfg.Cache is nil, a new in-memory cache is used. cfg.StopCh is ignored; use Stop instead.
+func NewManagedCache(cfg CacheWorkerConfig) *ManagedCache {
+	if cfg.Cache == nil {
+		cfg.Cache = NewCache()
+	}
+
+	m := &ManagedCache{
+		Cache:  cfg.Cache,
+		stopCh: make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	cfg.StopCh = m.stopCh
+	m.cfg = cfg
+
+	return m
+}
+
+// Start starts the cache worker in a new goroutine. Calls after the first, and calls after Stop, do nothing.
+func (m *ManagedCache) Start() {
+	m.startOnce.Do(func() {
+		go func() {
+			defer close(m.done)
+			StartCacheWorker(context.Background(), m.cfg)
+		}()
+	})
+}
+
+// Stop signals the cache worker to stop and waits for it to exit. It is safe to call more than once,
+// and if the worker was never started, it prevents Start from starting it.
+func (m *ManagedCache) Stop() {
+	m.stopOnce.Do(func() {
+		close(m.stopCh)
+	})
+	m.startOnce.Do(func() {
+		close(m.done)
+	})
+
+	<-m.done
+}
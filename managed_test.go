@@ -0,0 +1,76 @@
+package cache
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestManagedCacheStartStopIdempotent(t *testing.T) {
+	c := NewCache()
+	m := NewManagedCache(CacheWorkerConfig{Cache: c, Interval: time.Millisecond, Logger: discardLogger()})
+	workers := &c.(*inMemoryCache).workers
+
+	m.Start()
+	m.Start()
+	eventually(t, func() bool { return workers.Load() == 1 }, "worker did not start")
+	time.Sleep(10 * time.Millisecond)
+	if n := workers.Load(); n != 1 {
+		t.Fatalf("%d workers running after two Starts, want 1", n)
+	}
+
+	m.Stop()
+	m.Stop()
+	if n := workers.Load(); n != 0 {
+		t.Fatalf("%d workers running after Stop, want 0", n)
+	}
+
+	m.Start()
+	time.Sleep(10 * time.Millisecond)
+	if n := workers.Load(); n != 0 {
+		t.Fatalf("%d workers running after Start following Stop, want 0", n)
+	}
+}
+
+func TestManagedCacheConcurrentStartStop(t *testing.T) {
+	c := NewCache()
+	m := NewManagedCache(CacheWorkerConfig{Cache: c, Interval: time.Millisecond, Logger: discardLogger()})
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			m.Start()
+		}()
+		go func() {
+			defer wg.Done()
+			m.Stop()
+		}()
+	}
+	wg.Wait()
+
+	if n := c.(*inMemoryCache).workers.Load(); n != 0 {
+		t.Fatalf("%d workers running after Stop, want 0", n)
+	}
+}
+
+func TestManagedCacheStopWithoutStart(t *testing.T) {
+	m := NewManagedCache(CacheWorkerConfig{Logger: discardLogger()})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		m.Stop()
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Stop blocked on a managed cache that was never started")
+	}
+
+	m.Set("a", 1)
+	if _, ok := m.Get("a"); !ok {
+		t.Fatal("Get(a) ok = false on the default cache")
+	}
+}
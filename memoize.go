@@ -0,0 +1,70 @@
+package cache
+
+import (
+	"log"
+	"time"
+)
+
+// MemoizeConfig holds the configuration for memoizing a function with MemoizeWithConfig.
+type MemoizeConfig struct {
+	// TTL is how long results are cached. If TTL <= 0, results do not expire.
+	TTL time.Duration
+	// Logger receives the panics recovered from the memoized function. If nil, the standard logger is used.
+	Logger *log.Logger
+	// PropagatePanics lets panics in the memoized function propagate to the caller that made the call
+	// instead of being recovered, logged, and returned as an error wrapping ErrPanic.
+	PropagatePanics bool
+}
+
+// Memoize returns a version of fn that caches its results in c under the keys computed by keyFn, with the given TTL.
+// If ttl <= 0, results do not expire. A call whose key is cached returns the cached result without calling fn;
+// concurrent calls for the same uncached key share a single fn call. Errors are returned to the callers that
+// shared the call but are not cached, so the next call retries. A cached value that is not a V is recomputed.
+// A panic in fn is recovered, logged with the standard logger, and returned as an error wrapping ErrPanic.
+// Keys should not collide with other data stored in c, for example by giving them a prefix of their own.
+func Memoize[K comparable, V any](c Cache, keyFn func(K) string, fn func(K) (V, error), ttl time.Duration) func(K) (V, error) {
+	return MemoizeWithConfig(c, keyFn, fn, MemoizeConfig{TTL: ttl})
+}
+
+// MemoizeWithConfig is like Memoize, with the TTL and the handling of panics in fn taken from cfg.
+func MemoizeWithConfig[K comparable, V any](c Cache, keyFn func(K) string, fn func(K) (V, error), cfg MemoizeConfig) func(K) (V, error) {
+	var flight Group
+	logger := cfg.Logger
+	if logger == nil {
+		logger = log.Default()
+	}
+
+	call := func(arg K) (v V, err error) {
+		defer recoverCallback(logger, cfg.PropagatePanics, "memoized function", &err)
+
+		return fn(arg)
+	}
+
+	return func(arg K) (V, error) {
+		key := keyFn(arg)
+		if value, ok := c.Get(key); ok {
+			if v, ok := value.(V); ok {
+				return v, nil
+			}
+		}
+
+		value, err, _ := flight.Do(key, func() (any, error) {
+			// A call that finished just before this flight may have stored the value already.
+			if value, ok := c.Get(key); ok {
+				if v, ok := value.(V); ok {
+					return v, nil
+				}
+			}
+
+			v, err := call(arg)
+			if err != nil {
+				return nil, err
+			}
+			c.SetWithTTL(key, v, cfg.TTL)
+			return v, nil
+		})
+
+		v, _ := value.(V)
+		return v, err
+	}
+}
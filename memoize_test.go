@@ -0,0 +1,131 @@
+package cache
+
+import (
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMemoizeRunsOncePerKey(t *testing.T) {
+	c := NewCache()
+	calls := make(map[int]int)
+	square := Memoize(c, func(n int) string { return "square:" + strconv.Itoa(n) }, func(n int) (int, error) {
+		calls[n]++
+		return n * n, nil
+	}, 0)
+
+	for range 3 {
+		for _, n := range []int{2, 3} {
+			if got, err := square(n); err != nil || got != n*n {
+				t.Fatalf("square(%d) = %d, %v, want %d, nil", n, got, err, n*n)
+			}
+		}
+	}
+	if calls[2] != 1 || calls[3] != 1 {
+		t.Fatalf("fn calls per key = %v, want one each", calls)
+	}
+	if value, ok := c.Get("square:3"); !ok || value != 9 {
+		t.Fatalf("Get(square:3) = %v, %v, want the result cached under keyFn's key", value, ok)
+	}
+}
+
+func TestMemoizeDoesNotCacheErrors(t *testing.T) {
+	c := NewCache()
+	errUnavailable := errors.New("unavailable")
+	calls := 0
+	load := Memoize(c, func(id string) string { return "user:" + id }, func(id string) (string, error) {
+		calls++
+		if calls == 1 {
+			return "", errUnavailable
+		}
+		return "ann", nil
+	}, 0)
+
+	if _, err := load("1"); !errors.Is(err, errUnavailable) {
+		t.Fatalf("load(1) error = %v, want errUnavailable", err)
+	}
+	if c.(LookupCache).Has("user:1") {
+		t.Fatal("a failed result was cached")
+	}
+	if got, err := load("1"); err != nil || got != "ann" {
+		t.Fatalf("load(1) = %q, %v after an error, want a retry returning ann", got, err)
+	}
+	if calls != 2 {
+		t.Fatalf("fn calls = %d, want 2", calls)
+	}
+}
+
+func TestMemoizeCoalescesConcurrentCalls(t *testing.T) {
+	var calls atomic.Int64
+	release := make(chan struct{})
+	load := Memoize(NewCache(), func(id string) string { return id }, func(id string) (string, error) {
+		calls.Add(1)
+		<-release
+		return "value:" + id, nil
+	}, 0)
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+	}()
+	runConcurrently(20, func(int) {
+		if got, err := load("a"); err != nil || got != "value:a" {
+			t.Errorf("load(a) = %q, %v, want value:a, nil", got, err)
+		}
+	})
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("fn calls = %d, want one shared by concurrent callers", n)
+	}
+}
+
+func TestMemoizeTTL(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCacheWithConfig(CacheConfig{Clock: clock})
+	calls := 0
+	now := Memoize(c, func(string) string { return "now" }, func(string) (int, error) {
+		calls++
+		return calls, nil
+	}, time.Minute)
+
+	now("")
+	clock.Advance(30 * time.Second)
+	if got, _ := now(""); got != 1 {
+		t.Fatalf("now() = %d within the TTL, want the cached 1", got)
+	}
+	clock.Advance(time.Minute)
+	if got, _ := now(""); got != 2 {
+		t.Fatalf("now() = %d after the TTL, want a new call returning 2", got)
+	}
+}
+
+func TestMemoizeRecomputesOtherTypes(t *testing.T) {
+	c := NewCache()
+	c.Set("n", "not an int")
+	calls := 0
+	load := Memoize(c, func(string) string { return "n" }, func(string) (int, error) {
+		calls++
+		return 7, nil
+	}, 0)
+
+	if got, err := load(""); err != nil || got != 7 || calls != 1 {
+		t.Fatalf("load() = %d, %v with %d calls, want 7, nil from one call", got, err, calls)
+	}
+}
+
+func TestMemoizeRecoversPanics(t *testing.T) {
+	var logs syncBuffer
+	load := MemoizeWithConfig(NewCache(), func(string) string { return "k" }, func(string) (int, error) {
+		panic("boom")
+	}, MemoizeConfig{Logger: log.New(&logs, "", 0)})
+
+	if got, err := load(""); !errors.Is(err, ErrPanic) || got != 0 {
+		t.Fatalf("load() = %d, %v, want 0, ErrPanic", got, err)
+	}
+	if want := "Cache: recovered panic in memoized function: boom"; !strings.Contains(logs.String(), want) {
+		t.Fatalf("log = %q, want %q", logs.String(), want)
+	}
+}
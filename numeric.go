@@ -0,0 +1,61 @@
+package cache
+
+import (
+	"math"
+	"reflect"
+)
+
+// GetInt64 retrieves the integer stored under key as an int64, whatever its integer kind, including
+// named integer types. Returns false if the key is missing or expired, the value is not an integer,
+// or it is an unsigned integer larger than math.MaxInt64.
+func (c *inMemoryCache) GetInt64(key string) (int64, bool) {
+	value, ok := c.Get(key)
+	if !ok {
+		return 0, false
+	}
+
+	return toInt64(value)
+}
+
+// GetFloat64 retrieves the number stored under key as a float64, whatever its integer or floating-point kind.
+// Integers beyond 2^53 in magnitude lose precision. Returns false if the key is missing or expired,
+// or the value is not a number.
+func (c *inMemoryCache) GetFloat64(key string) (float64, bool) {
+	value, ok := c.Get(key)
+	if !ok {
+		return 0, false
+	}
+
+	return toFloat64(value)
+}
+
+// toInt64 converts an integer of any kind to an int64, reporting false if it does not fit.
+func toInt64(value any) (int64, bool) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() > math.MaxInt64 {
+			return 0, false
+		}
+		return int64(v.Uint()), true
+	default:
+		return 0, false
+	}
+}
+
+// toFloat64 converts a number of any kind to a float64.
+func toFloat64(value any) (float64, bool) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(v.Uint()), true
+	default:
+		return 0, false
+	}
+}
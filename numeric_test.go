@@ -0,0 +1,85 @@
+package cache
+
+import (
+	"math"
+	"testing"
+)
+
+type numericTestID int32
+
+func TestGetInt64(t *testing.T) {
+	c := NewCache()
+	lookup := c.(LookupCache)
+
+	for key, tc := range map[string]struct {
+		value any
+		want  int64
+	}{
+		"int":    {int(-7), -7},
+		"int8":   {int8(math.MinInt8), math.MinInt8},
+		"int16":  {int16(math.MaxInt16), math.MaxInt16},
+		"int32":  {int32(-1), -1},
+		"int64":  {int64(math.MaxInt64), math.MaxInt64},
+		"uint":   {uint(7), 7},
+		"uint8":  {uint8(math.MaxUint8), math.MaxUint8},
+		"uint16": {uint16(math.MaxUint16), math.MaxUint16},
+		"uint32": {uint32(math.MaxUint32), math.MaxUint32},
+		"uint64": {uint64(math.MaxInt64), math.MaxInt64},
+		"named":  {numericTestID(42), 42},
+	} {
+		c.Set(key, tc.value)
+		if got, ok := lookup.GetInt64(key); !ok || got != tc.want {
+			t.Fatalf("GetInt64(%s) = %d, %v, want %d, true", key, got, ok, tc.want)
+		}
+	}
+}
+
+func TestGetInt64RejectsOverflowAndNonIntegers(t *testing.T) {
+	c := NewCache()
+	lookup := c.(LookupCache)
+
+	for key, value := range map[string]any{
+		"overflow": uint64(math.MaxInt64) + 1,
+		"max":      uint64(math.MaxUint64),
+		"float":    1.0,
+		"string":   "1",
+		"nil":      nil,
+	} {
+		c.Set(key, value)
+		if got, ok := lookup.GetInt64(key); ok || got != 0 {
+			t.Fatalf("GetInt64(%s) = %d, %v, want 0, false", key, got, ok)
+		}
+	}
+	if got, ok := lookup.GetInt64("missing"); ok || got != 0 {
+		t.Fatalf("GetInt64(missing) = %d, %v, want 0, false", got, ok)
+	}
+}
+
+func TestGetFloat64(t *testing.T) {
+	c := NewCache()
+	lookup := c.(LookupCache)
+
+	for key, tc := range map[string]struct {
+		value any
+		want  float64
+	}{
+		"float64": {1.5, 1.5},
+		"float32": {float32(0.25), 0.25},
+		"int":     {-3, -3},
+		"uint8":   {uint8(200), 200},
+		"uint64":  {uint64(math.MaxUint64), math.MaxUint64},
+		"named":   {numericTestID(9), 9},
+	} {
+		c.Set(key, tc.value)
+		if got, ok := lookup.GetFloat64(key); !ok || got != tc.want {
+			t.Fatalf("GetFloat64(%s) = %v, %v, want %v, true", key, got, ok, tc.want)
+		}
+	}
+
+	c.Set("string", "1.5")
+	for _, key := range []string{"string", "missing"} {
+		if got, ok := lookup.GetFloat64(key); ok || got != 0 {
+			t.Fatalf("GetFloat64(%s) = %v, %v, want 0, false", key, got, ok)
+		}
+	}
+}
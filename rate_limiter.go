@@ -0,0 +1,59 @@
+package cache
+
+import (
+	"strconv"
+	"time"
+)
+
+// RateLimiter enforces per-key request limits over a sliding time window using an AtomicCache.
+// Counts are kept in one counter per fixed window and per key, created with a TTL and updated with Increment.
+// The count for the sliding window is estimated from the current window and a weighted share of the previous one.
+type RateLimiter struct {
+	cache AtomicCache
+}
+
+// NewRateLimiter creates a RateLimiter that stores its counters in the given cache.
+// Counter keys have the form "<key>@<window number>", so the cache should not be shared with other data under such keys.
+func NewRateLimiter(c AtomicCache) *RateLimiter {
+	return &RateLimiter{cache: c}
+}
+
+// Allow reports whether a request for key is allowed, given at most limit requests per window.
+// Allowed requests are counted; denied requests are not.
+func (rl *RateLimiter) Allow(key string, limit int, window time.Duration) bool {
+	if limit <= 0 || window <= 0 {
+		return false
+	}
+
+	now := time.Now().UnixNano()
+	current := now / int64(window)
+	elapsed := float64(now%int64(window)) / float64(window)
+
+	currentKey := windowKey(key, current)
+	// The counter is still needed as the previous window during the next window.
+	// Increment keeps the TTL of an existing item, so the counter is created with it first.
+	if _, _, err := rl.cache.GetOrSet(currentKey, int64(0), 2*window); err != nil {
+		return false
+	}
+	count, err := rl.cache.Increment(currentKey, 1)
+	if err != nil {
+		return false
+	}
+
+	var previous int64
+	if value, ok := rl.cache.Get(windowKey(key, current-1)); ok {
+		previous, _ = value.(int64)
+	}
+
+	if float64(previous)*(1-elapsed)+float64(count) > float64(limit) {
+		rl.cache.Increment(currentKey, -1)
+		return false
+	}
+
+	return true
+}
+
+// windowKey returns the cache key of the counter for key in the given window.
+func windowKey(key string, window int64) string {
+	return key + "@" + strconv.FormatInt(window, 10)
+}
@@ -0,0 +1,42 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	const (
+		limit  = 5
+		window = 50 * time.Millisecond
+	)
+	rl := NewRateLimiter(NewCache().(AtomicCache))
+
+	for i := range limit {
+		if !rl.Allow("client", limit, window) {
+			t.Fatalf("request %d denied within the limit", i+1)
+		}
+	}
+	if rl.Allow("client", limit, window) {
+		t.Fatal("request over the limit allowed")
+	}
+	if !rl.Allow("other", limit, window) {
+		t.Fatal("request for another key denied")
+	}
+
+	// Once the window and the one after it have passed, the old requests no longer count.
+	time.Sleep(2*window + 10*time.Millisecond)
+	if !rl.Allow("client", limit, window) {
+		t.Fatal("request denied after the window passed")
+	}
+}
+
+func TestRateLimiterRejectsInvalidLimits(t *testing.T) {
+	rl := NewRateLimiter(NewCache().(AtomicCache))
+	if rl.Allow("client", 0, time.Second) {
+		t.Fatal("Allow() with limit 0 = true")
+	}
+	if rl.Allow("client", 1, 0) {
+		t.Fatal("Allow() with window 0 = true")
+	}
+}
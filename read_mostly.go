@@ -0,0 +1,172 @@
+package cache
+
+import (
+	"maps"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// readMostlyBatch is the maximum number of queued writes a ReadMostlyCache applies with a single map copy.
+const readMostlyBatch = 64
+
+// ReadMostlyCache is a copy-on-write cache for workloads where reads vastly outnumber writes.
+// Reads load an immutable map with no lock, so they never contend with each other or with writes.
+// Writes are applied by a single background task that copies the map and atomically swaps it in,
+// so each write costs time proportional to the size of the cache.
+type ReadMostlyCache interface {
+	// Get retrieves the value for the specified key without locking.
+	// Returns (nil, false) if the key does not exist or if the item is expired.
+	Get(key string) (any, bool)
+	// Set assigns a value to the specified key without expiration and waits until readers can see it.
+	Set(key string, value any)
+	// SetWithTTL assigns a value to the specified key with a TTL and waits until readers can see it.
+	// If ttl <= 0, the item does not expire.
+	SetWithTTL(key string, value any, ttl time.Duration)
+	// Delete removes the item associated with the specified key and waits until readers no longer see it.
+	Delete(key string)
+	// Len returns the number of items in the cache, including expired items that have not been removed yet.
+	Len() int
+	// Close discards the writes still queued and makes later writes return without applying them; reads keep working.
+	Close()
+}
+
+// readMostlyItem is an item of a readMostlyCache. Items are never modified once published.
+type readMostlyItem struct {
+	value      any
+	expiration time.Time
+}
+
+// readMostlyWrite is a write queued for the writer task. A delete has no item.
+// done is closed once the write is visible to readers, or once it is discarded by Close.
+type readMostlyWrite struct {
+	key  string
+	item *readMostlyItem
+	done chan struct{}
+}
+
+// readMostlyCache implements ReadMostlyCache with an atomically swapped map and a single writer task,
+// which runs only while writes are queued.
+type readMostlyCache struct {
+	items atomic.Pointer[map[string]readMostlyItem]
+	pool  *TaskPool
+
+	mu     sync.Mutex
+	queue  []readMostlyWrite
+	active bool // Whether the writer task is running.
+	closed bool
+}
+
+// NewReadMostlyCache creates a new copy-on-write cache. Its writer task runs in a goroutine started
+// when writes are queued, which exits once they are applied.
+func NewReadMostlyCache() ReadMostlyCache {
+	return NewReadMostlyCacheWithPool(nil)
+}
+
+// NewReadMostlyCacheWithPool is like NewReadMostlyCache but runs the writer task on pool, if it is not nil.
+func NewReadMostlyCacheWithPool(pool *TaskPool) ReadMostlyCache {
+	c := &readMostlyCache{pool: pool}
+	items := make(map[string]readMostlyItem)
+	c.items.Store(&items)
+
+	return c
+}
+
+// Get retrieves the value for the specified key from the current map.
+func (c *readMostlyCache) Get(key string) (any, bool) {
+	item, ok := (*c.items.Load())[key]
+	if !ok || !item.expiration.IsZero() && time.Now().After(item.expiration) {
+		return nil, false
+	}
+
+	return item.value, true
+}
+
+// Set assigns a value to the specified key without expiration.
+func (c *readMostlyCache) Set(key string, value any) {
+	c.SetWithTTL(key, value, 0)
+}
+
+// SetWithTTL assigns a value to the specified key with a TTL.
+func (c *readMostlyCache) SetWithTTL(key string, value any, ttl time.Duration) {
+	item := &readMostlyItem{value: value}
+	if ttl > 0 {
+		item.expiration = time.Now().Add(ttl)
+	}
+	c.write(readMostlyWrite{key: key, item: item})
+}
+
+// Delete removes the item associated with the specified key.
+func (c *readMostlyCache) Delete(key string) {
+	c.write(readMostlyWrite{key: key})
+}
+
+// Len returns the number of items in the current map.
+func (c *readMostlyCache) Len() int {
+	return len(*c.items.Load())
+}
+
+// Close discards the queued writes. It is safe to call more than once.
+func (c *readMostlyCache) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closed = true
+	for _, w := range c.queue {
+		close(w.done)
+	}
+	c.queue = nil
+}
+
+// write queues w, starting the writer task if it is not running, and waits until w is applied or discarded.
+func (c *readMostlyCache) write(w readMostlyWrite) {
+	w.done = make(chan struct{})
+
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return
+	}
+	c.queue = append(c.queue, w)
+	if !c.active {
+		c.active = true
+		c.pool.goTask(c.run)
+	}
+	c.mu.Unlock()
+
+	<-w.done
+}
+
+// run applies queued writes until the queue is empty. Up to readMostlyBatch writes queued at the same time are
+// applied to a single copy of the map, which also drops the expired items, before it is swapped in.
+func (c *readMostlyCache) run() {
+	for {
+		c.mu.Lock()
+		if len(c.queue) == 0 {
+			c.active = false
+			c.mu.Unlock()
+			return
+		}
+		batch := c.queue[:min(len(c.queue), readMostlyBatch)]
+		c.queue = c.queue[len(batch):]
+		c.mu.Unlock()
+
+		now := time.Now()
+		items := maps.Clone(*c.items.Load())
+		maps.DeleteFunc(items, func(_ string, item readMostlyItem) bool {
+			return !item.expiration.IsZero() && now.After(item.expiration)
+		})
+		for _, w := range batch {
+			if w.item == nil {
+				delete(items, w.key)
+			} else {
+				items[w.key] = *w.item
+			}
+		}
+		c.items.Store(&items)
+
+		for _, w := range batch {
+			close(w.done)
+		}
+	}
+}
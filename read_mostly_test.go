@@ -0,0 +1,167 @@
+package cache
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReadMostlyCache(t *testing.T) {
+	c := NewReadMostlyCache()
+	defer c.Close()
+
+	c.Set("a", 1)
+	if value, ok := c.Get("a"); !ok || value != 1 {
+		t.Fatalf("Get(a) = %v, %v right after Set, want 1, true", value, ok)
+	}
+	c.SetWithTTL("short", 2, time.Millisecond)
+	c.SetWithTTL("long", 3, time.Hour)
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := c.Get("short"); ok {
+		t.Fatal("Get(short) ok = true after its TTL")
+	}
+	if _, ok := c.Get("long"); !ok {
+		t.Fatal("Get(long) ok = false before its TTL")
+	}
+
+	c.Delete("a")
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("Get(a) ok = true right after Delete")
+	}
+	// The write applying the delete also dropped the expired item.
+	if n := c.Len(); n != 1 {
+		t.Fatalf("Len() = %d, want only long left", n)
+	}
+}
+
+func TestReadMostlyConcurrentReadsDuringWrites(t *testing.T) {
+	c := NewReadMostlyCache()
+	defer c.Close()
+
+	const (
+		writers = 4
+		writes  = 200
+		readers = 8
+	)
+	for w := range writers {
+		c.Set(fmt.Sprintf("counter:%d", w), 0)
+	}
+
+	var stop atomic.Bool
+	var readerWG, writerWG sync.WaitGroup
+	for range readers {
+		readerWG.Add(1)
+		go func() {
+			defer readerWG.Done()
+			// Each key has a single writer that waits for every write, so a reader never sees its value go back.
+			last := make([]int, writers)
+			for !stop.Load() {
+				for w := range writers {
+					value, ok := c.Get(fmt.Sprintf("counter:%d", w))
+					if !ok {
+						t.Errorf("counter:%d missing during writes", w)
+						return
+					}
+					n := value.(int)
+					if n < last[w] {
+						t.Errorf("counter:%d went back from %d to %d", w, last[w], n)
+						return
+					}
+					last[w] = n
+				}
+			}
+		}()
+	}
+	for w := range writers {
+		writerWG.Add(1)
+		go func() {
+			defer writerWG.Done()
+			key := fmt.Sprintf("counter:%d", w)
+			for i := 1; i <= writes; i++ {
+				c.Set(key, i)
+				c.Set(fmt.Sprintf("scratch:%d:%d", w, i), i)
+				c.Delete(fmt.Sprintf("scratch:%d:%d", w, i))
+			}
+		}()
+	}
+	writerWG.Wait()
+	stop.Store(true)
+	readerWG.Wait()
+
+	for w := range writers {
+		if value, _ := c.Get(fmt.Sprintf("counter:%d", w)); value != writes {
+			t.Fatalf("counter:%d = %v, want %d", w, value, writes)
+		}
+	}
+	if n := c.Len(); n != writers {
+		t.Fatalf("Len() = %d, want %d", n, writers)
+	}
+}
+
+func TestReadMostlyClose(t *testing.T) {
+	c := NewReadMostlyCache()
+	c.Set("a", 1)
+	c.Close()
+	c.Close()
+
+	c.Set("b", 2)
+	if _, ok := c.Get("b"); ok {
+		t.Fatal("Get(b) ok = true for a write after Close")
+	}
+	if value, ok := c.Get("a"); !ok || value != 1 {
+		t.Fatalf("Get(a) = %v, %v after Close, want reads to keep working", value, ok)
+	}
+}
+
+// BenchmarkReadMostlyGet compares parallel reads of a ReadMostlyCache, which takes no lock, with the RWMutex-based
+// cache, while a writer updates a key in the background. Run it with -cpu 1,2,4,8 to see how reads scale.
+func BenchmarkReadMostlyGet(b *testing.B) {
+	const size = 1000
+	keys := benchKeys(size)
+
+	readMostly := NewReadMostlyCache()
+	defer readMostly.Close()
+	locked := NewCache()
+	caches := []struct {
+		name string
+		get  func(key string) (any, bool)
+		set  func(key string, value any)
+	}{
+		{"ReadMostly", readMostly.Get, readMostly.Set},
+		{"RWMutex", locked.Get, locked.Set},
+	}
+
+	for _, cache := range caches {
+		for i, key := range keys {
+			cache.set(key, i)
+		}
+
+		b.Run(cache.name, func(b *testing.B) {
+			stop := make(chan struct{})
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				for i := 0; ; i++ {
+					select {
+					case <-stop:
+						return
+					case <-time.After(time.Millisecond):
+						cache.set(keys[i%size], i)
+					}
+				}
+			}()
+
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					cache.get(keys[i%size])
+					i++
+				}
+			})
+			close(stop)
+			<-done
+		})
+	}
+}
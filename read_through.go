@@ -0,0 +1,249 @@
+package cache
+
+import (
+	"log"
+	"time"
+)
+
+// LoaderFunc loads the value for a key that is missing from the cache.
+// The returned TTL is used to store the value; if ttl <= 0, the value does not expire.
+type LoaderFunc func(key string) (any, time.Duration, error)
+
+// readThroughCache decorates a Cache so that misses are loaded with a LoaderFunc.
+type readThroughCache struct {
+	Cache
+	loader LoaderFunc
+	flight Group
+	// loads, if set, holds a token for every loader call in progress, limiting them to its capacity.
+	loads chan struct{}
+	// useFilter reports whether misses ruled out by the wrapped cache's MembershipFilter skip the loader.
+	useFilter bool
+	// logger receives the panics recovered from the loader unless propagatePanics is set.
+	logger          *log.Logger
+	propagatePanics bool
+}
+
+// ReadThroughConfig holds the configuration for creating a read-through cache.
+type ReadThroughConfig struct {
+	// Loader loads the values of missing keys.
+	Loader LoaderFunc
+	// MaxConcurrentLoads, if positive, caps how many loader calls run at the same time across distinct keys.
+	// Misses beyond the cap wait for a running load to finish. Misses for the same key are always coalesced.
+	MaxConcurrentLoads int
+	// Logger receives the panics recovered from Loader, which count as failed loads. If nil, the standard logger is used.
+	Logger *log.Logger
+	// PropagatePanics lets panics in Loader propagate to the caller that started the load instead of being
+	// recovered and logged. Callers sharing that load see a failed load.
+	PropagatePanics bool
+	// UseMembershipFilter, if the wrapped cache implements MembershipFilter, reports keys it rules out as misses
+	// without calling Loader. The filter only knows keys stored in the wrapped cache or added with AddToFilter,
+	// so seed it with the keys the backing store holds, and seed it again after Clear, or new keys are never loaded.
+	UseMembershipFilter bool
+}
+
+// NewReadThrough wraps the given cache so that a Get miss invokes loader and stores its result.
+// Concurrent misses for the same key are coalesced into a single loader call.
+// The other Cache methods are delegated to the wrapped cache, and so are StatsReporter, HealthReporter,
+// and the worker cleanup interfaces if the wrapped cache implements them.
+func NewReadThrough(c Cache, loader LoaderFunc) Cache {
+	return NewReadThroughWithConfig(c, ReadThroughConfig{Loader: loader})
+}
+
+// NewReadThroughWithConfig wraps the given cache like NewReadThrough, with the given configuration.
+func NewReadThroughWithConfig(c Cache, cfg ReadThroughConfig) Cache {
+	r := &readThroughCache{
+		Cache:           c,
+		loader:          cfg.Loader,
+		logger:          cfg.Logger,
+		propagatePanics: cfg.PropagatePanics,
+		useFilter:       cfg.UseMembershipFilter,
+	}
+	if r.logger == nil {
+		r.logger = log.Default()
+	}
+	if cfg.MaxConcurrentLoads > 0 {
+		r.loads = make(chan struct{}, cfg.MaxConcurrentLoads)
+	}
+
+	return r
+}
+
+// sourcedValue is a value shared by a load flight together with its source.
+type sourcedValue struct {
+	value  any
+	source Source
+}
+
+// Get retrieves the value for the specified key from the wrapped cache.
+// On a miss, the value is loaded and stored in the wrapped cache.
+// Returns (nil, false) if the loader fails; errors are not cached.
+func (r *readThroughCache) Get(key string) (any, bool) {
+	value, _, ok := r.GetWithSource(key)
+	return value, ok
+}
+
+// GetWithSource is like Get but also reports whether the value was a hit or was loaded.
+// With UseMembershipFilter, keys the wrapped cache's MembershipFilter rules out are reported as misses without loading.
+func (r *readThroughCache) GetWithSource(key string) (any, Source, bool) {
+	if value, ok := r.Cache.Get(key); ok {
+		return value, SourceHit, true
+	}
+	if filter, ok := r.Cache.(MembershipFilter); ok && r.useFilter && !filter.MayContain(key) {
+		return nil, SourceNone, false
+	}
+
+	result, err, _ := r.flight.Do(key, func() (any, error) {
+		// The key may have been loaded by a flight that finished just before this one started.
+		if value, ok := r.Cache.Get(key); ok {
+			return sourcedValue{value: value, source: SourceHit}, nil
+		}
+
+		value, ttl, err := r.load(key)
+		if err != nil {
+			return nil, err
+		}
+		r.Cache.SetWithTTL(key, value, ttl)
+
+		return sourcedValue{value: value, source: SourceLoaded}, nil
+	})
+	if err != nil {
+		return nil, SourceNone, false
+	}
+
+	sourced := result.(sourcedValue)
+	return sourced.value, sourced.source, true
+}
+
+// load calls the loader, first waiting for a free slot if concurrent loads are limited. Unless the configuration
+// propagates panics, a panic in the loader is recovered, logged, and returned as an error wrapping ErrPanic,
+// so it counts as a failed load.
+func (r *readThroughCache) load(key string) (value any, ttl time.Duration, err error) {
+	if r.loads != nil {
+		r.loads <- struct{}{}
+		defer func() { <-r.loads }()
+	}
+	defer recoverCallback(r.logger, r.propagatePanics, "loader", &err)
+
+	return r.loader(key)
+}
+
+// Stats returns the stats of the wrapped cache if it implements StatsReporter, and zero stats otherwise.
+func (r *readThroughCache) Stats() Stats {
+	if reporter, ok := r.Cache.(StatsReporter); ok {
+		return reporter.Stats()
+	}
+
+	return Stats{}
+}
+
+// Health returns the health of the wrapped cache if it implements HealthReporter, and a zero status otherwise.
+func (r *readThroughCache) Health() HealthStatus {
+	if reporter, ok := r.Cache.(HealthReporter); ok {
+		return reporter.Health()
+	}
+
+	return HealthStatus{}
+}
+
+// RemoveExpired removes expired items from the wrapped cache if it implements Cleanable.
+func (r *readThroughCache) RemoveExpired() int {
+	if cleanable, ok := r.Cache.(Cleanable); ok {
+		return cleanable.RemoveExpired()
+	}
+
+	return 0
+}
+
+// RemoveExpiredSized removes expired items from the wrapped cache and reports their size if it implements
+// SizedCleanable, and falls back to Cleanable otherwise.
+func (r *readThroughCache) RemoveExpiredSized() (int, int64) {
+	if sized, ok := r.Cache.(SizedCleanable); ok {
+		return sized.RemoveExpiredSized()
+	}
+
+	return r.RemoveExpired(), 0
+}
+
+// RemoveExpiredN removes at most n expired items from the wrapped cache if it implements BatchCleanable.
+func (r *readThroughCache) RemoveExpiredN(n int) (int, int64) {
+	if cleanable, ok := r.Cache.(BatchCleanable); ok {
+		return cleanable.RemoveExpiredN(n)
+	}
+
+	return 0, 0
+}
+
+// RemoveExpiredFunc removes expired items from the wrapped cache, passing them to fn first, if it implements HookCleanable.
+func (r *readThroughCache) RemoveExpiredFunc(fn func(key string, value any)) (int, int64) {
+	if cleanable, ok := r.Cache.(HookCleanable); ok {
+		return cleanable.RemoveExpiredFunc(fn)
+	}
+
+	return 0, 0
+}
+
+// EvictN evicts at most n items from the wrapped cache if it implements Evictable.
+func (r *readThroughCache) EvictN(n int) int {
+	if evictable, ok := r.Cache.(Evictable); ok {
+		return evictable.EvictN(n)
+	}
+
+	return 0
+}
+
+// Len returns the number of items stored in the wrapped cache if it implements Evictable.
+func (r *readThroughCache) Len() int {
+	if evictable, ok := r.Cache.(Evictable); ok {
+		return evictable.Len()
+	}
+
+	return 0
+}
+
+// RemoveExpiredKeys removes expired items from the wrapped cache if it implements KeyCleanable.
+func (r *readThroughCache) RemoveExpiredKeys() []string {
+	if cleanable, ok := r.Cache.(KeyCleanable); ok {
+		return cleanable.RemoveExpiredKeys()
+	}
+
+	return nil
+}
+
+// RemoveExpiredInTier removes the expired items of a tier from the wrapped cache if it implements TierCleanable.
+func (r *readThroughCache) RemoveExpiredInTier(tier string) int {
+	if cleanable, ok := r.Cache.(TierCleanable); ok {
+		return cleanable.RemoveExpiredInTier(tier)
+	}
+
+	return 0
+}
+
+// Verify checks the internal consistency of the wrapped cache if it implements Verifier.
+func (r *readThroughCache) Verify() error {
+	if verifier, ok := r.Cache.(Verifier); ok {
+		return verifier.Verify()
+	}
+
+	return nil
+}
+
+// workerStarted forwards the worker activity to the wrapped cache so that its Health reflects it.
+func (r *readThroughCache) workerStarted() {
+	if reporter, ok := r.Cache.(workerReporter); ok {
+		reporter.workerStarted()
+	}
+}
+
+// workerStopped forwards the worker activity to the wrapped cache.
+func (r *readThroughCache) workerStopped() {
+	if reporter, ok := r.Cache.(workerReporter); ok {
+		reporter.workerStopped()
+	}
+}
+
+// cleanupDone forwards the worker activity to the wrapped cache.
+func (r *readThroughCache) cleanupDone(at time.Time) {
+	if reporter, ok := r.Cache.(workerReporter); ok {
+		reporter.cleanupDone(at)
+	}
+}
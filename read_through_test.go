@@ -0,0 +1,289 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingLoader returns a loader that counts its calls and loads every key as "loaded:" + key.
+func countingLoader(calls *atomic.Int64) LoaderFunc {
+	return func(key string) (any, time.Duration, error) {
+		calls.Add(1)
+		return "loaded:" + key, 0, nil
+	}
+}
+
+func TestReadThroughLoadsMisses(t *testing.T) {
+	var calls atomic.Int64
+	c := NewReadThrough(NewCache(), countingLoader(&calls))
+
+	for range 3 {
+		value, ok := c.Get("a")
+		if !ok || value != "loaded:a" {
+			t.Fatalf("Get(a) = %v, %v, want loaded:a, true", value, ok)
+		}
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("loader called %d times, want 1", calls.Load())
+	}
+
+	_, source, _ := c.(SourceCache).GetWithSource("a")
+	if source != SourceHit {
+		t.Fatalf("source = %v, want SourceHit", source)
+	}
+}
+
+func TestReadThroughGetWithSource(t *testing.T) {
+	c := NewReadThrough(NewCache(), func(key string) (any, time.Duration, error) {
+		if key == "absent" {
+			return nil, 0, errors.New("not in the backing store")
+		}
+		return "loaded:" + key, 0, nil
+	}).(SourceCache)
+
+	tests := []struct {
+		key    string
+		value  any
+		source Source
+		ok     bool
+	}{
+		{key: "a", value: "loaded:a", source: SourceLoaded, ok: true},
+		{key: "a", value: "loaded:a", source: SourceHit, ok: true},
+		{key: "absent", value: nil, source: SourceNone, ok: false},
+	}
+	for _, tt := range tests {
+		value, source, ok := c.GetWithSource(tt.key)
+		if value != tt.value || source != tt.source || ok != tt.ok {
+			t.Fatalf("GetWithSource(%s) = %v, %v, %v, want %v, %v, %v", tt.key, value, source, ok, tt.value, tt.source, tt.ok)
+		}
+	}
+}
+
+func TestSourceString(t *testing.T) {
+	for source, want := range map[Source]string{SourceNone: "none", SourceHit: "hit", SourceLoaded: "loaded", Source(99): "unknown"} {
+		if got := source.String(); got != want {
+			t.Fatalf("Source(%d).String() = %q, want %q", source, got, want)
+		}
+	}
+}
+
+func TestReadThroughDelegatesHits(t *testing.T) {
+	var calls atomic.Int64
+	inner := NewCache()
+	inner.Set("a", "stored")
+	c := NewReadThrough(inner, countingLoader(&calls))
+
+	if value, ok := c.Get("a"); !ok || value != "stored" {
+		t.Fatalf("Get(a) = %v, %v, want stored, true", value, ok)
+	}
+	if calls.Load() != 0 {
+		t.Fatalf("loader called %d times for a hit, want 0", calls.Load())
+	}
+
+	c.Set("b", "set through the wrapper")
+	if value, ok := inner.Get("b"); !ok || value != "set through the wrapper" {
+		t.Fatalf("wrapped Get(b) = %v, %v, want the value set through the wrapper", value, ok)
+	}
+}
+
+func TestReadThroughCoalescesLoads(t *testing.T) {
+	var calls atomic.Int64
+	release := make(chan struct{})
+	c := NewReadThrough(NewCache(), func(key string) (any, time.Duration, error) {
+		calls.Add(1)
+		<-release
+		return "loaded:" + key, 0, nil
+	})
+
+	const callers = 20
+	var wg sync.WaitGroup
+	results := make(chan any, callers)
+	for range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, _ := c.Get("a")
+			results <- value
+		}()
+	}
+
+	waitForWaiters(t, &c.(*readThroughCache).flight, "a", callers-1)
+	close(release)
+	wg.Wait()
+	close(results)
+
+	if calls.Load() != 1 {
+		t.Fatalf("loader called %d times, want 1", calls.Load())
+	}
+	for value := range results {
+		if value != "loaded:a" {
+			t.Fatalf("Get(a) = %v, want loaded:a", value)
+		}
+	}
+}
+
+func TestReadThroughDoesNotCacheErrors(t *testing.T) {
+	var calls atomic.Int64
+	c := NewReadThrough(NewCache(), func(key string) (any, time.Duration, error) {
+		calls.Add(1)
+		return nil, 0, errors.New("backend down")
+	})
+
+	for range 2 {
+		if _, ok := c.Get("a"); ok {
+			t.Fatal("Get() ok = true for a failed load")
+		}
+	}
+	if calls.Load() != 2 {
+		t.Fatalf("loader called %d times, want 2", calls.Load())
+	}
+}
+
+func TestReadThroughIgnoresBloomFilterByDefault(t *testing.T) {
+	var calls atomic.Int64
+	inner := NewCacheWithConfig(CacheConfig{BloomFilterKeys: 100})
+	c := NewReadThrough(inner, countingLoader(&calls))
+
+	if _, ok := c.Get("new"); !ok {
+		t.Fatal("Get() of a key never stored did not load it")
+	}
+
+	c.Clear()
+	if _, ok := c.Get("new"); !ok {
+		t.Fatal("Get() after Clear did not load the key")
+	}
+	if calls.Load() != 2 {
+		t.Fatalf("loader called %d times, want 2", calls.Load())
+	}
+}
+
+func TestReadThroughBloomFilterSkipsDefiniteNegatives(t *testing.T) {
+	var calls atomic.Int64
+	inner := NewCacheWithConfig(CacheConfig{BloomFilterKeys: 1000})
+	inner.(MembershipFilter).AddToFilter("known")
+	c := NewReadThroughWithConfig(inner, ReadThroughConfig{
+		Loader:              countingLoader(&calls),
+		UseMembershipFilter: true,
+	})
+
+	if value, ok := c.Get("known"); !ok || value != "loaded:known" {
+		t.Fatalf("Get(known) = %v, %v, want loaded:known, true", value, ok)
+	}
+
+	skipped := 0
+	for i := range 1000 {
+		key := fmt.Sprintf("absent:%d", i)
+		if inner.(MembershipFilter).MayContain(key) {
+			continue
+		}
+		before := calls.Load()
+		if _, ok := c.Get(key); ok {
+			t.Fatalf("Get(%s) ok = true for a key the filter rules out", key)
+		}
+		if calls.Load() != before {
+			t.Fatalf("loader called for %s, which the filter rules out", key)
+		}
+		skipped++
+	}
+	if skipped == 0 {
+		t.Fatal("the filter ruled out no keys")
+	}
+}
+
+func TestReadThroughBloomFilterFalsePositivesLoad(t *testing.T) {
+	var calls atomic.Int64
+	// A filter sized for one key saturates quickly, so most absent keys become false positives.
+	inner := NewCacheWithConfig(CacheConfig{BloomFilterKeys: 1})
+	for i := range 100 {
+		inner.(MembershipFilter).AddToFilter(fmt.Sprintf("seed:%d", i))
+	}
+	c := NewReadThroughWithConfig(inner, ReadThroughConfig{
+		Loader:              countingLoader(&calls),
+		UseMembershipFilter: true,
+	})
+
+	for i := range 100 {
+		key := fmt.Sprintf("other:%d", i)
+		if !inner.(MembershipFilter).MayContain(key) {
+			continue
+		}
+		value, ok := c.Get(key)
+		if !ok || value != "loaded:"+key {
+			t.Fatalf("Get(%s) = %v, %v, want the loaded value for a false positive", key, value, ok)
+		}
+		return
+	}
+	t.Fatal("no false positive found")
+}
+
+func TestBloomFilterResetOnClear(t *testing.T) {
+	c := NewCacheWithConfig(CacheConfig{BloomFilterKeys: 100})
+	filter := c.(MembershipFilter)
+
+	c.Set("stored", 1)
+	filter.AddToFilter("seeded")
+	if !filter.MayContain("stored") || !filter.MayContain("seeded") {
+		t.Fatal("MayContain() = false for a recorded key")
+	}
+
+	c.Delete("stored")
+	if !filter.MayContain("stored") {
+		t.Fatal("Delete removed the key from the filter")
+	}
+
+	c.Clear()
+	if filter.MayContain("stored") || filter.MayContain("seeded") {
+		t.Fatal("MayContain() = true after Clear")
+	}
+}
+
+func TestMaxConcurrentLoads(t *testing.T) {
+	const (
+		limit = 3
+		keys  = 20
+	)
+	var running, peak, calls atomic.Int64
+	c := NewReadThroughWithConfig(NewCache(), ReadThroughConfig{
+		MaxConcurrentLoads: limit,
+		Loader: func(key string) (any, time.Duration, error) {
+			calls.Add(1)
+			n := running.Add(1)
+			defer running.Add(-1)
+			for {
+				old := peak.Load()
+				if n <= old || peak.CompareAndSwap(old, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			return "loaded:" + key, 0, nil
+		},
+	})
+
+	var wg sync.WaitGroup
+	for i := range keys {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			key := fmt.Sprint(i)
+			if value, ok := c.Get(key); !ok || value != "loaded:"+key {
+				t.Errorf("Get(%s) = %v, %v, want the loaded value", key, value, ok)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if calls.Load() != keys {
+		t.Fatalf("loader called %d times, want one load per key", calls.Load())
+	}
+	if peak.Load() > limit {
+		t.Fatalf("%d loads ran at once, want at most %d", peak.Load(), limit)
+	}
+	if peak.Load() < 2 {
+		t.Fatalf("at most %d load ran at once, want loads for distinct keys to run in parallel", peak.Load())
+	}
+}
@@ -0,0 +1,257 @@
+package cache
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Operations recorded by a Recorder.
+const (
+	opSet    = "set"
+	opGet    = "get"
+	opDelete = "delete"
+	opClear  = "clear"
+)
+
+// recordedOp is the encoded form of a single operation in a recording.
+type recordedOp struct {
+	Time  time.Time
+	Op    string
+	Key   string
+	Value any
+	TTL   time.Duration
+}
+
+// Recorder decorates a Cache and writes every Set, SetWithTTL, Get, Delete, and Clear call to an io.Writer,
+// so the sequence can be reproduced later with Replay. StatsReporter, HealthReporter, Verifier, and the worker
+// cleanup interfaces are delegated to the wrapped cache if it implements them, without being recorded,
+// so a cache worker can clean a Recorder.
+type Recorder struct {
+	Cache
+
+	mu  sync.Mutex
+	enc *gob.Encoder
+	err error
+}
+
+// NewRecorder wraps the given cache so that its operations are recorded to w as a gob stream.
+// Operations are encoded while a mutex is held, so w should be buffered for low overhead.
+// Values of custom types must be registered with gob.Register.
+func NewRecorder(c Cache, w io.Writer) *Recorder {
+	return &Recorder{
+		Cache: c,
+		enc:   gob.NewEncoder(w),
+	}
+}
+
+// Err returns the first error encountered while writing the recording.
+// Once an error occurs, no further operations are recorded.
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.err
+}
+
+// Set records the operation and assigns a value to the specified key with the wrapped cache's default TTL.
+func (r *Recorder) Set(key string, value any) {
+	r.record(recordedOp{Op: opSet, Key: key, Value: value})
+	r.Cache.Set(key, value)
+}
+
+// SetWithTTL records the operation and assigns a value to the specified key with a TTL.
+func (r *Recorder) SetWithTTL(key string, value any, ttl time.Duration) {
+	r.record(recordedOp{Op: opSet, Key: key, Value: value, TTL: ttl})
+	r.Cache.SetWithTTL(key, value, ttl)
+}
+
+// Get records the operation and retrieves the value for the specified key.
+func (r *Recorder) Get(key string) (any, bool) {
+	r.record(recordedOp{Op: opGet, Key: key})
+	return r.Cache.Get(key)
+}
+
+// Delete records the operation and removes the item associated with the specified key.
+func (r *Recorder) Delete(key string) {
+	r.record(recordedOp{Op: opDelete, Key: key})
+	r.Cache.Delete(key)
+}
+
+// Clear records the operation and removes all items from the cache.
+func (r *Recorder) Clear() {
+	r.record(recordedOp{Op: opClear})
+	r.Cache.Clear()
+}
+
+// Stats returns the stats of the wrapped cache if it implements StatsReporter, and zero stats otherwise.
+func (r *Recorder) Stats() Stats {
+	if reporter, ok := r.Cache.(StatsReporter); ok {
+		return reporter.Stats()
+	}
+
+	return Stats{}
+}
+
+// Health returns the health of the wrapped cache if it implements HealthReporter, and a zero status otherwise.
+func (r *Recorder) Health() HealthStatus {
+	if reporter, ok := r.Cache.(HealthReporter); ok {
+		return reporter.Health()
+	}
+
+	return HealthStatus{}
+}
+
+// RemoveExpired removes expired items from the wrapped cache if it implements Cleanable.
+func (r *Recorder) RemoveExpired() int {
+	if cleanable, ok := r.Cache.(Cleanable); ok {
+		return cleanable.RemoveExpired()
+	}
+
+	return 0
+}
+
+// RemoveExpiredSized removes expired items from the wrapped cache and reports their size if it implements
+// SizedCleanable, and falls back to Cleanable otherwise.
+func (r *Recorder) RemoveExpiredSized() (int, int64) {
+	if sized, ok := r.Cache.(SizedCleanable); ok {
+		return sized.RemoveExpiredSized()
+	}
+
+	return r.RemoveExpired(), 0
+}
+
+// RemoveExpiredN removes at most n expired items from the wrapped cache if it implements BatchCleanable.
+func (r *Recorder) RemoveExpiredN(n int) (int, int64) {
+	if cleanable, ok := r.Cache.(BatchCleanable); ok {
+		return cleanable.RemoveExpiredN(n)
+	}
+
+	return 0, 0
+}
+
+// RemoveExpiredFunc removes expired items from the wrapped cache, passing them to fn first, if it implements HookCleanable.
+func (r *Recorder) RemoveExpiredFunc(fn func(key string, value any)) (int, int64) {
+	if cleanable, ok := r.Cache.(HookCleanable); ok {
+		return cleanable.RemoveExpiredFunc(fn)
+	}
+
+	return 0, 0
+}
+
+// EvictN evicts at most n items from the wrapped cache if it implements Evictable.
+func (r *Recorder) EvictN(n int) int {
+	if evictable, ok := r.Cache.(Evictable); ok {
+		return evictable.EvictN(n)
+	}
+
+	return 0
+}
+
+// Len returns the number of items stored in the wrapped cache if it implements Evictable.
+func (r *Recorder) Len() int {
+	if evictable, ok := r.Cache.(Evictable); ok {
+		return evictable.Len()
+	}
+
+	return 0
+}
+
+// RemoveExpiredKeys removes expired items from the wrapped cache if it implements KeyCleanable.
+func (r *Recorder) RemoveExpiredKeys() []string {
+	if cleanable, ok := r.Cache.(KeyCleanable); ok {
+		return cleanable.RemoveExpiredKeys()
+	}
+
+	return nil
+}
+
+// RemoveExpiredInTier removes the expired items of a tier from the wrapped cache if it implements TierCleanable.
+func (r *Recorder) RemoveExpiredInTier(tier string) int {
+	if cleanable, ok := r.Cache.(TierCleanable); ok {
+		return cleanable.RemoveExpiredInTier(tier)
+	}
+
+	return 0
+}
+
+// Verify checks the internal consistency of the wrapped cache if it implements Verifier.
+func (r *Recorder) Verify() error {
+	if verifier, ok := r.Cache.(Verifier); ok {
+		return verifier.Verify()
+	}
+
+	return nil
+}
+
+// workerStarted forwards the worker activity to the wrapped cache so that its Health reflects it.
+func (r *Recorder) workerStarted() {
+	if reporter, ok := r.Cache.(workerReporter); ok {
+		reporter.workerStarted()
+	}
+}
+
+// workerStopped forwards the worker activity to the wrapped cache.
+func (r *Recorder) workerStopped() {
+	if reporter, ok := r.Cache.(workerReporter); ok {
+		reporter.workerStopped()
+	}
+}
+
+// cleanupDone forwards the worker activity to the wrapped cache.
+func (r *Recorder) cleanupDone(at time.Time) {
+	if reporter, ok := r.Cache.(workerReporter); ok {
+		reporter.cleanupDone(at)
+	}
+}
+
+// record encodes op with the current time, unless an earlier write failed.
+func (r *Recorder) record(op recordedOp) {
+	op.Time = time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.err != nil {
+		return
+	}
+	if err := r.enc.Encode(&op); err != nil {
+		r.err = fmt.Errorf("cache: record %s operation: %w", op.Op, err)
+	}
+}
+
+// Replay reads operations recorded by a Recorder from r and applies them to c in order, as fast as possible.
+// TTLs are applied relative to the time of replay, not the time of recording.
+func Replay(r io.Reader, c Cache) error {
+	dec := gob.NewDecoder(r)
+
+	for {
+		var op recordedOp
+		if err := dec.Decode(&op); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("cache: read recorded operation: %w", err)
+		}
+
+		switch op.Op {
+		case opSet:
+			if op.TTL > 0 {
+				c.SetWithTTL(op.Key, op.Value, op.TTL)
+			} else {
+				c.Set(op.Key, op.Value)
+			}
+		case opGet:
+			c.Get(op.Key)
+		case opDelete:
+			c.Delete(op.Key)
+		case opClear:
+			c.Clear()
+		default:
+			return fmt.Errorf("cache: unknown recorded operation %q", op.Op)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"maps"
+	"testing"
+	"time"
+)
+
+// entryMap returns the keys and values stored in c.
+func entryMap(c Cache) map[string]any {
+	entries := make(map[string]any)
+	for _, entry := range c.(InspectableCache).Entries() {
+		entries[entry.Key] = entry.Value
+	}
+
+	return entries
+}
+
+func TestRecorderReplay(t *testing.T) {
+	var buf bytes.Buffer
+	original := NewCache()
+	r := NewRecorder(original, &buf)
+	r.Set("a", 1)
+	r.Set("b", "two")
+	r.Get("a")
+	r.Get("missing")
+	r.Clear()
+	r.Set("c", 3.5)
+	r.SetWithTTL("d", 4, time.Hour)
+	r.Set("e", 5)
+	r.Delete("e")
+	if err := r.Err(); err != nil {
+		t.Fatalf("Err() = %v", err)
+	}
+
+	replayed := NewCache()
+	if err := Replay(&buf, replayed); err != nil {
+		t.Fatalf("Replay() error = %v", err)
+	}
+
+	if got, want := entryMap(replayed), entryMap(original); !maps.Equal(got, want) {
+		t.Fatalf("replayed entries = %v, want %v", got, want)
+	}
+	if ttl, ok := replayed.(TTLCache).GetTTL("d"); !ok || ttl <= 0 || ttl > time.Hour {
+		t.Fatalf("replayed GetTTL(d) = %v, %v, want the recorded TTL", ttl, ok)
+	}
+	got, want := replayed.(StatsReporter).Stats(), original.(StatsReporter).Stats()
+	if got.Hits != want.Hits || got.Misses != want.Misses {
+		t.Fatalf("replayed hits, misses = %d, %d, want %d, %d", got.Hits, got.Misses, want.Hits, want.Misses)
+	}
+}
+
+func TestReplayRejectsUnknownOperation(t *testing.T) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(recordedOp{Op: "rename", Key: "a"}); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+
+	if err := Replay(&buf, NewCache()); err == nil {
+		t.Fatal("Replay() of an unknown operation error = nil")
+	}
+}
+
+// failingWriter is an io.Writer that always fails.
+type failingWriter struct{}
+
+var errWrite = errors.New("write failed")
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errWrite
+}
+
+func TestRecorderErrStopsRecording(t *testing.T) {
+	c := NewCache()
+	r := NewRecorder(c, failingWriter{})
+	r.Set("a", 1)
+	r.Set("b", 2)
+
+	if err := r.Err(); !errors.Is(err, errWrite) {
+		t.Fatalf("Err() = %v, want the write error", err)
+	}
+	if value, ok := c.Get("b"); !ok || value != 2 {
+		t.Fatalf("Get(b) = %v, %v, want operations applied despite the recording error", value, ok)
+	}
+}
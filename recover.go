@@ -0,0 +1,25 @@
+package cache
+
+import (
+	"fmt"
+	"log"
+)
+
+// recoverCallback recovers a panic raised by a user-supplied callback, logs it to logger, and, if err is not nil,
+// stores an error wrapping ErrPanic in it. If propagate is set, the panic is left to continue unwinding.
+// It must be deferred directly by the function that runs the callback.
+func recoverCallback(logger *log.Logger, propagate bool, name string, err *error) {
+	if propagate {
+		return
+	}
+
+	r := recover()
+	if r == nil {
+		return
+	}
+
+	logger.Printf("Cache: recovered panic in %s: %v", name, r)
+	if err != nil {
+		*err = fmt.Errorf("%w: %s: %v", ErrPanic, name, r)
+	}
+}
@@ -0,0 +1,110 @@
+package cache
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestExpireCallbackPanicIsRecovered(t *testing.T) {
+	var logs syncBuffer
+	clock := newFakeClock()
+	c := NewCacheWithConfig(CacheConfig{Clock: clock, Logger: log.New(&logs, "", 0)})
+	c.(TTLCache).SetWithExpireCallback("a", 1, time.Second, func(any) { panic("boom") })
+	clock.Advance(time.Minute)
+
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("Get(a) ok = true for an expired key")
+	}
+	if want := "Cache: recovered panic in expiration callback: boom"; !strings.Contains(logs.String(), want) {
+		t.Fatalf("log = %q, want %q", logs.String(), want)
+	}
+
+	c.Set("b", 2)
+	if value, ok := c.Get("b"); !ok || value != 2 {
+		t.Fatalf("Get(b) = %v, %v after a recovered panic, want 2, true", value, ok)
+	}
+}
+
+func TestPropagatePanics(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCacheWithConfig(CacheConfig{Clock: clock, PropagatePanics: true})
+	c.(TTLCache).SetWithExpireCallback("a", 1, time.Second, func(any) { panic("boom") })
+	clock.Advance(time.Minute)
+
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Fatalf("recovered %v, want the callback's panic to propagate", r)
+			}
+		}()
+		c.Get("a")
+	}()
+
+	// The callback runs after the lock is released, so the cache is still usable.
+	c.Set("b", 2)
+	if _, ok := c.Get("b"); !ok {
+		t.Fatal("Get(b) ok = false after a propagated panic")
+	}
+}
+
+func TestLoaderPanicIsRecovered(t *testing.T) {
+	var logs syncBuffer
+	var panicking atomic.Bool
+	panicking.Store(true)
+	c := NewReadThroughWithConfig(NewCache(), ReadThroughConfig{
+		Logger: log.New(&logs, "", 0),
+		Loader: func(key string) (any, time.Duration, error) {
+			if panicking.Load() {
+				panic("boom")
+			}
+			return "loaded", 0, nil
+		},
+	})
+
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("Get(a) ok = true from a panicking loader")
+	}
+	if !strings.Contains(logs.String(), "Cache: recovered panic in loader: boom") {
+		t.Fatalf("log = %q, want the loader panic logged", logs.String())
+	}
+
+	panicking.Store(false)
+	if value, ok := c.Get("a"); !ok || value != "loaded" {
+		t.Fatalf("Get(a) = %v, %v after a recovered panic, want loaded, true", value, ok)
+	}
+}
+
+func TestWorkerSurvivesPanickingCallback(t *testing.T) {
+	var logs syncBuffer
+	var cycles atomic.Int64
+	clock := newFakeClock()
+	c := NewCacheWithConfig(CacheConfig{Clock: clock})
+	w := startWorker(t, CacheWorkerConfig{
+		Cache:    c,
+		Interval: time.Millisecond,
+		Logger:   log.New(&logs, "", 0),
+		OnCleanup: func(int, time.Duration) {
+			cycles.Add(1)
+			panic(errors.New("boom"))
+		},
+	})
+
+	eventually(t, func() bool { return cycles.Load() >= 3 }, "worker stopped after a panicking callback")
+	select {
+	case <-w.Stopped():
+		t.Fatal("worker exited after a panicking callback")
+	default:
+	}
+	if !strings.Contains(logs.String(), "Cache: recovered panic in cache worker cleanup: boom") {
+		t.Fatalf("log = %q, want the panic logged", logs.String())
+	}
+
+	// Cleanup still runs on the cycles after a panic.
+	c.SetWithTTL("a", 1, time.Second)
+	clock.Advance(time.Minute)
+	eventually(t, func() bool { return c.(StatsReporter).Stats().Size == 0 }, "expired item not removed after a panic")
+}
@@ -0,0 +1,73 @@
+// Package registry keeps a process-wide set of named caches, so that an application with many caches
+// can enumerate them, collect their stats, and clear them all from one place.
+// Registering a cache is optional, and all functions are safe for concurrent use.
+package registry
+
+import (
+	"fmt"
+	"maps"
+	"sync"
+
+	cache "github.com/nordew/go-stash"
+)
+
+var (
+	mu     sync.RWMutex
+	caches = make(map[string]cache.Cache)
+)
+
+// Register adds c to the registry under name.
+// Like expvar.Publish, it panics if a cache with the same name is already registered.
+func Register(name string, c cache.Cache) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if _, ok := caches[name]; ok {
+		panic(fmt.Sprintf("registry: cache %q is already registered", name))
+	}
+	caches[name] = c
+}
+
+// Unregister removes the cache registered under name, if any.
+func Unregister(name string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	delete(caches, name)
+}
+
+// Get returns the cache registered under name.
+func Get(name string) (cache.Cache, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	c, ok := caches[name]
+	return c, ok
+}
+
+// All returns a copy of the registry, mapping names to caches.
+func All() map[string]cache.Cache {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	return maps.Clone(caches)
+}
+
+// Stats returns the stats of every registered cache that implements cache.StatsReporter, keyed by name.
+func Stats() map[string]cache.Stats {
+	stats := make(map[string]cache.Stats)
+	for name, c := range All() {
+		if reporter, ok := c.(cache.StatsReporter); ok {
+			stats[name] = reporter.Stats()
+		}
+	}
+
+	return stats
+}
+
+// ClearAll clears every registered cache. The caches are cleared one at a time, outside the registry's lock.
+func ClearAll() {
+	for _, c := range All() {
+		c.Clear()
+	}
+}
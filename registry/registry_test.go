@@ -0,0 +1,101 @@
+package registry
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	cache "github.com/nordew/go-stash"
+)
+
+// register registers c under name and unregisters it when the test ends, keeping the global registry clean.
+func register(t *testing.T, name string, c cache.Cache) {
+	t.Helper()
+
+	Register(name, c)
+	t.Cleanup(func() { Unregister(name) })
+}
+
+func TestRegisterAndGet(t *testing.T) {
+	users, sessions := cache.NewCache(), cache.NewCache()
+	register(t, "users", users)
+	register(t, "sessions", sessions)
+
+	if c, ok := Get("users"); !ok || c != users {
+		t.Fatalf("Get(users) = %v, %v, want the registered cache", c, ok)
+	}
+	if _, ok := Get("missing"); ok {
+		t.Fatal("Get(missing) ok = true")
+	}
+
+	all := All()
+	if len(all) != 2 || all["users"] != users || all["sessions"] != sessions {
+		t.Fatalf("All() = %v, want both registered caches", all)
+	}
+	delete(all, "users")
+	if _, ok := Get("users"); !ok {
+		t.Fatal("modifying the map returned by All changed the registry")
+	}
+
+	Unregister("sessions")
+	if _, ok := Get("sessions"); ok {
+		t.Fatal("Get(sessions) ok = true after Unregister")
+	}
+}
+
+func TestRegisterDuplicatePanics(t *testing.T) {
+	register(t, "dup", cache.NewCache())
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Register() of a duplicate name did not panic")
+		}
+	}()
+	Register("dup", cache.NewCache())
+}
+
+func TestStatsAndClearAll(t *testing.T) {
+	a, b := cache.NewCache(), cache.NewCache()
+	register(t, "a", a)
+	register(t, "b", b)
+	a.Set("x", 1)
+	a.Set("y", 2)
+	b.Set("z", 3)
+
+	stats := Stats()
+	if stats["a"].Size != 2 || stats["b"].Size != 1 {
+		t.Fatalf("Stats() = %+v, want sizes 2 and 1", stats)
+	}
+
+	ClearAll()
+	for name, c := range map[string]cache.Cache{"a": a, "b": b} {
+		if size := c.(cache.StatsReporter).Stats().Size; size != 0 {
+			t.Fatalf("cache %s has %d items after ClearAll, want 0", name, size)
+		}
+	}
+}
+
+func TestConcurrentRegistration(t *testing.T) {
+	const caches = 50
+	var wg sync.WaitGroup
+	for i := range caches {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			name := fmt.Sprintf("concurrent:%d", i)
+			Register(name, cache.NewCache())
+			Get(name)
+			All()
+		}()
+	}
+	wg.Wait()
+	t.Cleanup(func() {
+		for i := range caches {
+			Unregister(fmt.Sprintf("concurrent:%d", i))
+		}
+	})
+
+	if n := len(All()); n != caches {
+		t.Fatalf("All() has %d caches, want %d", n, caches)
+	}
+}
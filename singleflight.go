@@ -0,0 +1,69 @@
+package cache
+
+import (
+	"context"
+	"sync"
+)
+
+// flightCall represents an in-flight or completed call for a key.
+type flightCall struct {
+	done  chan struct{}
+	value any
+	err   error
+	dups  int
+}
+
+// Group coalesces concurrent calls for the same key into a single execution.
+// It is the single-flight mechanism used by the loading caches and can be used on its own
+// to deduplicate work whose results should not be cached. The zero value is ready to use.
+type Group struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+// Do executes fn for the specified key, making sure only one execution per key is in flight at a time.
+// Callers that arrive while a call is in flight wait for it and receive the same result.
+// shared reports whether the result was given to more than one caller.
+func (g *Group) Do(key string, fn func() (any, error)) (value any, err error, shared bool) {
+	return g.doContext(context.Background(), key, fn)
+}
+
+// doContext is like Do, but a caller waiting for another caller's flight stops waiting
+// and returns ctx.Err() when ctx is done. The flight itself keeps running.
+func (g *Group) doContext(ctx context.Context, key string, fn func() (any, error)) (any, error, bool) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall)
+	}
+	if call, ok := g.calls[key]; ok {
+		call.dups++
+		g.mu.Unlock()
+		select {
+		case <-call.done:
+			return call.value, call.err, true
+		case <-ctx.Done():
+			return nil, ctx.Err(), false
+		}
+	}
+
+	call := &flightCall{done: make(chan struct{})}
+	g.calls[key] = call
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(call.done)
+	}()
+
+	// If fn panics, the callers waiting for this flight receive ErrPanic while the panic continues here.
+	call.err = ErrPanic
+	call.value, call.err = fn()
+
+	g.mu.Lock()
+	shared := call.dups > 0
+	g.mu.Unlock()
+
+	return call.value, call.err, shared
+}
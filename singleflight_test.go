@@ -0,0 +1,102 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitForWaiters waits until n callers are waiting for the flight of key in g.
+func waitForWaiters(t *testing.T, g *Group, key string, n int) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		g.mu.Lock()
+		call, ok := g.calls[key]
+		joined := ok && call.dups >= n
+		g.mu.Unlock()
+		if joined {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d callers did not join the flight of %q", n, key)
+		}
+		time.Sleep(100 * time.Microsecond)
+	}
+}
+
+func TestGroupDoCoalesces(t *testing.T) {
+	var g Group
+	var calls atomic.Int64
+	release := make(chan struct{})
+
+	const callers = 50
+	var wg sync.WaitGroup
+	values := make(chan any, callers)
+	var sharedCount atomic.Int64
+	for range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, err, shared := g.Do("key", func() (any, error) {
+				calls.Add(1)
+				<-release
+				return "result", nil
+			})
+			if err != nil {
+				t.Errorf("Do() error = %v", err)
+			}
+			if shared {
+				sharedCount.Add(1)
+			}
+			values <- value
+		}()
+	}
+
+	waitForWaiters(t, &g, "key", callers-1)
+	close(release)
+	wg.Wait()
+	close(values)
+
+	if calls.Load() != 1 {
+		t.Fatalf("fn called %d times, want 1", calls.Load())
+	}
+	for value := range values {
+		if value != "result" {
+			t.Fatalf("Do() = %v, want result", value)
+		}
+	}
+	if sharedCount.Load() != callers {
+		t.Fatalf("%d callers reported a shared result, want all %d", sharedCount.Load(), callers)
+	}
+}
+
+func TestGroupDoRunsAgainAfterFlight(t *testing.T) {
+	var g Group
+	calls := 0
+	fn := func() (any, error) {
+		calls++
+		return nil, errors.New("failed")
+	}
+
+	for range 2 {
+		if _, err, shared := g.Do("key", fn); err == nil || shared {
+			t.Fatalf("Do() = _, %v, %v, want the error unshared", err, shared)
+		}
+	}
+	if calls != 2 {
+		t.Fatalf("fn called %d times, want 2 for sequential calls", calls)
+	}
+}
+
+func TestGroupDoDistinctKeys(t *testing.T) {
+	var g Group
+	a, _, _ := g.Do("a", func() (any, error) { return 1, nil })
+	b, _, _ := g.Do("b", func() (any, error) { return 2, nil })
+	if a != 1 || b != 2 {
+		t.Fatalf("Do() = %v, %v, want 1, 2", a, b)
+	}
+}
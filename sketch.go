@@ -0,0 +1,84 @@
+package cache
+
+import (
+	"hash/maphash"
+	"sync/atomic"
+)
+
+const (
+	// sketchDepth is the number of rows of a count-min sketch, each indexed by a different hash of the key.
+	sketchDepth = 4
+	// sketchMinWidth is the minimum number of counters in a row.
+	sketchMinWidth = 64
+	// sketchSampleFactor sets how many increments, as a multiple of the row width, a sketch records
+	// before halving its counters, so that old accesses are forgotten.
+	sketchSampleFactor = 10
+)
+
+// countMinSketch estimates how often keys were recorded recently. Estimates may be too high because of
+// hash collisions but are never too low, except for the halving that ages every counter.
+// Counters are updated atomically, so recording does not need the cache's lock.
+type countMinSketch struct {
+	counters  []atomic.Uint32
+	width     uint64
+	additions atomic.Uint64
+	sample    uint64
+	seed      maphash.Seed
+}
+
+// newCountMinSketch creates a sketch sized for a cache holding about keys items.
+func newCountMinSketch(keys int) *countMinSketch {
+	width := uint64(max(keys, sketchMinWidth))
+
+	return &countMinSketch{
+		counters: make([]atomic.Uint32, sketchDepth*width),
+		width:    width,
+		sample:   width * sketchSampleFactor,
+		seed:     maphash.MakeSeed(),
+	}
+}
+
+// increment records an access to key. Every sample accesses, all counters are halved.
+func (s *countMinSketch) increment(key string) {
+	h1, h2 := s.hash(key)
+	for i := range uint64(sketchDepth) {
+		s.counters[i*s.width+(h1+i*h2)%s.width].Add(1)
+	}
+
+	if s.additions.Add(1) == s.sample {
+		s.additions.Store(0)
+		for i := range s.counters {
+			s.counters[i].Store(s.counters[i].Load() / 2)
+		}
+	}
+}
+
+// estimate returns the estimated number of recent accesses to key.
+func (s *countMinSketch) estimate(key string) uint32 {
+	h1, h2 := s.hash(key)
+	lowest := uint32(0)
+	for i := range uint64(sketchDepth) {
+		count := s.counters[i*s.width+(h1+i*h2)%s.width].Load()
+		if i == 0 || count < lowest {
+			lowest = count
+		}
+	}
+
+	return lowest
+}
+
+// reset forgets all recorded accesses.
+func (s *countMinSketch) reset() {
+	for i := range s.counters {
+		s.counters[i].Store(0)
+	}
+	s.additions.Store(0)
+}
+
+// hash returns the two halves of the key's hash, which are combined to derive the counter positions.
+// The second half is made odd so that the positions do not repeat.
+func (s *countMinSketch) hash(key string) (uint64, uint64) {
+	h := maphash.String(s.seed, key)
+
+	return h >> 32, h&0xffffffff | 1
+}
@@ -0,0 +1,175 @@
+package cache
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"time"
+)
+
+// SnapshotVersion is the version of the format written by WriteSnapshot.
+// It is increased whenever the encoding of entries changes incompatibly.
+const SnapshotVersion = 1
+
+// snapshotHeader is encoded once at the start of a snapshot stream, before the entries.
+type snapshotHeader struct {
+	Version int
+}
+
+// snapshotEntry is the encoded form of a single item in a snapshot stream.
+type snapshotEntry struct {
+	Key       string
+	Value     any
+	ExpiresAt time.Time
+	// Remaining is the TTL the item had left when it was written. Snapshots written before it was added
+	// decode it as zero, and their entries keep their absolute expiration in every mode.
+	Remaining time.Duration
+}
+
+// SnapshotExpiry chooses how ReadSnapshotWithConfig computes the expirations of the entries it stores.
+type SnapshotExpiry int
+
+const (
+	// SnapshotExpiryAbsolute keeps the absolute expiration times written in the snapshot,
+	// which are off by the difference between the clocks of the writing and reading machines.
+	SnapshotExpiryAbsolute SnapshotExpiry = iota
+	// SnapshotExpiryRemaining expires every entry after the TTL it had left when it was written,
+	// counted from the time it is read, so that clock skew between machines does not matter.
+	// The time the snapshot spent in transit or at rest does not count.
+	SnapshotExpiryRemaining
+)
+
+// SnapshotReadConfig holds the configuration for reading a snapshot with ReadSnapshotWithConfig.
+type SnapshotReadConfig struct {
+	// Expiry chooses how expirations are computed. The zero value is SnapshotExpiryAbsolute.
+	Expiry SnapshotExpiry
+}
+
+// WriteSnapshot streams all live items, except SetLazy items whose value has not been computed, to w as a gob-encoded header carrying SnapshotVersion,
+// followed by a sequence of gob-encoded entries.
+// Gob frames every entry with its length, so entries are encoded one at a time and memory stays bounded.
+// Values of custom types must be registered with gob.Register.
+// The read lock is held while writing, so w must not call back into the cache.
+func (c *inMemoryCache) WriteSnapshot(w io.Writer) error {
+	enc := gob.NewEncoder(w)
+	if err := enc.Encode(&snapshotHeader{Version: SnapshotVersion}); err != nil {
+		return fmt.Errorf("cache: write snapshot header: %w", err)
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	now := c.now()
+	for key, item := range c.items {
+		// A SetLazy value not computed yet has nothing to write, and writing nil would restore it as a stored nil.
+		if c.isExpiredAt(item, now) || pendingValue(item.value) {
+			continue
+		}
+
+		entry := snapshotEntry{
+			Key:       key,
+			Value:     settledValue(item.value),
+			ExpiresAt: item.expiration,
+		}
+		if !item.expiration.IsZero() {
+			entry.Remaining = item.expiration.Sub(now)
+		}
+		if err := enc.Encode(&entry); err != nil {
+			return fmt.Errorf("cache: write snapshot entry %q: %w", key, err)
+		}
+	}
+
+	return nil
+}
+
+// ReadSnapshot reads entries written by WriteSnapshot from r and stores them, keeping their absolute expirations.
+// Entries that have expired since the snapshot was written are skipped. Entries are stored as they are decoded,
+// so a decoding error leaves the entries read before it in the cache.
+// A snapshot written with a different SnapshotVersion is rejected with ErrSnapshotVersion before any entry is stored.
+func (c *inMemoryCache) ReadSnapshot(r io.Reader) error {
+	return c.ReadSnapshotWithConfig(r, SnapshotReadConfig{})
+}
+
+// ReadSnapshotWithConfig reads entries written by WriteSnapshot from r and stores them like ReadSnapshot,
+// computing their expirations as configured.
+func (c *inMemoryCache) ReadSnapshotWithConfig(r io.Reader, cfg SnapshotReadConfig) error {
+	dec := gob.NewDecoder(r)
+
+	var header snapshotHeader
+	if err := dec.Decode(&header); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		return fmt.Errorf("cache: read snapshot header: %w", err)
+	}
+	if header.Version != SnapshotVersion {
+		return fmt.Errorf("%w: got %d, want %d", ErrSnapshotVersion, header.Version, SnapshotVersion)
+	}
+
+	for {
+		var entry snapshotEntry
+		if err := dec.Decode(&entry); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("cache: read snapshot entry: %w", err)
+		}
+
+		item := cachedItem{
+			value:      c.copyValue(entry.Value),
+			expiration: entry.ExpiresAt,
+			created:    c.now(),
+			epoch:      c.epoch.Load(),
+		}
+		if cfg.Expiry == SnapshotExpiryRemaining && entry.Remaining > 0 {
+			item.expiration = item.created.Add(entry.Remaining)
+		}
+		if c.isExpired(item) {
+			continue
+		}
+		// Snapshots hold stored keys, which are already normalized.
+		c.setNormalized(entry.Key, item)
+	}
+}
+
+// Snapshot returns the values of all live items in the cache, keyed by their keys, taken under the read lock.
+func (c *inMemoryCache) Snapshot() map[string]any {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	snapshot := make(map[string]any, len(c.items))
+	for key, item := range c.items {
+		if !c.isExpired(item) {
+			snapshot[key] = c.copyValue(item.value)
+		}
+	}
+
+	return snapshot
+}
+
+// Diff compares two snapshots taken with Snapshot and returns, sorted, the keys only in b (added),
+// the keys only in a (removed), and the keys in both whose values differ according to reflect.DeepEqual (changed).
+func Diff(a, b map[string]any) (added, removed, changed []string) {
+	for key, before := range a {
+		after, ok := b[key]
+		if !ok {
+			removed = append(removed, key)
+		} else if !reflect.DeepEqual(before, after) {
+			changed = append(changed, key)
+		}
+	}
+	for key := range b {
+		if _, ok := a[key]; !ok {
+			added = append(added, key)
+		}
+	}
+
+	slices.Sort(added)
+	slices.Sort(removed)
+	slices.Sort(changed)
+
+	return added, removed, changed
+}
@@ -0,0 +1,210 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	clock := newFakeClock()
+	src := NewCacheWithConfig(CacheConfig{Clock: clock})
+	src.Set("forever", "a")
+	src.SetWithTTL("hour", 42, time.Hour)
+	src.SetWithTTL("short", 1.5, time.Minute)
+	src.SetWithTTL("expired", "gone", time.Second)
+	clock.Advance(2 * time.Second)
+
+	var buf bytes.Buffer
+	if err := src.(SnapshotCache).WriteSnapshot(&buf); err != nil {
+		t.Fatalf("WriteSnapshot() error = %v", err)
+	}
+
+	// The reader's clock is past the expiration of "short", which is skipped.
+	clock.Advance(2 * time.Minute)
+	dst := NewCacheWithConfig(CacheConfig{Clock: clock})
+	if err := dst.(SnapshotCache).ReadSnapshot(&buf); err != nil {
+		t.Fatalf("ReadSnapshot() error = %v", err)
+	}
+
+	for key, want := range map[string]any{"forever": "a", "hour": 42} {
+		if value, ok := dst.Get(key); !ok || value != want {
+			t.Fatalf("Get(%s) = %v, %v, want %v, true", key, value, ok, want)
+		}
+	}
+	srcTTL, _ := src.(TTLCache).GetTTL("hour")
+	if ttl, _ := dst.(TTLCache).GetTTL("hour"); ttl != srcTTL {
+		t.Fatalf("restored TTL = %v, want the absolute expiration kept (%v)", ttl, srcTTL)
+	}
+	for _, key := range []string{"short", "expired"} {
+		if _, ok := dst.Get(key); ok {
+			t.Fatalf("expired key %s was restored", key)
+		}
+	}
+}
+
+func TestSnapshotStreamsThroughPipe(t *testing.T) {
+	src := NewCache()
+	const keys = 10_000
+	for i := range keys {
+		src.Set(fmt.Sprintf("key:%d", i), i)
+	}
+
+	// A pipe holds no data, so the writer can only finish if the reader consumes entries as they are written.
+	r, w := io.Pipe()
+	go func() {
+		w.CloseWithError(src.(SnapshotCache).WriteSnapshot(w))
+	}()
+
+	dst := NewCache()
+	if err := dst.(SnapshotCache).ReadSnapshot(r); err != nil {
+		t.Fatalf("ReadSnapshot() error = %v", err)
+	}
+	if size := dst.(StatsReporter).Stats().Size; size != keys {
+		t.Fatalf("restored %d items, want %d", size, keys)
+	}
+}
+
+func TestReadSnapshotEmptyStream(t *testing.T) {
+	if err := NewCache().(SnapshotCache).ReadSnapshot(&bytes.Buffer{}); err != nil {
+		t.Fatalf("ReadSnapshot() of an empty stream error = %v, want nil", err)
+	}
+}
+
+func TestSnapshotHeaderCarriesVersion(t *testing.T) {
+	c := NewCache()
+	c.Set("a", 1)
+
+	var buf bytes.Buffer
+	if err := c.(SnapshotCache).WriteSnapshot(&buf); err != nil {
+		t.Fatalf("WriteSnapshot() error = %v", err)
+	}
+
+	var header snapshotHeader
+	if err := gob.NewDecoder(&buf).Decode(&header); err != nil {
+		t.Fatalf("decode header: %v", err)
+	}
+	if header.Version != SnapshotVersion {
+		t.Fatalf("header version = %d, want %d", header.Version, SnapshotVersion)
+	}
+}
+
+func TestReadSnapshotRejectsOtherVersions(t *testing.T) {
+	for _, version := range []int{SnapshotVersion - 1, SnapshotVersion + 1} {
+		var buf bytes.Buffer
+		enc := gob.NewEncoder(&buf)
+		if err := enc.Encode(snapshotHeader{Version: version}); err != nil {
+			t.Fatalf("encode header: %v", err)
+		}
+		if err := enc.Encode(snapshotEntry{Key: "a", Value: 1}); err != nil {
+			t.Fatalf("encode entry: %v", err)
+		}
+
+		c := NewCache()
+		err := c.(SnapshotCache).ReadSnapshot(&buf)
+		if !errors.Is(err, ErrSnapshotVersion) {
+			t.Fatalf("version %d: ReadSnapshot() error = %v, want ErrSnapshotVersion", version, err)
+		}
+		if _, ok := c.Get("a"); ok {
+			t.Fatalf("version %d: an entry was stored from a rejected snapshot", version)
+		}
+	}
+}
+
+func TestReadSnapshotExpiryModes(t *testing.T) {
+	writer := newFakeClock()
+	src := NewCacheWithConfig(CacheConfig{Clock: writer})
+	src.SetWithTTL("hour", 1, time.Hour)
+	src.Set("forever", 2)
+	writer.Advance(10 * time.Minute)
+
+	var buf bytes.Buffer
+	if err := src.(SnapshotCache).WriteSnapshot(&buf); err != nil {
+		t.Fatalf("WriteSnapshot() error = %v", err)
+	}
+	snapshot := buf.Bytes()
+
+	// The reading machine's clock runs two hours ahead of the writer's.
+	reader := newFakeClock()
+	reader.Advance(2 * time.Hour)
+	read := func(expiry SnapshotExpiry) Cache {
+		c := NewCacheWithConfig(CacheConfig{Clock: reader})
+		if err := c.(SnapshotCache).ReadSnapshotWithConfig(bytes.NewReader(snapshot), SnapshotReadConfig{Expiry: expiry}); err != nil {
+			t.Fatalf("ReadSnapshotWithConfig(%d) error = %v", expiry, err)
+		}
+		return c
+	}
+
+	absolute := read(SnapshotExpiryAbsolute)
+	if _, ok := absolute.Get("hour"); ok {
+		t.Fatal("absolute mode restored an entry whose expiration is past on the reader's clock")
+	}
+
+	remaining := read(SnapshotExpiryRemaining)
+	if ttl, ok := remaining.(TTLCache).GetTTL("hour"); !ok || ttl != 50*time.Minute {
+		t.Fatalf("GetTTL(hour) = %v, %v, want the 50m left at writing counted from the reader's now", ttl, ok)
+	}
+	for _, c := range []Cache{absolute, remaining} {
+		if ttl, ok := c.(TTLCache).GetTTL("forever"); !ok || ttl != 0 {
+			t.Fatalf("GetTTL(forever) = %v, %v, want 0, true", ttl, ok)
+		}
+	}
+
+	reader.Advance(time.Hour)
+	if _, ok := remaining.Get("hour"); ok {
+		t.Fatal("Get(hour) ok = true after its remaining TTL passed on the reader's clock")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	before := map[string]any{
+		"same":    1,
+		"slice":   []int{1, 2},
+		"changed": "old",
+		"removed": true,
+		"nil":     nil,
+	}
+	after := map[string]any{
+		"same":    1,
+		"slice":   []int{1, 2},
+		"changed": "new",
+		"nil":     nil,
+		"added:b": 2,
+		"added:a": 3,
+	}
+
+	added, removed, changed := Diff(before, after)
+	if !slices.Equal(added, []string{"added:a", "added:b"}) {
+		t.Fatalf("added = %q, want [added:a added:b]", added)
+	}
+	if !slices.Equal(removed, []string{"removed"}) {
+		t.Fatalf("removed = %q, want [removed]", removed)
+	}
+	if !slices.Equal(changed, []string{"changed"}) {
+		t.Fatalf("changed = %q, want [changed]", changed)
+	}
+
+	if added, removed, changed := Diff(before, before); added != nil || removed != nil || changed != nil {
+		t.Fatalf("Diff() of equal snapshots = %q, %q, %q, want no differences", added, removed, changed)
+	}
+}
+
+func TestDiffOfCacheSnapshots(t *testing.T) {
+	c := NewCache()
+	c.Set("a", 1)
+	c.Set("b", 2)
+	before := c.(InspectableCache).Snapshot()
+
+	c.Set("b", 3)
+	c.Delete("a")
+	c.Set("c", 4)
+	added, removed, changed := Diff(before, c.(InspectableCache).Snapshot())
+	if !slices.Equal(added, []string{"c"}) || !slices.Equal(removed, []string{"a"}) || !slices.Equal(changed, []string{"b"}) {
+		t.Fatalf("Diff() = %q, %q, %q, want [c], [a], [b]", added, removed, changed)
+	}
+}
@@ -0,0 +1,14 @@
+package cache
+
+// SuspendExpiration makes the cache treat every item as live, as if no TTL had elapsed, until ResumeExpiration
+// is called: reads return overdue items and cache workers do not remove them. Items invalidated by InvalidateAll
+// stay absent. Suspending an already suspended cache has no effect.
+func (c *inMemoryCache) SuspendExpiration() {
+	c.expirySuspended.Store(true)
+}
+
+// ResumeExpiration ends a suspension started by SuspendExpiration. Expirations are absolute, so the items whose
+// TTL elapsed while expiration was suspended expire right away.
+func (c *inMemoryCache) ResumeExpiration() {
+	c.expirySuspended.Store(false)
+}
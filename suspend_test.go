@@ -0,0 +1,50 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSuspendExpiration(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCacheWithConfig(CacheConfig{Clock: clock})
+	tc := c.(TTLCache)
+	c.SetWithTTL("short", 1, time.Second)
+	c.SetWithTTL("long", 2, time.Hour)
+
+	tc.SuspendExpiration()
+	tc.SuspendExpiration()
+	clock.Advance(time.Minute)
+	if value, ok := c.Get("short"); !ok || value != 1 {
+		t.Fatalf("Get(short) = %v, %v past its TTL while suspended, want 1, true", value, ok)
+	}
+	if removed := c.(Cleanable).RemoveExpired(); removed != 0 {
+		t.Fatalf("RemoveExpired() = %d while suspended, want 0", removed)
+	}
+
+	// The TTL elapsed during the suspension, so the item expires as soon as it ends.
+	tc.ResumeExpiration()
+	if _, ok := c.Get("short"); ok {
+		t.Fatal("Get(short) ok = true after ResumeExpiration")
+	}
+	if _, ok := c.Get("long"); !ok {
+		t.Fatal("Get(long) ok = false, want an item within its TTL kept")
+	}
+}
+
+func TestSuspendedCacheWorkerKeepsItems(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCacheWithConfig(CacheConfig{Clock: clock})
+	c.SetWithTTL("short", 1, time.Second)
+	c.(TTLCache).SuspendExpiration()
+	clock.Advance(time.Minute)
+
+	startWorker(t, CacheWorkerConfig{Cache: c, Interval: time.Millisecond, Logger: discardLogger()})
+	time.Sleep(20 * time.Millisecond)
+	if size := c.(StatsReporter).Stats().Size; size != 1 {
+		t.Fatalf("Size = %d, want the worker to keep the overdue item while suspended", size)
+	}
+
+	c.(TTLCache).ResumeExpiration()
+	eventually(t, func() bool { return c.(StatsReporter).Stats().Size == 0 }, "the worker did not remove the item after ResumeExpiration")
+}
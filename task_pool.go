@@ -0,0 +1,188 @@
+package cache
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+// TaskPool runs background tasks on at most a fixed number of goroutines. Goroutines are started when tasks
+// are queued and exit when nothing is left to run, so an idle pool holds none.
+//
+// A pool can be shared by several caches, through CacheConfig.TaskPool and NewReadMostlyCacheWithPool,
+// to bound the background goroutines of all of them together. Tasks beyond the bound wait in a queue,
+// so a task that blocks, such as a KeysChan stream whose receiver stopped reading, delays the tasks behind it.
+type TaskPool struct {
+	mu      sync.Mutex
+	size    int
+	running int
+	ready   []func()
+	delayed delayedTasks
+	// timekeeper reports whether a goroutine is waiting for the earliest delayed task to become due.
+	timekeeper bool
+	// wake interrupts the timekeeper's wait when a task is added that it must handle sooner.
+	wake chan struct{}
+}
+
+// NewTaskPool returns a pool that runs tasks on at most size goroutines. A size below 1 is treated as 1.
+func NewTaskPool(size int) *TaskPool {
+	return &TaskPool{
+		size: max(size, 1),
+		wake: make(chan struct{}, 1),
+	}
+}
+
+// stopper is a scheduled task that can be canceled, implemented by *time.Timer and *delayedTask.
+type stopper interface {
+	// Stop cancels the task and reports whether it did so before the task ran.
+	Stop() bool
+}
+
+// goTask runs fn on the pool, or in a new goroutine if the pool is nil.
+func (p *TaskPool) goTask(fn func()) {
+	if p == nil {
+		go fn()
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.ready = append(p.ready, fn)
+	p.startLocked()
+}
+
+// afterFunc runs fn on the pool once d has elapsed, or with time.AfterFunc if the pool is nil.
+func (p *TaskPool) afterFunc(d time.Duration, fn func()) stopper {
+	if p == nil {
+		return time.AfterFunc(d, fn)
+	}
+
+	task := &delayedTask{pool: p, at: time.Now().Add(d), fn: fn}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	heap.Push(&p.delayed, task)
+	p.startLocked()
+
+	return task
+}
+
+// startLocked makes sure a goroutine will pick up the queued tasks: it starts one if the pool is below its size,
+// and otherwise wakes the timekeeper, which may be waiting on a later deadline. Busy goroutines check the queue
+// when their task finishes. The caller must hold p.mu.
+func (p *TaskPool) startLocked() {
+	if p.running < p.size && (len(p.ready) > 0 || !p.timekeeper) {
+		p.running++
+		go p.run()
+		return
+	}
+	if p.timekeeper {
+		select {
+		case p.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// run executes ready tasks until there are none. Then, unless another goroutine already does, it waits for
+// the earliest delayed task to become due; otherwise it exits.
+func (p *TaskPool) run() {
+	var timer *time.Timer
+
+	p.mu.Lock()
+	for {
+		p.promoteLocked(time.Now())
+		if len(p.ready) > 0 {
+			fn := p.ready[0]
+			p.ready[0] = nil
+			p.ready = p.ready[1:]
+			p.mu.Unlock()
+
+			fn()
+
+			p.mu.Lock()
+			continue
+		}
+		if len(p.delayed) == 0 || p.timekeeper {
+			p.running--
+			p.mu.Unlock()
+			return
+		}
+
+		p.timekeeper = true
+		wait := time.Until(p.delayed[0].at)
+		p.mu.Unlock()
+
+		if timer == nil {
+			timer = time.NewTimer(wait)
+		} else {
+			timer.Reset(wait)
+		}
+		select {
+		case <-timer.C:
+		case <-p.wake:
+			timer.Stop()
+		}
+
+		p.mu.Lock()
+		p.timekeeper = false
+	}
+}
+
+// promoteLocked moves the delayed tasks due at now to the ready queue, dropping stopped ones.
+// The caller must hold p.mu.
+func (p *TaskPool) promoteLocked(now time.Time) {
+	for len(p.delayed) > 0 && !p.delayed[0].at.After(now) {
+		task := heap.Pop(&p.delayed).(*delayedTask)
+		if task.stopped {
+			continue
+		}
+		task.fired = true
+		p.ready = append(p.ready, task.fn)
+	}
+}
+
+// delayedTask is a task scheduled with TaskPool.afterFunc. Its flags are guarded by the pool's lock.
+type delayedTask struct {
+	pool    *TaskPool
+	at      time.Time
+	fn      func()
+	stopped bool
+	fired   bool
+}
+
+// Stop cancels the task and reports whether it did so before the task was queued to run.
+func (t *delayedTask) Stop() bool {
+	t.pool.mu.Lock()
+	defer t.pool.mu.Unlock()
+
+	if t.stopped || t.fired {
+		return false
+	}
+	t.stopped = true
+
+	return true
+}
+
+// delayedTasks is a min-heap of delayed tasks ordered by when they are due.
+type delayedTasks []*delayedTask
+
+// Len, Less, Swap, Push, and Pop implement heap.Interface.
+func (h delayedTasks) Len() int           { return len(h) }
+func (h delayedTasks) Less(i, j int) bool { return h[i].at.Before(h[j].at) }
+func (h delayedTasks) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *delayedTasks) Push(x any) {
+	*h = append(*h, x.(*delayedTask))
+}
+
+func (h *delayedTasks) Pop() any {
+	old := *h
+	task := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+
+	return task
+}
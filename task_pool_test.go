@@ -0,0 +1,160 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitGoroutines polls until cond holds, failing the test if the number of live goroutines
+// ever exceeds limit in the meantime.
+func waitGoroutines(t *testing.T, limit int, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	peak := 0
+	for !cond() {
+		if n := runtime.NumGoroutine(); n > peak {
+			peak = n
+		}
+		if peak > limit {
+			t.Fatalf("live goroutines reached %d, want at most %d", peak, limit)
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("background tasks did not finish in time")
+		}
+		time.Sleep(100 * time.Microsecond)
+	}
+}
+
+func TestTaskPoolBoundsGoroutines(t *testing.T) {
+	const size = 4
+	pool := NewTaskPool(size)
+	base := runtime.NumGoroutine()
+
+	var done atomic.Int64
+	for i := range 500 {
+		pool.afterFunc(time.Duration(i%10)*time.Millisecond, func() { done.Add(1) })
+		pool.goTask(func() {
+			time.Sleep(100 * time.Microsecond)
+			done.Add(1)
+		})
+	}
+
+	waitGoroutines(t, base+size, func() bool { return done.Load() == 1000 })
+}
+
+func TestTaskPoolStoppedTaskDoesNotRun(t *testing.T) {
+	pool := NewTaskPool(1)
+
+	var ran atomic.Bool
+	task := pool.afterFunc(10*time.Millisecond, func() { ran.Store(true) })
+	if !task.Stop() {
+		t.Fatal("Stop() = false, want true for a pending task")
+	}
+	if task.Stop() {
+		t.Fatal("second Stop() = true, want false")
+	}
+
+	fired := make(chan struct{})
+	pool.afterFunc(20*time.Millisecond, func() { close(fired) })
+	<-fired
+	if ran.Load() {
+		t.Fatal("stopped task ran")
+	}
+}
+
+func TestTaskPoolRunsEarlierDeadlineFirst(t *testing.T) {
+	pool := NewTaskPool(1)
+
+	order := make(chan int, 2)
+	pool.afterFunc(50*time.Millisecond, func() { order <- 2 })
+	pool.afterFunc(5*time.Millisecond, func() { order <- 1 })
+
+	if first := <-order; first != 1 {
+		t.Fatalf("first task = %d, want the one with the earlier deadline", first)
+	}
+	<-order
+}
+
+func TestCacheBackgroundTasksShareTaskPool(t *testing.T) {
+	const size = 3
+	pool := NewTaskPool(size)
+	base := runtime.NumGoroutine()
+
+	debounced := NewCacheWithConfig(CacheConfig{WriteDebounce: 5 * time.Millisecond, TaskPool: pool})
+	watched := NewCacheWithConfig(CacheConfig{WatchCoalesce: 100 * time.Millisecond, TaskPool: pool}).(WatchableCache)
+	mostly := NewReadMostlyCacheWithPool(pool)
+
+	const keys = 200
+	events := make([]<-chan CacheEvent, keys)
+	for i := range keys {
+		key := fmt.Sprintf("key:%d", i)
+		ch, cancel := watched.Watch(key)
+		defer cancel()
+		events[i] = ch
+	}
+
+	for round := range 3 {
+		for i := range keys {
+			key := fmt.Sprintf("key:%d", i)
+			debounced.Set(key, round)
+			watched.Set(key, round)
+		}
+	}
+	for i := range 20 {
+		mostly.Set(fmt.Sprintf("key:%d", i), i)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	streams := make([]<-chan string, 10)
+	for i := range streams {
+		streams[i] = debounced.(InspectableCache).KeysChan(ctx)
+	}
+	for _, stream := range streams {
+		for range stream {
+		}
+	}
+
+	waitGoroutines(t, base+size, func() bool {
+		for i := range keys {
+			if value, _ := debounced.Get(fmt.Sprintf("key:%d", i)); value != 2 {
+				return false
+			}
+		}
+		return true
+	})
+
+	for i, ch := range events {
+		select {
+		case event := <-ch:
+			if event.Value != 2 {
+				t.Fatalf("coalesced event for key:%d carries %v, want the latest value 2", i, event.Value)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("no coalesced event for key:%d", i)
+		}
+	}
+	if got := mostly.Len(); got != 20 {
+		t.Fatalf("read-mostly Len() = %d, want 20", got)
+	}
+}
+
+func TestCoarseClockOnTaskPool(t *testing.T) {
+	pool := NewTaskPool(1)
+	clock := newCoarseClock(time.Millisecond, pool)
+	defer clock.close()
+
+	start := clock.Now()
+	deadline := time.Now().Add(time.Second)
+	for !clock.Now().After(start) {
+		if time.Now().After(deadline) {
+			t.Fatal("clock was not refreshed by the pool")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
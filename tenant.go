@@ -0,0 +1,105 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// DefaultTenantSeparator separates the tenant ID from the key in the keys a TenantCache stores.
+const DefaultTenantSeparator = ":"
+
+// TenantConfig holds the configuration for creating a TenantCache.
+type TenantConfig struct {
+	// ContextKey is the context key under which requests carry their tenant ID, as a non-empty string.
+	ContextKey any
+	// Separator separates the tenant ID from the key. If empty, DefaultTenantSeparator is used.
+	// Tenant IDs should not contain it, or the keys of two tenants may collide.
+	Separator string
+}
+
+// TenantCache scopes the keys of a shared Cache per tenant, taking the tenant ID from the request context,
+// so that callers do not thread a prefix through every call. A key is stored as "<tenant><separator><key>".
+type TenantCache struct {
+	cache      Cache
+	contextKey any
+	separator  string
+}
+
+// NewTenantCache creates a TenantCache that stores the keys of every tenant in c.
+func NewTenantCache(c Cache, cfg TenantConfig) *TenantCache {
+	separator := cfg.Separator
+	if separator == "" {
+		separator = DefaultTenantSeparator
+	}
+
+	return &TenantCache{cache: c, contextKey: cfg.ContextKey, separator: separator}
+}
+
+// GetCtx retrieves the value for key in the tenant of ctx.
+// Returns (nil, false) if the key does not exist, the item is expired, or ctx carries no tenant ID.
+func (t *TenantCache) GetCtx(ctx context.Context, key string) (any, bool) {
+	scoped, err := t.scopedKey(ctx, key)
+	if err != nil {
+		return nil, false
+	}
+
+	return t.cache.Get(scoped)
+}
+
+// GetCtxChecked is like GetCtx but returns an error wrapping ErrNoTenant if ctx carries no tenant ID,
+// and ErrNotFound if the key does not exist or the item is expired.
+func (t *TenantCache) GetCtxChecked(ctx context.Context, key string) (any, error) {
+	scoped, err := t.scopedKey(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	value, ok := t.cache.Get(scoped)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrNotFound, scoped)
+	}
+
+	return value, nil
+}
+
+// SetCtx assigns a value to key in the tenant of ctx with a TTL. If ttl <= 0, the item does not expire.
+// Nothing is stored if ctx carries no tenant ID.
+func (t *TenantCache) SetCtx(ctx context.Context, key string, value any, ttl time.Duration) {
+	if scoped, err := t.scopedKey(ctx, key); err == nil {
+		t.cache.SetWithTTL(scoped, value, ttl)
+	}
+}
+
+// SetCtxChecked is like SetCtx but returns an error wrapping ErrNoTenant if ctx carries no tenant ID,
+// and otherwise the error of the underlying cache's SetChecked if it implements CheckedCache.
+func (t *TenantCache) SetCtxChecked(ctx context.Context, key string, value any, ttl time.Duration) error {
+	scoped, err := t.scopedKey(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	if checked, ok := t.cache.(CheckedCache); ok {
+		return checked.SetChecked(scoped, value, ttl)
+	}
+	t.cache.SetWithTTL(scoped, value, ttl)
+
+	return nil
+}
+
+// DeleteCtx removes key from the tenant of ctx. Nothing is removed if ctx carries no tenant ID.
+func (t *TenantCache) DeleteCtx(ctx context.Context, key string) {
+	if scoped, err := t.scopedKey(ctx, key); err == nil {
+		t.cache.Delete(scoped)
+	}
+}
+
+// scopedKey returns key prefixed with the tenant ID of ctx, or an error wrapping ErrNoTenant if ctx carries none.
+func (t *TenantCache) scopedKey(ctx context.Context, key string) (string, error) {
+	tenant, _ := ctx.Value(t.contextKey).(string)
+	if tenant == "" {
+		return "", fmt.Errorf("%w: no tenant ID under context key %v", ErrNoTenant, t.contextKey)
+	}
+
+	return tenant + t.separator + key, nil
+}
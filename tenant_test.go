@@ -0,0 +1,83 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type tenantTestKey struct{}
+
+func tenantContext(tenant string) context.Context {
+	return context.WithValue(context.Background(), tenantTestKey{}, tenant)
+}
+
+func TestTenantCacheIsolatesTenants(t *testing.T) {
+	c := NewCache()
+	tc := NewTenantCache(c, TenantConfig{ContextKey: tenantTestKey{}})
+	acme, other := tenantContext("acme"), tenantContext("other")
+
+	tc.SetCtx(acme, "user:1", "ann", 0)
+	tc.SetCtx(other, "user:1", "bob", 0)
+	if value, ok := tc.GetCtx(acme, "user:1"); !ok || value != "ann" {
+		t.Fatalf("GetCtx(acme, user:1) = %v, %v, want ann, true", value, ok)
+	}
+	if value, ok := tc.GetCtx(other, "user:1"); !ok || value != "bob" {
+		t.Fatalf("GetCtx(other, user:1) = %v, %v, want bob, true", value, ok)
+	}
+	if value, ok := c.Get("acme:user:1"); !ok || value != "ann" {
+		t.Fatalf("Get(acme:user:1) = %v, %v, want the key stored with the tenant prefix", value, ok)
+	}
+
+	tc.DeleteCtx(acme, "user:1")
+	if _, ok := tc.GetCtx(acme, "user:1"); ok {
+		t.Fatal("GetCtx(acme, user:1) ok = true after DeleteCtx")
+	}
+	if _, ok := tc.GetCtx(other, "user:1"); !ok {
+		t.Fatal("DeleteCtx in one tenant removed the key of another")
+	}
+
+	if _, err := tc.GetCtxChecked(acme, "user:1"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("GetCtxChecked(acme, user:1) error = %v, want ErrNotFound", err)
+	}
+	if err := tc.SetCtxChecked(acme, "user:2", "cat", time.Minute); err != nil {
+		t.Fatalf("SetCtxChecked() error = %v", err)
+	}
+	if value, err := tc.GetCtxChecked(acme, "user:2"); err != nil || value != "cat" {
+		t.Fatalf("GetCtxChecked(acme, user:2) = %v, %v, want cat, nil", value, err)
+	}
+	if _, ok := tc.GetCtx(other, "user:2"); ok {
+		t.Fatal("a key set in one tenant is visible in another")
+	}
+}
+
+func TestTenantCacheWithoutTenant(t *testing.T) {
+	c := NewCache()
+	tc := NewTenantCache(c, TenantConfig{ContextKey: tenantTestKey{}, Separator: "/"})
+
+	for name, ctx := range map[string]context.Context{
+		"no value":   context.Background(),
+		"empty":      tenantContext(""),
+		"not string": context.WithValue(context.Background(), tenantTestKey{}, 42),
+	} {
+		if err := tc.SetCtxChecked(ctx, "a", 1, 0); !errors.Is(err, ErrNoTenant) {
+			t.Fatalf("%s: SetCtxChecked() error = %v, want ErrNoTenant", name, err)
+		}
+		if _, err := tc.GetCtxChecked(ctx, "a"); !errors.Is(err, ErrNoTenant) {
+			t.Fatalf("%s: GetCtxChecked() error = %v, want ErrNoTenant", name, err)
+		}
+		tc.SetCtx(ctx, "a", 1, 0)
+		if _, ok := tc.GetCtx(ctx, "a"); ok {
+			t.Fatalf("%s: GetCtx() ok = true without a tenant", name)
+		}
+	}
+	if size := c.(StatsReporter).Stats().Size; size != 0 {
+		t.Fatalf("Size = %d, want nothing stored without a tenant", size)
+	}
+
+	tc.SetCtx(tenantContext("acme"), "a", 1, 0)
+	if _, ok := c.Get("acme/a"); !ok {
+		t.Fatal("key not stored with the configured separator")
+	}
+}
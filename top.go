@@ -0,0 +1,39 @@
+package cache
+
+import (
+	"cmp"
+	"slices"
+)
+
+// TopN returns up to n live items with the most hits, sorted by hits in descending order
+// and by key among items with the same hits. Hits are counted by Get, GetIfFresh, GetAndRefresh,
+// and Tx.Get, and survive overwriting a live key. Returns nil if n <= 0.
+func (c *inMemoryCache) TopN(n int) []ItemStats {
+	if n <= 0 {
+		return nil
+	}
+
+	c.mu.RLock()
+	stats := make([]ItemStats, 0, len(c.items))
+	for key, item := range c.items {
+		if c.isExpired(item) {
+			continue
+		}
+		stats = append(stats, ItemStats{
+			Key:       key,
+			Value:     c.copyValue(item.value),
+			Hits:      item.accesses.Load(),
+			ExpiresAt: item.expiration,
+		})
+	}
+	c.mu.RUnlock()
+
+	slices.SortFunc(stats, func(a, b ItemStats) int {
+		if a.Hits != b.Hits {
+			return cmp.Compare(b.Hits, a.Hits)
+		}
+		return cmp.Compare(a.Key, b.Key)
+	})
+
+	return slices.Clip(stats[:min(n, len(stats))])
+}
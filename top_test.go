@@ -0,0 +1,65 @@
+package cache
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// topKeys returns the keys of stats in order.
+func topKeys(stats []ItemStats) []string {
+	keys := make([]string, len(stats))
+	for i, s := range stats {
+		keys[i] = s.Key
+	}
+
+	return keys
+}
+
+func TestTopN(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCacheWithConfig(CacheConfig{Clock: clock})
+	hits := map[string]int{"cold": 0, "warm": 2, "hot": 5, "tie:a": 3, "tie:b": 3}
+	for key, n := range hits {
+		c.Set(key, key)
+		for range n {
+			c.Get(key)
+		}
+	}
+	c.SetWithTTL("expired", 1, time.Second)
+	for range 10 {
+		c.Get("expired")
+	}
+	clock.Advance(time.Minute)
+
+	inspect := c.(InspectableCache)
+	top := inspect.TopN(3)
+	if want := []string{"hot", "tie:a", "tie:b"}; !slices.Equal(topKeys(top), want) {
+		t.Fatalf("TopN(3) keys = %q, want %q", topKeys(top), want)
+	}
+	if top[0].Hits != 5 || top[0].Value != "hot" {
+		t.Fatalf("TopN(3)[0] = %+v, want hot with 5 hits", top[0])
+	}
+
+	all := inspect.TopN(100)
+	if want := []string{"hot", "tie:a", "tie:b", "warm", "cold"}; !slices.Equal(topKeys(all), want) {
+		t.Fatalf("TopN(100) keys = %q, want every live key %q", topKeys(all), want)
+	}
+	if top := inspect.TopN(0); top != nil {
+		t.Fatalf("TopN(0) = %v, want nil", top)
+	}
+}
+
+func TestTopNCountsHitsAcrossOverwrites(t *testing.T) {
+	c := NewCache()
+	c.Set("a", 1)
+	c.Get("a")
+	c.Set("a", 2)
+	c.Get("a")
+	c.Get("missing")
+
+	top := c.(InspectableCache).TopN(1)
+	if len(top) != 1 || top[0].Hits != 2 || top[0].Value != 2 {
+		t.Fatalf("TopN(1) = %+v, want a with 2 hits and the latest value", top)
+	}
+}
@@ -0,0 +1,80 @@
+package cache
+
+import (
+	"time"
+)
+
+// Tx gives access to the cache inside a Transaction.
+// All operations run under the write lock held for the whole transaction.
+type Tx interface {
+	// Get retrieves the value for the specified key.
+	// Returns (nil, false) if the key does not exist or if the item is expired.
+	Get(key string) (any, bool)
+	// Set assigns a value to the specified key with the cache's default TTL for the key's prefix, if any,
+	// and otherwise without expiration.
+	Set(key string, value any)
+	// SetWithTTL assigns a value to the specified key with a TTL.
+	// If ttl <= 0, the item does not expire.
+	SetWithTTL(key string, value any, ttl time.Duration)
+	// Delete removes the item associated with the specified key.
+	Delete(key string)
+}
+
+// cacheTx implements Tx for an inMemoryCache whose write lock is held.
+type cacheTx struct {
+	c *inMemoryCache
+}
+
+// Transaction runs fn while holding the cache's write lock, so the reads and writes made through tx
+// are atomic with respect to all other callers. fn must only use tx and must not call the cache's
+// own methods, which would deadlock. Expiration callbacks run after fn returns and the lock is released.
+func (c *inMemoryCache) Transaction(fn func(tx Tx)) {
+	c.mu.Lock()
+	defer c.unlock()
+
+	fn(cacheTx{c: c})
+}
+
+// Get retrieves the value for the specified key, removing it if it is expired.
+func (tx cacheTx) Get(key string) (any, bool) {
+	key = tx.c.normalizeKey(key)
+
+	item, ok := tx.c.items[key]
+	if !ok {
+		tx.c.misses.Add(1)
+		return nil, false
+	}
+
+	if tx.c.isExpired(item) {
+		tx.c.misses.Add(1)
+		tx.c.removeLocked(key, EventExpire)
+		return nil, false
+	}
+
+	tx.c.hits.Add(1)
+	item.accesses.Add(1)
+	if tx.c.policy != nil {
+		tx.c.policy.OnAccess(key)
+	}
+
+	return tx.c.copyValue(item.value), true
+}
+
+// Set assigns a value to the specified key with the default TTL of its prefix, if any.
+func (tx cacheTx) Set(key string, value any) {
+	tx.SetWithTTL(key, value, tx.c.prefixTTL(key))
+}
+
+// SetWithTTL assigns a value to the specified key with a TTL.
+func (tx cacheTx) SetWithTTL(key string, value any, ttl time.Duration) {
+	key = tx.c.normalizeKey(key)
+
+	tx.c.storeLocked(key, tx.c.newItem(value, ttl))
+}
+
+// Delete removes the item associated with the specified key.
+func (tx cacheTx) Delete(key string) {
+	key = tx.c.normalizeKey(key)
+
+	tx.c.removeLocked(key, EventDelete)
+}
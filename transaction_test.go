@@ -0,0 +1,91 @@
+package cache
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestTransactionReadModifyWrite(t *testing.T) {
+	c := NewCache()
+	c.Set("checking", 1000)
+	c.Set("savings", 1000)
+	bc := c.(BulkCache)
+
+	const (
+		workers   = 8
+		transfers = 500
+	)
+	var wg sync.WaitGroup
+	for w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			from, to := "checking", "savings"
+			if w%2 == 1 {
+				from, to = to, from
+			}
+			for range transfers {
+				bc.Transaction(func(tx Tx) {
+					a, _ := tx.Get(from)
+					b, _ := tx.Get(to)
+					tx.Set(from, a.(int)-1)
+					tx.Set(to, b.(int)+1)
+				})
+			}
+		}()
+	}
+
+	// Concurrent readers must never see a transfer half done.
+	done := make(chan struct{})
+	var readers sync.WaitGroup
+	readers.Add(1)
+	go func() {
+		defer readers.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			var total int
+			bc.Transaction(func(tx Tx) {
+				a, _ := tx.Get("checking")
+				b, _ := tx.Get("savings")
+				total = a.(int) + b.(int)
+			})
+			if total != 2000 {
+				t.Errorf("observed total %d mid-transfer, want 2000", total)
+				return
+			}
+		}
+	}()
+
+	wg.Wait()
+	close(done)
+	readers.Wait()
+
+	a, _ := c.Get("checking")
+	b, _ := c.Get("savings")
+	if a.(int) != 1000 || b.(int) != 1000 {
+		t.Fatalf("balances = %v, %v, want 1000, 1000 after balanced transfers", a, b)
+	}
+}
+
+func TestTransactionDelete(t *testing.T) {
+	c := NewCache()
+	c.Set("a", 1)
+	c.(BulkCache).Transaction(func(tx Tx) {
+		tx.Delete("a")
+		if _, ok := tx.Get("a"); ok {
+			t.Error("tx.Get(a) ok = true after tx.Delete")
+		}
+		tx.SetWithTTL("b", 2, 0)
+	})
+
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("Get(a) ok = true after the transaction deleted it")
+	}
+	if value, ok := c.Get("b"); !ok || value != 2 {
+		t.Fatalf("Get(b) = %v, %v, want 2, true", value, ok)
+	}
+}
@@ -0,0 +1,65 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// GetMultiTyped retrieves the values for the specified keys from c and returns those that are present,
+// not expired, and of type V. Keys that are missing, expired, or hold a value of another type are left out.
+// Each key is read with its own Get, so the result is not an atomic snapshot.
+func GetMultiTyped[V any](c Cache, keys []string) map[string]V {
+	values := make(map[string]V, len(keys))
+	for _, key := range keys {
+		value, ok := c.Get(key)
+		if !ok {
+			continue
+		}
+		if typed, ok := value.(V); ok {
+			values[key] = typed
+		}
+	}
+
+	return values
+}
+
+// GetJSON retrieves the JSON document stored under key in c as a []byte or string and decodes it into a V.
+// Returns false if the key is missing or expired. Returns an error wrapping ErrWrongType if the stored value
+// is neither a []byte nor a string, and the decoding error if the document is not valid JSON for V.
+func GetJSON[V any](c Cache, key string) (V, bool, error) {
+	var v V
+
+	value, ok := c.Get(key)
+	if !ok {
+		return v, false, nil
+	}
+
+	var data []byte
+	switch raw := value.(type) {
+	case []byte:
+		data = raw
+	case string:
+		data = []byte(raw)
+	default:
+		return v, true, fmt.Errorf("%w: key %q holds %T, not JSON bytes or string", ErrWrongType, key, value)
+	}
+
+	if err := json.Unmarshal(data, &v); err != nil {
+		return v, true, fmt.Errorf("cache: decode JSON for key %q: %w", key, err)
+	}
+
+	return v, true, nil
+}
+
+// SetJSON encodes value as JSON and stores the resulting []byte under key in c with the given TTL.
+// If ttl <= 0, the value does not expire. If value cannot be encoded, nothing is stored and the error is returned.
+func SetJSON(c Cache, key string, value any, ttl time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("cache: encode JSON for key %q: %w", key, err)
+	}
+
+	c.SetWithTTL(key, data, ttl)
+	return nil
+}
@@ -0,0 +1,102 @@
+package cache
+
+import (
+	"errors"
+	"maps"
+	"testing"
+	"time"
+)
+
+func TestGetMultiTyped(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCacheWithConfig(CacheConfig{Clock: clock})
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Set("string", "3")
+	c.Set("int64", int64(4))
+	c.SetWithTTL("expired", 5, time.Second)
+	clock.Advance(time.Minute)
+
+	got := GetMultiTyped[int](c, []string{"a", "b", "string", "int64", "expired", "missing"})
+	if want := map[string]int{"a": 1, "b": 2}; !maps.Equal(got, want) {
+		t.Fatalf("GetMultiTyped[int]() = %v, want %v", got, want)
+	}
+
+	if got := GetMultiTyped[string](c, []string{"a", "string"}); !maps.Equal(got, map[string]string{"string": "3"}) {
+		t.Fatalf("GetMultiTyped[string]() = %v, want map[string:3]", got)
+	}
+	if got := GetMultiTyped[int](c, nil); len(got) != 0 {
+		t.Fatalf("GetMultiTyped() of no keys = %v, want empty", got)
+	}
+}
+
+func TestGetMultiTypedInterface(t *testing.T) {
+	c := NewCache()
+	c.Set("stringer", time.Second)
+	c.Set("int", 1)
+
+	got := GetMultiTyped[interface{ String() string }](c, []string{"stringer", "int"})
+	if len(got) != 1 || got["stringer"].String() != "1s" {
+		t.Fatalf("GetMultiTyped[Stringer]() = %v, want only the Duration", got)
+	}
+}
+
+type jsonTestUser struct {
+	Name string `json:"name"`
+	Age  int    `json:"age"`
+}
+
+func TestGetJSON(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCacheWithConfig(CacheConfig{Clock: clock})
+	c.Set("bytes", []byte(`{"name":"ann","age":30}`))
+	c.Set("string", `{"name":"bob","age":40}`)
+	c.Set("malformed", []byte(`{"name":`))
+	c.Set("number", 42)
+	c.SetWithTTL("expired", []byte(`{}`), time.Second)
+	clock.Advance(time.Minute)
+
+	for key, want := range map[string]jsonTestUser{"bytes": {"ann", 30}, "string": {"bob", 40}} {
+		user, ok, err := GetJSON[jsonTestUser](c, key)
+		if err != nil || !ok || user != want {
+			t.Fatalf("GetJSON(%s) = %+v, %v, %v, want %+v, true, nil", key, user, ok, err, want)
+		}
+	}
+
+	if _, ok, err := GetJSON[jsonTestUser](c, "malformed"); !ok || err == nil {
+		t.Fatalf("GetJSON(malformed) = %v, %v, want true and a decoding error", ok, err)
+	}
+	if _, ok, err := GetJSON[jsonTestUser](c, "number"); !ok || !errors.Is(err, ErrWrongType) {
+		t.Fatalf("GetJSON(number) = %v, %v, want true and ErrWrongType", ok, err)
+	}
+	for _, key := range []string{"missing", "expired"} {
+		if _, ok, err := GetJSON[jsonTestUser](c, key); ok || err != nil {
+			t.Fatalf("GetJSON(%s) = %v, %v, want false, nil", key, ok, err)
+		}
+	}
+}
+
+func TestSetJSON(t *testing.T) {
+	c := NewCache()
+
+	if err := SetJSON(c, "user", jsonTestUser{Name: "ann", Age: 30}, time.Minute); err != nil {
+		t.Fatalf("SetJSON() error = %v", err)
+	}
+	value, ok := c.Get("user")
+	if data, isBytes := value.([]byte); !ok || !isBytes || string(data) != `{"name":"ann","age":30}` {
+		t.Fatalf("Get(user) = %v, %v, want the JSON bytes", value, ok)
+	}
+	if ttl, _ := c.(TTLCache).GetTTL("user"); ttl <= 0 || ttl > time.Minute {
+		t.Fatalf("GetTTL(user) = %v, want the TTL given to SetJSON", ttl)
+	}
+	if user, _, err := GetJSON[jsonTestUser](c, "user"); err != nil || user != (jsonTestUser{"ann", 30}) {
+		t.Fatalf("GetJSON(user) = %+v, %v, want the value round-tripped", user, err)
+	}
+
+	if err := SetJSON(c, "channel", make(chan int), 0); err == nil {
+		t.Fatal("SetJSON() of a channel error = nil")
+	}
+	if _, ok := c.Get("channel"); ok {
+		t.Fatal("a value that failed to encode was stored")
+	}
+}
@@ -0,0 +1,149 @@
+package cache
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Verify checks that the priority and tier indexes, the total size, and the eviction policy track exactly the stored items.
+// It returns an error describing the first mismatch found. Verify holds the read lock and walks all items,
+// so it is meant for tests and debugging rather than production code paths.
+func (c *inMemoryCache) Verify() error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if err := c.verifyTiersLocked(); err != nil {
+		return err
+	}
+	if err := c.verifyPinnedLocked(); err != nil {
+		return err
+	}
+	if err := c.verifySizeLocked(); err != nil {
+		return err
+	}
+	if c.policy == nil {
+		return nil
+	}
+	if err := c.verifyPrioritiesLocked(); err != nil {
+		return err
+	}
+
+	return c.verifyPolicyLocked()
+}
+
+// verifyTiersLocked checks that every item with a tier is in that tier's index and that the index
+// holds no other keys. The caller must hold the lock.
+func (c *inMemoryCache) verifyTiersLocked() error {
+	indexed := 0
+	for tier, keys := range c.tiers {
+		if len(keys) == 0 {
+			return fmt.Errorf("cache: verify: tier %q has an empty index", tier)
+		}
+		for key := range keys {
+			item, ok := c.items[key]
+			if !ok {
+				return fmt.Errorf("cache: verify: tier %q indexes missing key %q", tier, key)
+			}
+			if item.tier != tier {
+				return fmt.Errorf("cache: verify: tier %q indexes key %q stored in tier %q", tier, key, item.tier)
+			}
+		}
+		indexed += len(keys)
+	}
+
+	for key, item := range c.items {
+		if item.tier == "" {
+			continue
+		}
+		if _, ok := c.tiers[item.tier][key]; !ok {
+			return fmt.Errorf("cache: verify: key %q in tier %q is not indexed", key, item.tier)
+		}
+		indexed--
+	}
+	if indexed != 0 {
+		return fmt.Errorf("cache: verify: tier indexes hold %d keys that are not stored", indexed)
+	}
+
+	return nil
+}
+
+// verifyPinnedLocked checks that the pinned count matches the stored items. The caller must hold the lock.
+func (c *inMemoryCache) verifyPinnedLocked() error {
+	pinned := 0
+	for _, item := range c.items {
+		if item.pinned {
+			pinned++
+		}
+	}
+	if pinned != c.pinned {
+		return fmt.Errorf("cache: verify: pinned count is %d, want %d", c.pinned, pinned)
+	}
+
+	return nil
+}
+
+// verifySizeLocked checks that the total size matches the sizes of the stored items. The caller must hold the lock.
+func (c *inMemoryCache) verifySizeLocked() error {
+	var size int64
+	for _, item := range c.items {
+		size += int64(item.size)
+	}
+	if size != c.bytes {
+		return fmt.Errorf("cache: verify: total size is %d, want %d", c.bytes, size)
+	}
+
+	return nil
+}
+
+// verifyPrioritiesLocked checks that the priority counts match the stored items. The caller must hold the lock.
+func (c *inMemoryCache) verifyPrioritiesLocked() error {
+	counts := make(map[int]int, len(c.priorities))
+	for _, item := range c.items {
+		counts[item.priority]++
+	}
+
+	for priority, count := range counts {
+		if c.priorities[priority] != count {
+			return fmt.Errorf("cache: verify: priority %d counts %d items, want %d", priority, c.priorities[priority], count)
+		}
+	}
+	for priority, count := range c.priorities {
+		if _, ok := counts[priority]; !ok {
+			return fmt.Errorf("cache: verify: priority %d counts %d items, want 0", priority, count)
+		}
+	}
+
+	return nil
+}
+
+// verifyPolicyLocked checks that the eviction policy tracks exactly the stored keys, and the keys removed by
+// a Clear that has not told it of them yet. Policies that are not an OrderedPolicy cannot list their keys and are not checked. Policies may yield a key more than once
+// from Candidates, so distinct keys are compared. The caller must hold the lock.
+func (c *inMemoryCache) verifyPolicyLocked() error {
+	ordered, ok := c.policy.(OrderedPolicy)
+	if !ok {
+		return nil
+	}
+
+	tracked := make(map[string]struct{}, len(c.items))
+	for key := range ordered.Candidates() {
+		if _, ok := c.items[key]; !ok && !c.clearingLocked(key) {
+			return fmt.Errorf("cache: verify: eviction policy tracks missing key %q", key)
+		}
+		tracked[key] = struct{}{}
+	}
+
+	for key := range c.items {
+		if _, ok := tracked[key]; !ok {
+			return fmt.Errorf("cache: verify: key %q is not tracked by the eviction policy", key)
+		}
+	}
+
+	return nil
+}
+
+// clearingLocked reports whether key was removed by a Clear that has not told the eviction policy of it yet.
+// The caller must hold the lock.
+func (c *inMemoryCache) clearingLocked(key string) bool {
+	return slices.ContainsFunc(c.cleared, func(cleared *clearedKeys) bool { return cleared.pending(key) })
+}
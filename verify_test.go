@@ -0,0 +1,74 @@
+package cache
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// newVerifyTestCache returns a bounded cache with every index populated.
+func newVerifyTestCache(t *testing.T) *inMemoryCache {
+	t.Helper()
+
+	c := NewBoundedCache(10, NewLRUPolicy()).(*inMemoryCache)
+	c.SetWithTier("a", "value", time.Hour, "hot")
+	c.SetWithPriority("b", "value", 0, 5)
+	c.Set("c", "value")
+	c.Pin("c")
+	if err := c.Verify(); err != nil {
+		t.Fatalf("Verify() of a consistent cache error = %v", err)
+	}
+
+	return c
+}
+
+func TestVerifyDetectsDesync(t *testing.T) {
+	tests := []struct {
+		name   string
+		desync func(c *inMemoryCache)
+		want   string
+	}{
+		{"tier index misses key", func(c *inMemoryCache) { delete(c.tiers["hot"], "a") }, `tier "hot" has an empty index`},
+		{"tier index holds missing key", func(c *inMemoryCache) { c.tiers["hot"]["gone"] = struct{}{} }, `indexes missing key "gone"`},
+		{"item not in tier index", func(c *inMemoryCache) {
+			item := c.items["c"]
+			item.tier = "hot"
+			c.items["c"] = item
+		}, `key "c" in tier "hot" is not indexed`},
+		{"pinned count", func(c *inMemoryCache) { c.pinned++ }, "pinned count is 2, want 1"},
+		{"total size", func(c *inMemoryCache) { c.bytes += 7 }, "total size is"},
+		{"priority count", func(c *inMemoryCache) { c.priorities[5]++ }, "priority 5 counts 2 items, want 1"},
+		{"stale priority", func(c *inMemoryCache) { c.priorities[9] = 1 }, "priority 9 counts 1 items, want 0"},
+		{"policy misses key", func(c *inMemoryCache) { c.policy.OnRemove("b") }, `key "b" is not tracked by the eviction policy`},
+		{"policy tracks missing key", func(c *inMemoryCache) { c.policy.OnAdd("gone") }, `eviction policy tracks missing key "gone"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newVerifyTestCache(t)
+			tt.desync(c)
+
+			err := c.Verify()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Verify() error = %v, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyAfterOperations(t *testing.T) {
+	c := NewBoundedCache(3, NewLRUPolicy())
+	e := c.(EvictionCache)
+	for i, key := range []string{"a", "b", "c", "d", "e"} {
+		e.SetWithPriority(key, i, 0, i%2)
+	}
+	e.Pin("e")
+	c.Delete("d")
+	c.(TTLCache).SetWithTier("f", 1, time.Hour, "cold")
+	c.Clear()
+	c.Set("g", "value")
+
+	if err := c.(Verifier).Verify(); err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+}